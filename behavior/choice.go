@@ -0,0 +1,68 @@
+package behavior
+
+import "time"
+
+// Weighted is an option for Choose with its relative probability.
+type Weighted[T any] struct {
+	Value  T
+	Weight float64 // Relative weight, options with non-positive weights are never chosen.
+}
+
+// Choose converts simple [0,1] float64 noise into one of the options, picked
+// with the probability proportional to its weight.
+//
+// Returns the zero value if there are no options with positive weights.
+func Choose[T any](noise float64, options ...Weighted[T]) T {
+	var total float64
+	for _, o := range options {
+		total += max(o.Weight, 0)
+	}
+	var chosen T
+	target := noise * total
+	for _, o := range options {
+		if o.Weight <= 0 {
+			continue
+		}
+		chosen = o.Value // The last option also absorbs rounding errors at noise=1.
+		if target < o.Weight {
+			break
+		}
+		target -= o.Weight
+	}
+	return chosen
+}
+
+// Shuffle deterministically permutes the slice in place, using noise values
+// starting at t.
+//
+// Noise must not be smooth: values at adjacent nanoseconds must be
+// independent, like those of HashNoise or Md5Noise.
+func Shuffle[T any](noise TemporalNoise, t time.Time, s []T) {
+	// Fisher-Yates shuffle.
+	for i := len(s) - 1; i > 0; i-- {
+		j := index(noise, t.Add(time.Duration(len(s)-1-i)*time.Nanosecond), i+1)
+		s[i], s[j] = s[j], s[i]
+	}
+}
+
+// Sample deterministically picks k distinct elements of the slice, using noise
+// values starting at t. Each element is equally likely to be picked. The slice
+// is not modified.
+//
+// Returns all elements in a random order if k exceeds the slice length. Noise
+// must not be smooth, see Shuffle.
+func Sample[T any](noise TemporalNoise, t time.Time, k int, s []T) []T {
+	k = min(max(k, 0), len(s))
+	pool := append([]T(nil), s...)
+	// Partial Fisher-Yates shuffle, which only settles the first k elements.
+	for i := range k {
+		j := i + index(noise, t.Add(time.Duration(i)*time.Nanosecond), len(pool)-i)
+		pool[i], pool[j] = pool[j], pool[i]
+	}
+	return pool[:k]
+}
+
+// index converts noise at t into an index in [0, n).
+func index(noise TemporalNoise, t time.Time, n int) int {
+	return min(Spread(0, n, noise.At(t)), n-1)
+}
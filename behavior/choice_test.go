@@ -0,0 +1,154 @@
+package behavior
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// chiSquared returns the chi-squared statistic of the observed counts against
+// the expected probabilities.
+func chiSquared(counts []int, probabilities []float64) float64 {
+	total := 0
+	for _, c := range counts {
+		total += c
+	}
+	var chi2 float64
+	for i, c := range counts {
+		expected := float64(total) * probabilities[i]
+		chi2 += (float64(c) - expected) * (float64(c) - expected) / expected
+	}
+	return chi2
+}
+
+// uniform returns n equal probabilities.
+func uniform(n int) []float64 {
+	p := make([]float64, n)
+	for i := range p {
+		p[i] = 1 / float64(n)
+	}
+	return p
+}
+
+// Critical chi-squared values for p=0.001, indexed by degrees of freedom.
+var chiSquaredCritical = map[int]float64{2: 13.82, 3: 16.27, 4: 18.47, 5: 20.52}
+
+func TestChoose(t *testing.T) {
+	options := []Weighted[string]{
+		{Value: "groom", Weight: 1},
+		{Value: "never", Weight: 0},
+		{Value: "stretch", Weight: 2},
+		{Value: "nap", Weight: 5},
+		{Value: "negative", Weight: -1},
+	}
+
+	tests := []struct {
+		noise float64
+		want  string
+	}{
+		{noise: 0, want: "groom"},
+		{noise: 0.124, want: "groom"},
+		{noise: 0.125, want: "stretch"},
+		{noise: 0.374, want: "stretch"},
+		{noise: 0.375, want: "nap"},
+		{noise: 1, want: "nap"},
+	}
+	for _, tc := range tests {
+		if got := Choose(tc.noise, options...); got != tc.want {
+			t.Errorf("Choose(%v) = %q, want %q", tc.noise, got, tc.want)
+		}
+	}
+
+	if got := Choose[string](0.5); got != "" {
+		t.Errorf("Choose() without options = %q, want zero value", got)
+	}
+	if got := Choose(0.5, Weighted[int]{Value: 1, Weight: 0}); got != 0 {
+		t.Errorf("Choose() with only zero weights = %d, want zero value", got)
+	}
+}
+
+func TestChoose_Distribution(t *testing.T) {
+	noise := NewHashNoise([]byte("choose_distribution_seed"))
+	start := time.Unix(1234567890, 0).UTC()
+	options := []Weighted[int]{{0, 1}, {1, 2}, {2, 3}, {3, 4}}
+
+	counts := make([]int, len(options))
+	for i := range 100000 {
+		counts[Choose(noise.At(start.Add(time.Duration(i))), options...)]++
+	}
+	if chi2 := chiSquared(counts, []float64{0.1, 0.2, 0.3, 0.4}); chi2 > chiSquaredCritical[3] {
+		t.Errorf("Choose() distribution %v doesn't match the weights 1:2:3:4, chi-squared = %f", counts, chi2)
+	}
+}
+
+func TestShuffle(t *testing.T) {
+	noise := NewHashNoise([]byte("shuffle_seed"))
+	start := time.Unix(1234567890, 0).UTC()
+
+	s := []int{0, 1, 2, 3, 4, 5}
+	Shuffle(noise, start, s)
+	again := []int{0, 1, 2, 3, 4, 5}
+	Shuffle(noise, start, again)
+	if !slices.Equal(s, again) {
+		t.Errorf("Shuffle() is not deterministic: %v and %v", s, again)
+	}
+	sorted := slices.Clone(s)
+	slices.Sort(sorted)
+	if !slices.Equal(sorted, []int{0, 1, 2, 3, 4, 5}) {
+		t.Errorf("Shuffle() = %v, want a permutation of the input", s)
+	}
+
+	// Every element is equally likely to end up in every position. Shuffles
+	// at different times are spaced apart, so their noise doesn't overlap.
+	const n = 5
+	counts := make([][]int, n)
+	for i := range counts {
+		counts[i] = make([]int, n)
+	}
+	for i := range 20000 {
+		s := []int{0, 1, 2, 3, 4}
+		Shuffle(noise, start.Add(time.Duration(i)*time.Microsecond), s)
+		for pos, v := range s {
+			counts[v][pos]++
+		}
+	}
+	for v, c := range counts {
+		if chi2 := chiSquared(c, uniform(n)); chi2 > chiSquaredCritical[n-1] {
+			t.Errorf("Element %d positions %v are not uniform, chi-squared = %f", v, c, chi2)
+		}
+	}
+}
+
+func TestSample(t *testing.T) {
+	noise := NewHashNoise([]byte("sample_seed"))
+	start := time.Unix(1234567890, 0).UTC()
+	input := []string{"a", "b", "c", "d", "e", "f"}
+
+	got := Sample(noise, start, 3, input)
+	if len(got) != 3 {
+		t.Fatalf("Sample(3) returned %d elements, want 3", len(got))
+	}
+	if got[0] == got[1] || got[1] == got[2] || got[0] == got[2] {
+		t.Errorf("Sample(3) = %v, want distinct elements", got)
+	}
+	if !slices.Equal(input, []string{"a", "b", "c", "d", "e", "f"}) {
+		t.Errorf("Sample() modified the input: %v", input)
+	}
+	if got := Sample(noise, start, 10, input); len(got) != len(input) {
+		t.Errorf("Sample(10) of %d elements returned %d, want all", len(input), len(got))
+	}
+	if got := Sample(noise, start, -1, input); len(got) != 0 {
+		t.Errorf("Sample(-1) = %v, want empty", got)
+	}
+
+	// Each element is equally likely to be picked.
+	counts := make([]int, len(input))
+	for i := range 20000 {
+		for _, v := range Sample(noise, start.Add(time.Duration(i)*time.Microsecond), 2, []int{0, 1, 2, 3, 4, 5}) {
+			counts[v]++
+		}
+	}
+	if chi2 := chiSquared(counts, uniform(len(input))); chi2 > chiSquaredCritical[len(input)-1] {
+		t.Errorf("Sample() picks %v are not uniform, chi-squared = %f", counts, chi2)
+	}
+}
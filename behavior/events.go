@@ -0,0 +1,133 @@
+package behavior
+
+import (
+	"iter"
+	"math"
+	"slices"
+	"time"
+)
+
+const (
+	// maxEventsPerBucket bounds the number of events generated in a single
+	// Events bucket. With one event per bucket on average, exceeding it is
+	// practically impossible.
+	maxEventsPerBucket = 64
+	// maxEmptyBuckets limits how far Events.Next and Events.Prev search.
+	maxEmptyBuckets = 1 << 16
+)
+
+// Events generates pseudo-random, deterministic event times: a seeded Poisson
+// process with the average Interval between events.
+//
+// Time is split into buckets of Interval length, referenced to zero time. The
+// number of events in each bucket and their positions are derived from the
+// noise values at the start of the bucket only, so the result doesn't depend on
+// which interval is queried, and nothing needs to be stored.
+//
+// Noise must not be smooth: values at adjacent nanoseconds must be
+// independent, like those of HashNoise or Md5Noise.
+type Events struct {
+	Noise    TemporalNoise
+	Interval time.Duration // Average time between events. No events happen if not positive.
+}
+
+// Between returns events that happen within [from, to), in order.
+func (e Events) Between(from, to time.Time) []time.Time {
+	return slices.Collect(e.All(from, to))
+}
+
+// Happened returns true if any event happens within [from, to).
+func (e Events) Happened(from, to time.Time) bool {
+	for range e.All(from, to) {
+		return true
+	}
+	return false
+}
+
+// All iterates over events that happen within [from, to), in order.
+func (e Events) All(from, to time.Time) iter.Seq[time.Time] {
+	return func(yield func(time.Time) bool) {
+		if e.Interval <= 0 {
+			return
+		}
+		for bucket := from.Truncate(e.Interval); bucket.Before(to); bucket = bucket.Add(e.Interval) {
+			for _, t := range e.bucket(bucket) {
+				if t.Before(from) {
+					continue
+				}
+				if !t.Before(to) || !yield(t) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Next returns the first event at or after t. Returns false if there are no
+// events within a very long time, which only happens with broken noise.
+func (e Events) Next(t time.Time) (time.Time, bool) {
+	if e.Interval <= 0 {
+		return time.Time{}, false
+	}
+	bucket := t.Truncate(e.Interval)
+	for range maxEmptyBuckets {
+		for _, event := range e.bucket(bucket) {
+			if !event.Before(t) {
+				return event, true
+			}
+		}
+		bucket = bucket.Add(e.Interval)
+	}
+	return time.Time{}, false
+}
+
+// Prev returns the last event before t. Returns false if there are no events
+// within a very long time, which only happens with broken noise.
+func (e Events) Prev(t time.Time) (time.Time, bool) {
+	if e.Interval <= 0 {
+		return time.Time{}, false
+	}
+	bucket := t.Truncate(e.Interval)
+	for range maxEmptyBuckets {
+		events := e.bucket(bucket)
+		for i := len(events) - 1; i >= 0; i-- {
+			if events[i].Before(t) {
+				return events[i], true
+			}
+		}
+		bucket = bucket.Add(-e.Interval)
+	}
+	return time.Time{}, false
+}
+
+// bucket returns events within the bucket starting at the given time, in
+// order.
+func (e Events) bucket(start time.Time) []time.Time {
+	n := poisson(1, e.Noise.At(start))
+	if n == 0 {
+		return nil
+	}
+	events := make([]time.Time, n)
+	for i := range events {
+		// The bucket start itself was used for the count, event positions use
+		// the following nanoseconds.
+		offset := e.Noise.At(start.Add(time.Duration(i+1) * time.Nanosecond))
+		events[i] = start.Add(min(Spread(0, e.Interval, offset), e.Interval-1))
+	}
+	slices.SortFunc(events, func(a, b time.Time) int { return a.Compare(b) })
+	return events
+}
+
+// poisson converts uniform noise into a Poisson-distributed number of events
+// with the given mean, using the inverse cumulative distribution function.
+func poisson(mean float64, noise float64) int {
+	p := math.Exp(-mean)
+	cdf := p
+	k := 0
+	for noise > cdf && k < maxEventsPerBucket {
+		k++
+		p *= mean / float64(k)
+		cdf += p
+	}
+	return k
+}
@@ -0,0 +1,142 @@
+package behavior
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func newTestEvents(seed string, interval time.Duration) Events {
+	return Events{Noise: NewHashNoise([]byte(seed)), Interval: interval}
+}
+
+func TestEvents_WindowStability(t *testing.T) {
+	events := newTestEvents("events_window_seed", time.Hour)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(30 * 24 * time.Hour)
+	whole := events.Between(start, end)
+
+	// Splitting the window at arbitrary points must yield the same events.
+	for _, step := range []time.Duration{time.Minute, 7*time.Hour + 13*time.Minute, 5 * 24 * time.Hour} {
+		var parts []time.Time
+		for from := start; from.Before(end); from = from.Add(step) {
+			to := from.Add(step)
+			if to.After(end) {
+				to = end
+			}
+			parts = append(parts, events.Between(from, to)...)
+		}
+		if diff := cmp.Diff(whole, parts); diff != "" {
+			t.Errorf("Events queried in %v windows differ from a single query (-whole +parts):\n%s", step, diff)
+		}
+	}
+
+	for i, e := range whole {
+		if e.Before(start) || !e.Before(end) {
+			t.Errorf("Event %v is outside of the queried window [%v, %v)", e, start, end)
+		}
+		if i > 0 && e.Before(whole[i-1]) {
+			t.Errorf("Events are out of order: %v before %v", whole[i-1], e)
+		}
+	}
+}
+
+func TestEvents_Rate(t *testing.T) {
+	events := newTestEvents("events_rate_seed", 10*time.Minute)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	const buckets = 20000
+	all := events.Between(start, start.Add(buckets*events.Interval))
+
+	// The count is Poisson-distributed with the mean equal to the variance, so
+	// the standard deviation is sqrt(buckets). Allow 4 standard deviations.
+	if diff := math.Abs(float64(len(all)) - buckets); diff > 4*math.Sqrt(buckets) {
+		t.Errorf("Got %d events over %d intervals, want about %d", len(all), buckets, buckets)
+	}
+
+	// Gaps between events of a Poisson process are exponentially distributed:
+	// a fraction of e^-1 of them is longer than the average interval.
+	long := 0
+	for i := 1; i < len(all); i++ {
+		if all[i].Sub(all[i-1]) > events.Interval {
+			long++
+		}
+	}
+	if got, want := float64(long)/float64(len(all)-1), math.Exp(-1); math.Abs(got-want) > 0.02 {
+		t.Errorf("Got %.3f of gaps longer than the interval, want about %.3f", got, want)
+	}
+}
+
+func TestEvents_NextPrev(t *testing.T) {
+	events := newTestEvents("events_next_seed", time.Hour)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	all := events.Between(start, start.Add(10*24*time.Hour))
+	if len(all) < 10 {
+		t.Fatalf("Got %d events, want enough to test with", len(all))
+	}
+
+	for i := 1; i < len(all)-1; i++ {
+		if next, ok := events.Next(all[i-1].Add(time.Nanosecond)); !ok || !next.Equal(all[i]) {
+			t.Errorf("Next(%v) = %v, %v; want %v", all[i-1].Add(time.Nanosecond), next, ok, all[i])
+		}
+		if next, ok := events.Next(all[i]); !ok || !next.Equal(all[i]) {
+			t.Errorf("Next(%v) = %v, %v; want the event itself", all[i], next, ok)
+		}
+		if prev, ok := events.Prev(all[i]); !ok || !prev.Equal(all[i-1]) {
+			t.Errorf("Prev(%v) = %v, %v; want %v", all[i], prev, ok, all[i-1])
+		}
+	}
+
+	if !events.Happened(all[0], all[0].Add(time.Nanosecond)) {
+		t.Errorf("Happened() around %v = false, want true", all[0])
+	}
+	if events.Happened(all[0].Add(time.Nanosecond), all[1]) {
+		t.Errorf("Happened() between consecutive events = true, want false")
+	}
+}
+
+func TestEvents_NonPositiveInterval(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, interval := range []time.Duration{0, -time.Hour} {
+		events := newTestEvents("events_empty_seed", interval)
+		if got := events.Between(start, start.Add(time.Hour)); len(got) != 0 {
+			t.Errorf("Between() with interval %v = %v, want no events", interval, got)
+		}
+		if next, ok := events.Next(start); ok {
+			t.Errorf("Next() with interval %v = %v, want no event", interval, next)
+		}
+		if prev, ok := events.Prev(start); ok {
+			t.Errorf("Prev() with interval %v = %v, want no event", interval, prev)
+		}
+	}
+}
+
+func TestPoisson(t *testing.T) {
+	tests := []struct {
+		noise float64
+		want  int
+	}{
+		{noise: 0, want: 0},
+		{noise: 0.36, want: 0}, // e^-1 ≈ 0.368
+		{noise: 0.37, want: 1},
+		{noise: 0.73, want: 1}, // 2e^-1 ≈ 0.736
+		{noise: 0.74, want: 2},
+	}
+	for _, tc := range tests {
+		if got := poisson(1, tc.noise); got != tc.want {
+			t.Errorf("poisson(1, %v) = %d, want %d", tc.noise, got, tc.want)
+		}
+	}
+}
+
+func BenchmarkEvents(b *testing.B) {
+	events := newTestEvents("benchmark_events_seed", time.Hour)
+	start := time.Unix(1678886400, 0).UTC()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = events.Happened(start, start.Add(24*time.Hour))
+	}
+}
@@ -0,0 +1,58 @@
+package behavior
+
+import (
+	"iter"
+	"time"
+)
+
+// Markov is a discrete-time Markov chain, which changes its state every Step
+// according to transition probabilities that may depend on time.
+//
+// The chain doesn't need to store anything: the state at any time is computed
+// by replaying the transitions since the start, using deterministic noise
+// sampled at each step.
+type Markov[S comparable] struct {
+	// Noise drives transitions. It must not be smooth, see Shuffle.
+	Noise TemporalNoise
+	Step  time.Duration
+	// Transitions returns the possible next states with their weights, given
+	// the current state and the time elapsed since the start of the chain. If
+	// there are no options with positive weights, the state doesn't change.
+	Transitions func(state S, elapsed time.Duration) []Weighted[S]
+}
+
+// At returns the state at t of the chain that started in the initial state at
+// the start time. The cost is proportional to the number of steps in between.
+func (m Markov[S]) At(initial S, start, t time.Time) S {
+	state := initial
+	for _, s := range m.All(initial, start, t) {
+		state = s
+	}
+	return state
+}
+
+// All iterates over the states of the chain after each step up to and
+// including the until time, along with the step time. The initial state at the
+// start time is not included.
+func (m Markov[S]) All(initial S, start, until time.Time) iter.Seq2[time.Time, S] {
+	return func(yield func(time.Time, S) bool) {
+		state := initial
+		for elapsed := m.Step; elapsed <= until.Sub(start); elapsed += m.Step {
+			at := start.Add(elapsed)
+			state = m.next(state, elapsed, m.Noise.At(at))
+			if !yield(at, state) {
+				return
+			}
+		}
+	}
+}
+
+func (m Markov[S]) next(state S, elapsed time.Duration, noise float64) S {
+	options := m.Transitions(state, elapsed)
+	for _, o := range options {
+		if o.Weight > 0 {
+			return Choose(noise, options...)
+		}
+	}
+	return state
+}
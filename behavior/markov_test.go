@@ -0,0 +1,72 @@
+package behavior
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestMarkov_Stationary(t *testing.T) {
+	// A two-state chain switching A->B with probability 0.1 and B->A with 0.3
+	// spends 3/4 of the time in A.
+	m := Markov[string]{
+		Noise: NewHashNoise([]byte("markov_stationary_seed")),
+		Step:  time.Minute,
+		Transitions: func(state string, _ time.Duration) []Weighted[string] {
+			if state == "A" {
+				return []Weighted[string]{{"A", 0.9}, {"B", 0.1}}
+			}
+			return []Weighted[string]{{"A", 0.3}, {"B", 0.7}}
+		},
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	steps, inA := 0, 0
+	for _, s := range m.All("A", start, start.Add(100000*time.Minute)) {
+		steps++
+		if s == "A" {
+			inA++
+		}
+	}
+	if steps != 100000 {
+		t.Errorf("All() yielded %d steps, want 100000", steps)
+	}
+	if got := float64(inA) / float64(steps); math.Abs(got-0.75) > 0.02 {
+		t.Errorf("Chain spent %.3f of the time in A, want about 0.75", got)
+	}
+}
+
+func TestMarkov_At(t *testing.T) {
+	// A chain that can only leave the initial state after an hour.
+	m := Markov[int]{
+		Noise: NewHashNoise([]byte("markov_at_seed")),
+		Step:  time.Minute,
+		Transitions: func(state int, elapsed time.Duration) []Weighted[int] {
+			if elapsed <= time.Hour {
+				return nil
+			}
+			return []Weighted[int]{{state, 1}, {state + 1, 1}}
+		},
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	if got := m.At(0, start, start.Add(time.Hour)); got != 0 {
+		t.Errorf("At(1h) = %d, want 0 before transitions are allowed", got)
+	}
+	if got := m.At(0, start, start.Add(-time.Hour)); got != 0 {
+		t.Errorf("At(-1h) = %d, want the initial state", got)
+	}
+
+	// At agrees with All at every step, including between steps.
+	for at, state := range m.All(0, start, start.Add(3*time.Hour)) {
+		if got := m.At(0, start, at); got != state {
+			t.Fatalf("At(%v) = %d, want %d from All()", at, got, state)
+		}
+		if got := m.At(0, start, at.Add(59*time.Second)); got != state {
+			t.Fatalf("At(%v) = %d, want %d between steps", at.Add(59*time.Second), got, state)
+		}
+	}
+	if got := m.At(0, start, start.Add(3*time.Hour)); got < 30 || got > 90 {
+		t.Errorf("At(3h) = %d, want about 60 after 120 fair coin flips", got)
+	}
+}
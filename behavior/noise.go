@@ -44,6 +44,41 @@ func (hn Md5Noise) At(t time.Time) float64 {
 	return f64 / math.MaxUint64
 }
 
+// HashNoise returns hash-based, pseudo-random, time-dependent noise.
+//
+// It's a faster, allocation-free alternative to Md5Noise, based on the
+// SplitMix64 mixing function. It produces a different sequence than Md5Noise
+// for the same seed.
+type HashNoise struct {
+	Seed uint64
+}
+
+// NewHashNoise returns HashNoise seeded with arbitrary data.
+func NewHashNoise(seed []byte) HashNoise {
+	// FNV-1a, inlined to avoid allocating a hasher.
+	h := uint64(14695981039346656037)
+	for _, b := range seed {
+		h ^= uint64(b)
+		h *= 1099511628211
+	}
+	return HashNoise{Seed: h}
+}
+
+func (hn HashNoise) At(t time.Time) float64 {
+	h := splitMix64(hn.Seed ^ splitMix64(uint64(t.UnixNano())))
+	// Use the top 53 bits, which a float64 can represent exactly.
+	return float64(h>>11) / (1 << 53)
+}
+
+// splitMix64 is the finalizer of the SplitMix64 generator, which maps distinct
+// inputs to distinct, well-mixed outputs.
+func splitMix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
 // SmoothNoise provides pseudo-random, smooth noise dependent on the time.
 //
 // It uses smoothstep interpolation to smoothen the underlying TemporalNoise
@@ -67,6 +102,56 @@ func (tn SmoothNoise) At(t time.Time) float64 {
 	return v0 + smootherStep(t0, t1, t)*(v1-v0)
 }
 
+// octaveOffset shifts the sampling grid of each subsequent FractalNoise octave,
+// so that octaves don't sample the underlying noise at the same points. It's a
+// prime number of nanoseconds to avoid aligning with any round period.
+const octaveOffset = 7919 * time.Nanosecond
+
+// FractalNoise provides pseudo-random, smooth noise that varies at several
+// timescales at once, also known as fractional Brownian motion.
+//
+// It sums Octaves layers of SmoothNoise. The first octave has the given Period,
+// each next one has a period Lacunarity times shorter and an amplitude Gain
+// times smaller. The sum is normalized back into the [0, 1] range.
+//
+// The random numbers are not cryptographically secure.
+type FractalNoise struct {
+	Underlying TemporalNoise
+	Period     time.Duration // Period of the first, slowest octave.
+	Octaves    int           // Number of octaves. Defaults to 1.
+	Lacunarity float64       // Period divisor between octaves. Defaults to 2.
+	Gain       float64       // Amplitude multiplier between octaves. Defaults to 0.5.
+}
+
+// At returns a temporal noise value corresponding to the time point `t`. The
+// value is guaranteed to be in the [0, 1.0] range.
+func (fn FractalNoise) At(t time.Time) float64 {
+	octaves := max(fn.Octaves, 1)
+	lacunarity := fn.Lacunarity
+	if lacunarity <= 0 {
+		lacunarity = 2
+	}
+	gain := fn.Gain
+	if gain <= 0 {
+		gain = 0.5
+	}
+
+	var sum, total float64
+	period := float64(fn.Period)
+	amplitude := 1.0
+	for i := range octaves {
+		octave := SmoothNoise{
+			Underlying: fn.Underlying,
+			Period:     max(time.Duration(period), time.Nanosecond),
+		}
+		sum += amplitude * octave.At(t.Add(time.Duration(i)*octaveOffset))
+		total += amplitude
+		period /= lacunarity
+		amplitude *= gain
+	}
+	return clamp01(sum / total)
+}
+
 // clamp01 clamps v to the [0, 1] interval.
 func clamp01(v float64) float64 {
 	return min(max(v, 0), 1)
@@ -81,6 +81,65 @@ func FuzzMd5NoiseProperties(f *testing.F) {
 	})
 }
 
+func FuzzHashNoiseProperties(f *testing.F) {
+	// Seed corpus: t1_nanos (int64), seed_bytes ([]byte), t2_nanos (int64)
+	f.Add(int64(0), []byte("seed1"), int64(1))                              // Epoch, simple seed, t2 just after t1
+	f.Add(int64(1000000000), []byte("seed2"), int64(1000000000))            // Same time
+	f.Add(int64(1678886400000000000), []byte{}, int64(1678886400000000001)) // Empty seed, t2 just after t1
+	f.Add(int64(-1000000000), []byte{0x01, 0x02}, int64(2000000000))        // Pre-epoch and post-epoch
+	f.Add(int64(math.MaxInt64), []byte("maxTime"), int64(0))                // Max int64 nanos (Year 2262)
+	f.Add(int64(0), []byte("minTime"), int64(math.MinInt64))                // Min int64 nanos (Year 1677)
+
+	f.Fuzz(func(t *testing.T, timeNano1 int64, seed []byte, timeNano2 int64) {
+		t.Logf("Fuzzing HashNoise with parameters: timeNano1=%d, seed=%x, timeNano2=%d", timeNano1, seed, timeNano2)
+
+		noise := NewHashNoise(seed)
+		ts1 := time.Unix(0, timeNano1).UTC()
+		ts2 := time.Unix(0, timeNano2).UTC()
+
+		checkDeterminism(t, noise, ts1)
+		checkDeterminism(t, noise, ts2)
+		checkUniqueness(t, noise, ts1, ts2)
+		checkRange(t, noise, ts1)
+		checkRange(t, noise, ts2)
+	})
+}
+
+func TestHashNoiseDistribution(t *testing.T) {
+	noise := NewHashNoise([]byte("hash_distribution_seed"))
+	start := time.Unix(1234567890, 0).UTC()
+	const (
+		buckets = 10
+		samples = 100000
+	)
+
+	var counts [buckets]int
+	for i := range samples {
+		v := noise.At(start.Add(time.Duration(i) * time.Nanosecond))
+		counts[min(int(v*buckets), buckets-1)]++
+	}
+
+	// Chi-squared test with 9 degrees of freedom, 21.67 is the critical value
+	// for p=0.01.
+	expected := float64(samples) / buckets
+	var chi2 float64
+	for _, c := range counts {
+		chi2 += (float64(c) - expected) * (float64(c) - expected) / expected
+	}
+	if chi2 > 21.67 {
+		t.Errorf("HashNoise values are not uniform: chi-squared = %f over buckets %v", chi2, counts)
+	}
+}
+
+func TestHashNoise_Allocations(t *testing.T) {
+	noise := NewHashNoise([]byte("hash_alloc_seed"))
+	smooth := SmoothNoise{Underlying: noise, Period: time.Second}
+	ts := time.Unix(1678886400, 0).UTC()
+	if allocs := testing.AllocsPerRun(100, func() { _ = smooth.At(ts) }); allocs != 0 {
+		t.Errorf("SmoothNoise over HashNoise allocated %v times per call, want 0", allocs)
+	}
+}
+
 func FuzzSmoothNoiseProperties(f *testing.F) {
 	const (
 		minNanosPeriod = int64(1) // Smallest valid period (1ns) for SmoothNoise
@@ -134,16 +193,140 @@ func FuzzSmoothNoiseProperties(f *testing.F) {
 	})
 }
 
+func FuzzFractalNoiseProperties(f *testing.F) {
+	// Seed corpus: t1Nanos, seedBytes, t2Nanos, periodNanos, octaves
+	f.Add(int64(0), []byte("f_seed1"), int64(1), int64(time.Hour), 4)
+	f.Add(int64(1e9), []byte("f_seed2"), int64(1e9), int64(time.Minute), 1) // Same time
+	f.Add(int64(1678886400000000000), []byte{}, int64(1678886400000000001), int64(24*time.Hour), 8)
+	f.Add(int64(-1e9), []byte{0x01, 0x02, 0x03}, int64(2e9), int64(time.Second), 3)
+	f.Add(int64(0), []byte(nil), int64(100), int64(1), 16) // Octave periods shorter than 1ns
+	f.Add(int64(0), []byte("f_minTime"), int64(math.MinInt64), int64(time.Hour), 2)
+
+	f.Fuzz(func(t *testing.T, timeNano1 int64, seed []byte, timeNano2 int64, periodNanos int64, octaves int) {
+		t.Logf("Fuzzing FractalNoise with parameters: timeNano1=%d, seed=%x, timeNano2=%d, periodNanos=%d, octaves=%d",
+			timeNano1, seed, timeNano2, periodNanos, octaves)
+
+		periodNanos = max(periodNanos, 1)
+		octaves = min(max(octaves, 1), 32)
+		noise := FractalNoise{
+			Underlying: Md5Noise{Seed: seed},
+			Period:     time.Duration(periodNanos),
+			Octaves:    octaves,
+		}
+
+		// Stay away from the end of the supported range, since octaves are
+		// sampled slightly later than t.
+		ts1 := time.Unix(0, min(timeNano1, math.MaxInt64-int64(time.Hour))).UTC()
+		ts2 := time.Unix(0, min(timeNano2, math.MaxInt64-int64(time.Hour))).UTC()
+
+		checkDeterminism(t, noise, ts1)
+		checkDeterminism(t, noise, ts2)
+		checkRange(t, noise, ts1)
+		checkRange(t, noise, ts2)
+		if ts1.Sub(ts2).Abs() > time.Millisecond {
+			checkUniqueness(t, noise, ts1, ts2)
+		}
+	})
+}
+
+func TestFractalNoise_SingleOctave(t *testing.T) {
+	underlying := Md5Noise{Seed: []byte("fractal_single_octave_seed")}
+	fractal := FractalNoise{Underlying: underlying, Period: time.Minute}
+	smooth := SmoothNoise{Underlying: underlying, Period: time.Minute}
+	start := time.Unix(1234567890, 0).UTC()
+
+	for i := range 100 {
+		ts := start.Add(time.Duration(i) * 7 * time.Second)
+		if got, want := fractal.At(ts), smooth.At(ts); math.Abs(got-want) > 1e-12 {
+			t.Errorf("FractalNoise with one octave at %v = %v, want SmoothNoise value %v", ts, got, want)
+		}
+	}
+}
+
+// meanAbsStep returns the average absolute difference between noise values
+// sampled with the given step.
+func meanAbsStep(noise TemporalNoise, start time.Time, step time.Duration, samples int) float64 {
+	var total float64
+	prev := noise.At(start)
+	for i := range samples {
+		v := noise.At(start.Add(time.Duration(i+1) * step))
+		total += math.Abs(v - prev)
+		prev = v
+	}
+	return total / float64(samples)
+}
+
+func TestFractalNoise_Timescales(t *testing.T) {
+	underlying := Md5Noise{Seed: []byte("fractal_timescales_seed")}
+	start := time.Unix(987654321, 0).UTC()
+	smooth := SmoothNoise{Underlying: underlying, Period: 24 * time.Hour}
+	fractal := FractalNoise{
+		Underlying: underlying,
+		Period:     24 * time.Hour,
+		Octaves:    8, // The last octave has a period of ~11 minutes.
+	}
+
+	// Within minutes, a single daily octave barely changes, while the fractal
+	// noise keeps moving thanks to the faster octaves.
+	smoothStep := meanAbsStep(smooth, start, time.Minute, 2000)
+	fractalStep := meanAbsStep(fractal, start, time.Minute, 2000)
+	t.Logf("Average per-minute change: SmoothNoise %f, FractalNoise %f", smoothStep, fractalStep)
+	if fractalStep < 1.5*smoothStep {
+		t.Errorf("FractalNoise average per-minute change %f, want at least 1.5 times the daily SmoothNoise change %f", fractalStep, smoothStep)
+	}
+
+	// It's still continuous: tiny steps produce tiny changes.
+	if step := meanAbsStep(fractal, start, time.Millisecond, 1000); step > 1e-4 {
+		t.Errorf("FractalNoise average per-millisecond change %f, want < 1e-4", step)
+	}
+
+	// Octaves are normalized, so the values must use most of the range.
+	lo, hi := 1.0, 0.0
+	for i := range 10000 {
+		v := fractal.At(start.Add(time.Duration(i) * 17 * time.Minute))
+		lo, hi = min(lo, v), max(hi, v)
+	}
+	if lo > 0.25 || hi < 0.75 {
+		t.Errorf("FractalNoise values span [%f, %f], want to cover at least [0.25, 0.75]", lo, hi)
+	}
+}
+
 func BenchmarkMd5Noise(b *testing.B) {
 	noise := Md5Noise{Seed: []byte("benchmark_seed")}
 	ts := time.Unix(1678886400, 0).UTC() // A fixed timestamp
 
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = noise.At(ts)
+	}
+}
+
+func BenchmarkHashNoise(b *testing.B) {
+	noise := NewHashNoise([]byte("benchmark_seed"))
+	ts := time.Unix(1678886400, 0).UTC() // A fixed timestamp
+
+	b.ReportAllocs()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		_ = noise.At(ts)
 	}
 }
 
+func BenchmarkSmoothHashNoise(b *testing.B) {
+	smoothNoise := SmoothNoise{
+		Underlying: NewHashNoise([]byte("benchmark_smooth_seed")),
+		Period:     time.Second,
+	}
+	ts := time.Unix(1678886400, 0).UTC() // A fixed timestamp
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = smoothNoise.At(ts)
+	}
+}
+
 func BenchmarkSmoothNoise(b *testing.B) {
 	smoothNoise := SmoothNoise{
 		Underlying: Md5Noise{Seed: []byte("benchmark_smooth_seed")},
@@ -157,6 +340,20 @@ func BenchmarkSmoothNoise(b *testing.B) {
 	}
 }
 
+func BenchmarkFractalNoise(b *testing.B) {
+	fractalNoise := FractalNoise{
+		Underlying: Md5Noise{Seed: []byte("benchmark_fractal_seed")},
+		Period:     24 * time.Hour,
+		Octaves:    8,
+	}
+	ts := time.Unix(1678886400, 0).UTC() // A fixed timestamp
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = fractalNoise.At(ts)
+	}
+}
+
 func TestMd5NoiseConsecutiveDifference(t *testing.T) {
 	noise := Md5Noise{Seed: []byte("md5_consecutive_diff_seed")}
 	startTs := time.Unix(1234567890, 0).UTC()
@@ -6,4 +6,70 @@ import "time"
 type NowFunc func() time.Time
 
 // Now returns current time. Can be overridden in tests using chronotest package.
+//
+// Prefer accepting a Clock, which tests can replace without affecting each
+// other.
 var Now NowFunc = time.Now
+
+// Clock is a source of the current time and timers.
+//
+// Components that depend on time should accept a Clock, so that tests can
+// substitute chronotest.Clock and run in parallel.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// NewTimer creates a timer that fires once after the duration.
+	NewTimer(d time.Duration) Timer
+	// NewTicker creates a ticker that fires repeatedly with the given period.
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer is an equivalent of time.Timer.
+type Timer interface {
+	// C returns the channel on which the time is delivered when the timer fires.
+	C() <-chan time.Time
+	// Stop prevents the timer from firing. Returns false if the timer has
+	// already fired or been stopped.
+	Stop() bool
+	// Reset changes the timer to fire after the duration. Returns true if the
+	// timer had been active.
+	Reset(d time.Duration) bool
+}
+
+// Ticker is an equivalent of time.Ticker.
+type Ticker interface {
+	// C returns the channel on which the ticks are delivered.
+	C() <-chan time.Time
+	// Stop turns off the ticker.
+	Stop()
+	// Reset stops the ticker and resets its period to the duration.
+	Reset(d time.Duration)
+}
+
+// System is the real clock. Its Now method uses the Now variable, so that
+// legacy overrides keep working.
+var System Clock = systemClock{}
+
+// Or returns the clock, or System if it's nil.
+func Or(c Clock) Clock {
+	if c == nil {
+		return System
+	}
+	return c
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return Now() }
+
+func (systemClock) NewTimer(d time.Duration) Timer { return systemTimer{time.NewTimer(d)} }
+
+func (systemClock) NewTicker(d time.Duration) Ticker { return systemTicker{time.NewTicker(d)} }
+
+type systemTimer struct{ *time.Timer }
+
+func (t systemTimer) C() <-chan time.Time { return t.Timer.C }
+
+type systemTicker struct{ *time.Ticker }
+
+func (t systemTicker) C() <-chan time.Time { return t.Ticker.C }
@@ -9,12 +9,17 @@ import (
 
 // OverrideNow overrides chrono.Now to return the fixed time for the duration
 // of the test. Automatically restores to time.Now at the end of the test.
+//
+// It affects all tests running in parallel, prefer passing a Clock instead.
 func OverrideNow(t *testing.T, now time.Time) {
 	orig := chrono.Now
 	chrono.Now = func() time.Time { return now }
 	t.Cleanup(func() { chrono.Now = orig })
 }
 
+// OverrideScope overrides chrono.Now to return the fixed time while f runs.
+//
+// It affects all tests running in parallel, prefer passing a Clock instead.
 func OverrideScope(now time.Time, f func()) {
 	orig := chrono.Now
 	chrono.Now = func() time.Time { return now }
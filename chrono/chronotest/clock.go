@@ -0,0 +1,153 @@
+package chronotest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nevkontakte/pat/chrono"
+)
+
+// Clock is a fake chrono.Clock, which only moves when told to.
+//
+// Timers and tickers fire synchronously within Set and Advance calls, in the
+// order of their deadlines. Like their time package counterparts, they drop
+// ticks if the receiver doesn't keep up. Clock is safe for concurrent use.
+type Clock struct {
+	mu      sync.Mutex
+	changed sync.Cond // Broadcast when the set of waiters changes.
+	now     time.Time
+	waiters map[*waiter]bool
+}
+
+var _ chrono.Clock = (*Clock)(nil)
+
+// NewClock returns a fake clock set to the given time.
+func NewClock(now time.Time) *Clock {
+	c := &Clock{now: now, waiters: map[*waiter]bool{}}
+	c.changed.L = &c.mu
+	return c
+}
+
+// Now returns the current fake time.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by the duration, firing timers along the way.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(c.now.Add(d))
+}
+
+// Set moves the clock to the given time, firing timers due by then. Moving the
+// clock backwards doesn't fire anything.
+func (c *Clock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(t)
+}
+
+func (c *Clock) set(t time.Time) {
+	for {
+		// Fire the earliest waiter first, so that the time it reports is
+		// consistent with the order of events.
+		var next *waiter
+		for w := range c.waiters {
+			if !w.deadline.After(t) && (next == nil || w.deadline.Before(next.deadline)) {
+				next = w
+			}
+		}
+		if next == nil {
+			break
+		}
+		if next.deadline.After(c.now) {
+			c.now = next.deadline
+		}
+		select {
+		case next.ch <- next.deadline:
+		default: // Drop the tick, like time.Ticker does.
+		}
+		if next.period > 0 {
+			next.deadline = next.deadline.Add(next.period)
+		} else {
+			delete(c.waiters, next)
+			c.changed.Broadcast()
+		}
+	}
+	c.now = t
+}
+
+// BlockUntil waits until at least n timers and tickers are active. Use it to
+// make sure a goroutine under test is waiting for the clock before advancing it.
+func (c *Clock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.waiters) < n {
+		c.changed.Wait()
+	}
+}
+
+// NewTimer creates a fake timer that fires when the clock reaches now+d.
+func (c *Clock) NewTimer(d time.Duration) chrono.Timer {
+	w := &waiter{clock: c, ch: make(chan time.Time, 1)}
+	w.reset(d, 0)
+	return w
+}
+
+// NewTicker creates a fake ticker that fires every d.
+func (c *Clock) NewTicker(d time.Duration) chrono.Ticker {
+	if d <= 0 {
+		panic("chronotest: non-positive interval for NewTicker")
+	}
+	w := &waiter{clock: c, ch: make(chan time.Time, 1)}
+	w.reset(d, d)
+	return ticker{w}
+}
+
+// waiter is a pending fake timer or ticker.
+type waiter struct {
+	clock    *Clock
+	ch       chan time.Time
+	deadline time.Time
+	period   time.Duration // Zero for timers.
+}
+
+func (w *waiter) C() <-chan time.Time { return w.ch }
+
+func (w *waiter) Stop() bool {
+	c := w.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	active := c.waiters[w]
+	delete(c.waiters, w)
+	c.changed.Broadcast()
+	return active
+}
+
+func (w *waiter) Reset(d time.Duration) bool {
+	return w.reset(d, 0)
+}
+
+func (w *waiter) reset(d time.Duration, period time.Duration) bool {
+	c := w.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	active := c.waiters[w]
+	w.deadline = c.now.Add(d)
+	w.period = period
+	c.waiters[w] = true
+	c.changed.Broadcast()
+	// A timer with a non-positive duration fires right away.
+	c.set(c.now)
+	return active
+}
+
+// ticker adapts waiter to the chrono.Ticker interface.
+type ticker struct{ *waiter }
+
+func (t ticker) Stop() { t.waiter.Stop() }
+
+func (t ticker) Reset(d time.Duration) { t.waiter.reset(d, d) }
@@ -0,0 +1,95 @@
+package chronotest
+
+import (
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// received returns the value from the channel, if any, without blocking.
+func received(ch <-chan time.Time) (time.Time, bool) {
+	select {
+	case t := <-ch:
+		return t, true
+	default:
+		return time.Time{}, false
+	}
+}
+
+func TestClock_Timer(t *testing.T) {
+	t.Parallel()
+	c := NewClock(epoch)
+	timer := c.NewTimer(time.Minute)
+
+	c.Advance(59 * time.Second)
+	if _, ok := received(timer.C()); ok {
+		t.Fatalf("Got: timer fired after 59s. Want: not fired.")
+	}
+	c.Advance(2 * time.Second)
+	if got, ok := received(timer.C()); !ok || !got.Equal(epoch.Add(time.Minute)) {
+		t.Errorf("Got: timer fired %v at %v. Want: fired at %v.", ok, got, epoch.Add(time.Minute))
+	}
+	if got, want := c.Now(), epoch.Add(61*time.Second); !got.Equal(want) {
+		t.Errorf("Got: Now() = %v. Want: %v.", got, want)
+	}
+	if timer.Stop() {
+		t.Errorf("Got: Stop() of a fired timer returned true. Want: false.")
+	}
+
+	if timer.Reset(time.Second) {
+		t.Errorf("Got: Reset() of a fired timer returned true. Want: false.")
+	}
+	if !timer.Stop() {
+		t.Errorf("Got: Stop() of an active timer returned false. Want: true.")
+	}
+	c.Advance(time.Hour)
+	if _, ok := received(timer.C()); ok {
+		t.Errorf("Got: stopped timer fired. Want: not fired.")
+	}
+}
+
+func TestClock_Ticker(t *testing.T) {
+	t.Parallel()
+	c := NewClock(epoch)
+	ticker := c.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for i := 1; i <= 3; i++ {
+		c.Advance(time.Minute)
+		if got, ok := received(ticker.C()); !ok || !got.Equal(epoch.Add(time.Duration(i)*time.Minute)) {
+			t.Errorf("Got: tick %d %v at %v. Want: tick at %v.", i, ok, got, epoch.Add(time.Duration(i)*time.Minute))
+		}
+	}
+
+	// Ticks are dropped if the receiver doesn't keep up.
+	c.Advance(10 * time.Minute)
+	if got, ok := received(ticker.C()); !ok || !got.Equal(epoch.Add(4*time.Minute)) {
+		t.Errorf("Got: tick %v at %v. Want: the first missed tick at %v.", ok, got, epoch.Add(4*time.Minute))
+	}
+	if _, ok := received(ticker.C()); ok {
+		t.Errorf("Got: more than one buffered tick. Want: one.")
+	}
+
+	ticker.Reset(time.Hour)
+	c.Advance(59 * time.Minute)
+	if _, ok := received(ticker.C()); ok {
+		t.Errorf("Got: tick before the new period elapsed. Want: none.")
+	}
+}
+
+func TestClock_BlockUntil(t *testing.T) {
+	t.Parallel()
+	c := NewClock(epoch)
+	done := make(chan time.Time)
+	go func() {
+		timer := c.NewTimer(time.Hour)
+		done <- <-timer.C()
+	}()
+
+	c.BlockUntil(1)
+	c.Set(epoch.Add(2 * time.Hour))
+	if got := <-done; !got.Equal(epoch.Add(time.Hour)) {
+		t.Errorf("Got: timer fired at %v. Want: %v.", got, epoch.Add(time.Hour))
+	}
+}
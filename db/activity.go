@@ -0,0 +1,79 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nevkontakte/pat/behavior"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Activity is something a cat does on its own, at pseudo-random times that
+// follow from the cat's noise.
+type Activity struct {
+	Cue      string // Unique noise cue for the activity schedule.
+	Event    EventType
+	Interval time.Duration // Average time between occurrences.
+}
+
+// Activities lists all autonomous activities.
+var Activities = []Activity{
+	{Cue: "nap", Event: EventNapped, Interval: 6 * time.Hour},
+	{Cue: "fly", Event: EventChasedFly, Interval: 12 * time.Hour},
+	{Cue: "wall", Event: EventStaredAtWall, Interval: 8 * time.Hour},
+	{Cue: "zoomies", Event: EventZoomies, Interval: 24 * time.Hour},
+}
+
+// Schedule returns the times when the cat does the activity.
+func (c Cat) Schedule(a Activity) behavior.Events {
+	return c.Events(a.Cue, a.Interval)
+}
+
+// activityKey returns the journal record key for the activity occurrence.
+func activityKey(cat CatID, a Activity, at time.Time) *string {
+	key := fmt.Sprintf("activity:%s:%s:%d", cat, a.Cue, at.UnixNano())
+	return &key
+}
+
+// RecordActivities adds journal records for the cat's activities that happen
+// within [from, to) and returns the number of added records.
+//
+// Activities before the latest interaction with the cat are still recorded,
+// but described without the mood, see Cat.Describe.
+//
+// Each occurrence is recorded once, even if the function is called repeatedly
+// or concurrently for overlapping intervals.
+func RecordActivities(tx *gorm.DB, cat Cat, from, to time.Time) (int, error) {
+	var records []Journal
+	for _, a := range Activities {
+		for at := range cat.Schedule(a).All(from, to) {
+			records = append(records, Journal{
+				CreatedAt: at,
+				CatID:     cat.ID,
+				Event:     Event{Type: a.Event, Description: cat.Describe(a.Event, at, nil)},
+				Key:       activityKey(cat.ID, a, at),
+			})
+		}
+	}
+
+	added := 0
+	for _, r := range records {
+		inserted := false
+		err := tx.Transaction(func(tx *gorm.DB) error {
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&r)
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+			inserted = true
+			return EnqueueDeliveries(tx, r)
+		})
+		if err != nil {
+			return added, err
+		}
+		if inserted {
+			added++
+		}
+	}
+	return added, nil
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nevkontakte/pat/db/dbtest"
+)
+
+func TestRecordActivities(t *testing.T) {
+	tx := dbtest.InMemory(t)
+	tx.AutoMigrate(Cat{}, Journal{}, Webhook{}, Delivery{})
+	cat := Cat{ID: SplotchID, Name: "Splotch"}
+	dbtest.Save(t, tx, &cat)
+
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(7 * 24 * time.Hour)
+	// The last of the overlapping intervals below ends 12 hours after the end.
+	want := 0
+	for _, a := range Activities {
+		want += len(cat.Schedule(a).Between(start, end.Add(12*time.Hour)))
+	}
+	if want == 0 {
+		t.Fatalf("Got: no activities scheduled within a week. Want: some.")
+	}
+
+	// Overlapping intervals add each occurrence once.
+	added := 0
+	for from := start; from.Before(end); from = from.Add(12 * time.Hour) {
+		n, err := RecordActivities(tx, cat, from, from.Add(24*time.Hour))
+		if err != nil {
+			t.Fatalf("Got: RecordActivities() returned error: %s. Want: no error.", err)
+		}
+		added += n
+	}
+	if added != want {
+		t.Errorf("Got: %d records added. Want: %d.", added, want)
+	}
+	var count int64
+	tx.Model(&Journal{}).Count(&count)
+	if count != int64(want) {
+		t.Errorf("Got: %d journal records. Want: %d.", count, want)
+	}
+
+	var nap Journal
+	dbtest.First(t, tx, &nap, "type = ?", EventNapped)
+	bank := Narrator.Bank(EventNapped.String(), "")
+	if !slices.Contains(bank, strings.ReplaceAll(nap.Event.Description, "Splotch", "{cat}")) || nap.CatID != SplotchID || nap.Visitor != nil {
+		t.Errorf("Got: nap record %+v. Want: an autonomous record about Splotch.", nap)
+	}
+	if events := cat.Schedule(Activities[0]).Between(nap.CreatedAt, nap.CreatedAt.Add(time.Nanosecond)); len(events) != 1 {
+		t.Errorf("Got: nap record at %v, which isn't on the schedule. Want: scheduled time.", nap.CreatedAt)
+	}
+}
@@ -0,0 +1,346 @@
+package db
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DefaultAdmin is the account name of the bootstrap owner, authenticated with
+// the -admin-password flag. It doesn't have a database record.
+const DefaultAdmin = "admin"
+
+// Role defines what an admin account is allowed to do.
+type Role string
+
+const (
+	RoleViewer    Role = "viewer"    // Can view the admin area.
+	RoleModerator Role = "moderator" // Can also moderate visitor content.
+	RoleOwner     Role = "owner"     // Can also manage admin accounts.
+)
+
+// Roles lists all known roles, from the least to the most privileged.
+var Roles = []Role{RoleViewer, RoleModerator, RoleOwner}
+
+func (r Role) rank() int {
+	for i, role := range Roles {
+		if r == role {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Valid returns true if r is one of the known roles.
+func (r Role) Valid() bool {
+	return r.rank() > 0
+}
+
+// Includes returns true if the role grants all permissions of the other role.
+func (r Role) Includes(other Role) bool {
+	return other.Valid() && r.rank() >= other.rank()
+}
+
+// Admin is an account with access to the admin area.
+//
+// Accounts are created by an owner with an invite, and become usable once the
+// invitee sets their password.
+type Admin struct {
+	Username  string `gorm:"primaryKey"`
+	CreatedAt time.Time
+	Role      Role
+	// PasswordHash is the bcrypt hash of the account password. Empty until the
+	// invite is accepted.
+	PasswordHash []byte
+	// InvitedBy is the account that created the invite.
+	InvitedBy string
+	// InviteHash is the hash of the pending invite token. Nil once the invite
+	// has been accepted.
+	InviteHash []byte `gorm:"uniqueIndex"`
+}
+
+// Active returns true if the account can be used to log in.
+func (a Admin) Active() bool {
+	return len(a.PasswordHash) > 0
+}
+
+// HashToken returns the hash under which a random secret token is stored.
+//
+// Tokens are random and long enough that a fast hash is sufficient.
+func HashToken(token string) []byte {
+	h := sha256.Sum256([]byte(token))
+	return h[:]
+}
+
+// AdminByUsername queries the admin account with the given username.
+func AdminByUsername(tx *gorm.DB, username string) (Admin, error) {
+	var a Admin
+	if result := tx.First(&a, "username = ?", username); result.Error != nil {
+		return Admin{}, result.Error
+	}
+	return a, nil
+}
+
+// Admins returns all admin accounts, including pending invites, ordered by
+// username.
+func Admins(tx *gorm.DB) ([]Admin, error) {
+	var admins []Admin
+	if result := tx.Order("username").Find(&admins); result.Error != nil {
+		return nil, result.Error
+	}
+	return admins, nil
+}
+
+// InviteAdmin creates a pending admin account, which can be claimed with the
+// invite token.
+func InviteAdmin(tx *gorm.DB, username string, role Role, invitedBy string, token string) error {
+	if username == DefaultAdmin {
+		return fmt.Errorf("username %q is reserved", username)
+	}
+	if !role.Valid() {
+		return fmt.Errorf("unknown role %q", role)
+	}
+	result := tx.Create(&Admin{
+		Username:   username,
+		Role:       role,
+		InvitedBy:  invitedBy,
+		InviteHash: HashToken(token),
+	})
+	return result.Error
+}
+
+// AcceptInvite sets the password of the account invited with the token and
+// activates it.
+//
+// Returns gorm.ErrRecordNotFound if there is no pending invite with such token.
+func AcceptInvite(tx *gorm.DB, token string, passwordHash []byte) (Admin, error) {
+	var a Admin
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		if result := tx.First(&a, "invite_hash = ?", HashToken(token)); result.Error != nil {
+			return result.Error
+		}
+		a.PasswordHash = passwordHash
+		a.InviteHash = nil
+		return tx.Save(&a).Error
+	})
+	if err != nil {
+		return Admin{}, err
+	}
+	return a, nil
+}
+
+// RemoveAdmin deletes the admin account along with its second factor and API
+// tokens.
+func RemoveAdmin(tx *gorm.DB, username string) error {
+	return tx.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("username = ?", username).Delete(&Admin{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return fmt.Errorf("deleted %d rows: %w", result.RowsAffected, gorm.ErrRecordNotFound)
+		}
+		if result := tx.Where("admin = ?", username).Delete(&APIToken{}); result.Error != nil {
+			return result.Error
+		}
+		return ResetSecondFactor(tx, username)
+	})
+}
+
+// SecondFactor holds the TOTP second factor enrollment of an admin account.
+type SecondFactor struct {
+	Account   string `gorm:"primaryKey"`
+	CreatedAt time.Time
+	// Secret is the TOTP secret shared with the authenticator app.
+	Secret []byte
+	// Confirmed is set once the admin has proven possession of the secret. The
+	// second factor is only enforced for confirmed enrollments.
+	Confirmed bool
+	// LastCounter is the time step counter of the latest accepted code, used to
+	// prevent code reuse.
+	LastCounter int64
+	// Failures counts wrong codes entered since the latest lockout or accepted
+	// code.
+	Failures int
+	// LockedUntil is when the account accepts codes again after too many wrong
+	// ones.
+	LockedUntil time.Time
+}
+
+const (
+	// SecondFactorAttempts is the number of wrong codes that locks the second
+	// factor check of the account.
+	SecondFactorAttempts = 5
+	// SecondFactorLockout is how long the second factor check stays locked.
+	SecondFactorLockout = 15 * time.Minute
+)
+
+// ErrSecondFactorLocked is returned by SecondFactorFailed when it locks the
+// account, and should be reported to the admin while SecondFactor.Locked is
+// true.
+var ErrSecondFactorLocked = errors.New("too many wrong codes")
+
+// Locked returns true if the account doesn't accept codes, even correct ones,
+// at the given time.
+func (sf SecondFactor) Locked(now time.Time) bool {
+	return now.Before(sf.LockedUntil)
+}
+
+// RecoveryCode is a single-use code that can replace a TOTP code in case the
+// admin loses access to their authenticator app.
+//
+// Only a hash of the code is stored, see HashToken.
+type RecoveryCode struct {
+	ID      uint64 `gorm:"primaryKey"`
+	Account string `gorm:"index"`
+	Hash    []byte `gorm:"uniqueIndex"`
+}
+
+// SecondFactorFor returns second factor enrollment for the account.
+//
+// Returns gorm.ErrRecordNotFound if the account has no enrollment, confirmed
+// or not.
+func SecondFactorFor(tx *gorm.DB, account string) (SecondFactor, error) {
+	var sf SecondFactor
+	if result := tx.First(&sf, "account = ?", account); result.Error != nil {
+		return SecondFactor{}, result.Error
+	}
+	return sf, nil
+}
+
+// EnrollSecondFactor starts a new, unconfirmed enrollment for the account.
+//
+// Any previous unconfirmed enrollment is replaced. Confirmed enrollments can
+// only be removed with ResetSecondFactor.
+func EnrollSecondFactor(tx *gorm.DB, account string, secret []byte) error {
+	return tx.Transaction(func(tx *gorm.DB) error {
+		existing, err := SecondFactorFor(tx, account)
+		if err == nil && existing.Confirmed {
+			return fmt.Errorf("second factor is already enrolled for %q", account)
+		} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		result := tx.Save(&SecondFactor{Account: account, Secret: secret})
+		return result.Error
+	})
+}
+
+// ConfirmSecondFactor activates the enrollment and replaces the account's
+// recovery codes.
+//
+// counter is the time step counter of the code used for confirmation.
+func ConfirmSecondFactor(tx *gorm.DB, account string, counter int64, recoveryCodes []string) error {
+	return tx.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&SecondFactor{}).Where("account = ?", account).Updates(map[string]any{
+			"confirmed":    true,
+			"last_counter": counter,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return fmt.Errorf("updated %d rows: %w", result.RowsAffected, gorm.ErrRecordNotFound)
+		}
+
+		if result := tx.Where("account = ?", account).Delete(&RecoveryCode{}); result.Error != nil {
+			return result.Error
+		}
+		for _, code := range recoveryCodes {
+			if result := tx.Create(&RecoveryCode{Account: account, Hash: HashToken(code)}); result.Error != nil {
+				return result.Error
+			}
+		}
+		return nil
+	})
+}
+
+// AcceptSecondFactorCode records counter as the latest used TOTP code.
+//
+// The update is conditional, so that concurrent logins can't use the same code
+// twice. Returns gorm.ErrRecordNotFound if the code was already used.
+func AcceptSecondFactorCode(tx *gorm.DB, account string, counter int64) error {
+	result := tx.Model(&SecondFactor{}).
+		Where("account = ? AND last_counter < ?", account, counter).
+		Update("last_counter", counter)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != 1 {
+		return fmt.Errorf("updated %d rows: %w", result.RowsAffected, gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+// SecondFactorFailed counts a wrong code entered for the account. Once there
+// were SecondFactorAttempts of them, the account is locked for
+// SecondFactorLockout and ErrSecondFactorLocked is returned.
+func SecondFactorFailed(tx *gorm.DB, account string, now time.Time) error {
+	locked := false
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&SecondFactor{}).
+			Where("account = ?", account).
+			Update("failures", gorm.Expr("failures + 1"))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return fmt.Errorf("updated %d rows: %w", result.RowsAffected, gorm.ErrRecordNotFound)
+		}
+		var sf SecondFactor
+		if result := tx.First(&sf, "account = ?", account); result.Error != nil {
+			return result.Error
+		}
+		if sf.Failures < SecondFactorAttempts {
+			return nil
+		}
+		locked = true
+		return tx.Model(&sf).Updates(map[string]any{
+			"failures":     0,
+			"locked_until": now.Add(SecondFactorLockout),
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+	if locked {
+		return ErrSecondFactorLocked
+	}
+	return nil
+}
+
+// SecondFactorSucceeded forgets wrong codes entered for the account before a
+// correct one.
+func SecondFactorSucceeded(tx *gorm.DB, account string) error {
+	return tx.Model(&SecondFactor{}).Where("account = ?", account).Update("failures", 0).Error
+}
+
+// UseRecoveryCode consumes the recovery code for the account.
+//
+// Returns gorm.ErrRecordNotFound if there is no such unused code.
+func UseRecoveryCode(tx *gorm.DB, account string, code string) error {
+	result := tx.Where("account = ? AND hash = ?", account, HashToken(code)).Delete(&RecoveryCode{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != 1 {
+		return fmt.Errorf("deleted %d rows: %w", result.RowsAffected, gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+// ResetSecondFactor removes second factor enrollment and recovery codes of the
+// account, so that it can log in with a password alone.
+func ResetSecondFactor(tx *gorm.DB, account string) error {
+	return tx.Transaction(func(tx *gorm.DB) error {
+		if result := tx.Where("account = ?", account).Delete(&RecoveryCode{}); result.Error != nil {
+			return result.Error
+		}
+		if result := tx.Where("account = ?", account).Delete(&SecondFactor{}); result.Error != nil {
+			return result.Error
+		}
+		return nil
+	})
+}
@@ -0,0 +1,187 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nevkontakte/pat/db/dbtest"
+	"gorm.io/gorm"
+)
+
+func TestSecondFactor(t *testing.T) {
+	tx := dbtest.InMemory(t)
+	tx.AutoMigrate(&SecondFactor{}, &RecoveryCode{})
+
+	if _, err := SecondFactorFor(tx, DefaultAdmin); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("Got: SecondFactorFor() before enrollment returned error: %v. Want: %v.", err, gorm.ErrRecordNotFound)
+	}
+
+	if err := EnrollSecondFactor(tx, DefaultAdmin, []byte("first")); err != nil {
+		t.Fatalf("Got: EnrollSecondFactor() returned error: %s. Want: no error.", err)
+	}
+	// Unconfirmed enrollment can be replaced.
+	if err := EnrollSecondFactor(tx, DefaultAdmin, []byte("second")); err != nil {
+		t.Fatalf("Got: second EnrollSecondFactor() returned error: %s. Want: no error.", err)
+	}
+	sf, err := SecondFactorFor(tx, DefaultAdmin)
+	if err != nil {
+		t.Fatalf("Got: SecondFactorFor() returned error: %s. Want: no error.", err)
+	}
+	if string(sf.Secret) != "second" || sf.Confirmed {
+		t.Fatalf("Got: %+v. Want: unconfirmed enrollment with the second secret.", sf)
+	}
+
+	if err := ConfirmSecondFactor(tx, DefaultAdmin, 10, []string{"code-a", "code-b"}); err != nil {
+		t.Fatalf("Got: ConfirmSecondFactor() returned error: %s. Want: no error.", err)
+	}
+	dbtest.First(t, tx, &sf, "account = ?", DefaultAdmin)
+	if !sf.Confirmed || sf.LastCounter != 10 {
+		t.Errorf("Got: %+v. Want: confirmed enrollment with LastCounter=10.", sf)
+	}
+
+	t.Run("confirmed enrollment can't be replaced", func(t *testing.T) {
+		if err := EnrollSecondFactor(tx, DefaultAdmin, []byte("third")); err == nil {
+			t.Errorf("Got: EnrollSecondFactor() succeeded. Want: error.")
+		}
+	})
+
+	t.Run("codes can't be reused", func(t *testing.T) {
+		for _, counter := range []int64{9, 10} {
+			if err := AcceptSecondFactorCode(tx, DefaultAdmin, counter); !errors.Is(err, gorm.ErrRecordNotFound) {
+				t.Errorf("Got: AcceptSecondFactorCode(%d) returned error: %v. Want: %v.", counter, err, gorm.ErrRecordNotFound)
+			}
+		}
+		if err := AcceptSecondFactorCode(tx, DefaultAdmin, 11); err != nil {
+			t.Errorf("Got: AcceptSecondFactorCode(11) returned error: %s. Want: no error.", err)
+		}
+	})
+
+	t.Run("recovery codes are single-use", func(t *testing.T) {
+		if err := UseRecoveryCode(tx, DefaultAdmin, "code-a"); err != nil {
+			t.Fatalf("Got: UseRecoveryCode() returned error: %s. Want: no error.", err)
+		}
+		if err := UseRecoveryCode(tx, DefaultAdmin, "code-a"); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("Got: second UseRecoveryCode() returned error: %v. Want: %v.", err, gorm.ErrRecordNotFound)
+		}
+		if err := UseRecoveryCode(tx, "someone-else", "code-b"); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("Got: UseRecoveryCode() for another account returned error: %v. Want: %v.", err, gorm.ErrRecordNotFound)
+		}
+	})
+
+	t.Run("wrong codes lock the account", func(t *testing.T) {
+		now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+		for i := range SecondFactorAttempts - 1 {
+			if err := SecondFactorFailed(tx, DefaultAdmin, now); err != nil {
+				t.Fatalf("Got: SecondFactorFailed() #%d returned error: %s. Want: no error.", i+1, err)
+			}
+		}
+		// A correct code starts the count over.
+		if err := SecondFactorSucceeded(tx, DefaultAdmin); err != nil {
+			t.Fatalf("Got: SecondFactorSucceeded() returned error: %s. Want: no error.", err)
+		}
+		for i := range SecondFactorAttempts - 1 {
+			if err := SecondFactorFailed(tx, DefaultAdmin, now); err != nil {
+				t.Fatalf("Got: SecondFactorFailed() #%d after success returned error: %s. Want: no error.", i+1, err)
+			}
+		}
+		if err := SecondFactorFailed(tx, DefaultAdmin, now); !errors.Is(err, ErrSecondFactorLocked) {
+			t.Fatalf("Got: SecondFactorFailed() #%d returned error: %v. Want: %v.", SecondFactorAttempts, err, ErrSecondFactorLocked)
+		}
+		dbtest.First(t, tx, &sf, "account = ?", DefaultAdmin)
+		if !sf.Locked(now.Add(SecondFactorLockout-time.Second)) || sf.Locked(now.Add(SecondFactorLockout)) {
+			t.Errorf("Got: locked until %s. Want: locked until %s.", sf.LockedUntil, now.Add(SecondFactorLockout))
+		}
+		if sf.Failures != 0 {
+			t.Errorf("Got: %d failures after lockout. Want: 0.", sf.Failures)
+		}
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		if err := ResetSecondFactor(tx, DefaultAdmin); err != nil {
+			t.Fatalf("Got: ResetSecondFactor() returned error: %s. Want: no error.", err)
+		}
+		if _, err := SecondFactorFor(tx, DefaultAdmin); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("Got: SecondFactorFor() after reset returned error: %v. Want: %v.", err, gorm.ErrRecordNotFound)
+		}
+		if err := UseRecoveryCode(tx, DefaultAdmin, "code-b"); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("Got: UseRecoveryCode() after reset returned error: %v. Want: %v.", err, gorm.ErrRecordNotFound)
+		}
+	})
+}
+
+func TestRole_Includes(t *testing.T) {
+	testCases := []struct {
+		role, other Role
+		want        bool
+	}{
+		{RoleOwner, RoleViewer, true},
+		{RoleOwner, RoleOwner, true},
+		{RoleModerator, RoleViewer, true},
+		{RoleModerator, RoleOwner, false},
+		{RoleViewer, RoleModerator, false},
+		{Role("root"), RoleViewer, false},
+		{RoleOwner, Role("root"), false},
+	}
+
+	for _, tc := range testCases {
+		if got := tc.role.Includes(tc.other); got != tc.want {
+			t.Errorf("Role(%q).Includes(%q) = %v, want %v", tc.role, tc.other, got, tc.want)
+		}
+	}
+}
+
+func TestAdmin_InviteAcceptRemove(t *testing.T) {
+	tx := dbtest.InMemory(t)
+	tx.AutoMigrate(&Admin{}, &SecondFactor{}, &RecoveryCode{}, &APIToken{})
+
+	if err := InviteAdmin(tx, DefaultAdmin, RoleViewer, DefaultAdmin, "token-0"); err == nil {
+		t.Errorf("Got: InviteAdmin(%q) succeeded. Want: error for the reserved username.", DefaultAdmin)
+	}
+	if err := InviteAdmin(tx, "bob", Role("root"), DefaultAdmin, "token-0"); err == nil {
+		t.Errorf("Got: InviteAdmin() with unknown role succeeded. Want: error.")
+	}
+
+	for i, username := range []string{"alice", "bob"} {
+		token := "token-" + username
+		if err := InviteAdmin(tx, username, RoleModerator, DefaultAdmin, token); err != nil {
+			t.Fatalf("Got: InviteAdmin(%q) returned error: %s. Want: no error.", username, err)
+		}
+		a, err := AdminByUsername(tx, username)
+		if err != nil {
+			t.Fatalf("Got: AdminByUsername(%q) returned error: %s. Want: no error.", username, err)
+		}
+		if a.Active() {
+			t.Errorf("Got: invited account %q is active. Want: inactive until the invite is accepted.", username)
+		}
+
+		a, err = AcceptInvite(tx, token, []byte{byte(i + 1)})
+		if err != nil {
+			t.Fatalf("Got: AcceptInvite(%q) returned error: %s. Want: no error.", token, err)
+		}
+		if a.Username != username || !a.Active() || a.InviteHash != nil {
+			t.Errorf("Got: AcceptInvite(%q) returned %+v. Want: active account %q.", token, a, username)
+		}
+		if _, err := AcceptInvite(tx, token, []byte{0}); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("Got: second AcceptInvite(%q) returned error: %v. Want: %v.", token, err, gorm.ErrRecordNotFound)
+		}
+	}
+
+	admins, err := Admins(tx)
+	if err != nil {
+		t.Fatalf("Got: Admins() returned error: %s. Want: no error.", err)
+	}
+	if len(admins) != 2 || admins[0].Username != "alice" || admins[1].Username != "bob" {
+		t.Errorf("Got: Admins() = %+v. Want: alice and bob.", admins)
+	}
+
+	if err := RemoveAdmin(tx, "alice"); err != nil {
+		t.Fatalf("Got: RemoveAdmin() returned error: %s. Want: no error.", err)
+	}
+	if _, err := AdminByUsername(tx, "alice"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Got: AdminByUsername() after removal returned error: %v. Want: %v.", err, gorm.ErrRecordNotFound)
+	}
+	if err := RemoveAdmin(tx, "alice"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Got: second RemoveAdmin() returned error: %v. Want: %v.", err, gorm.ErrRecordNotFound)
+	}
+}
@@ -1,19 +1,46 @@
 package db
 
 import (
+	"database/sql/driver"
 	"fmt"
+	"regexp"
 	"time"
 
 	"github.com/nevkontakte/pat/behavior"
-	"github.com/nevkontakte/pat/chrono"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 	"gorm.io/gorm"
 )
 
 // CatID is the type for Cat record's primary key and unique identifier.
+//
+// An empty CatID is stored as SQL NULL, so that records not related to any cat
+// can reference it.
 type CatID string
 
+// Value implements driver.Valuer. It returns SQL NULL for an empty ID.
+func (id CatID) Value() (driver.Value, error) {
+	if id == "" {
+		return nil, nil
+	}
+	return string(id), nil
+}
+
+// Scan implements sql.Scanner. It accepts string, []byte or NULL input.
+func (id *CatID) Scan(src any) error {
+	switch src := src.(type) {
+	case nil:
+		*id = ""
+	case string:
+		*id = CatID(src)
+	case []byte:
+		*id = CatID(src)
+	default:
+		return fmt.Errorf("unsupported CatID source type %T", src)
+	}
+	return nil
+}
+
 // Seed returns the noise seed for the cat, given noise type.
 func (id CatID) Seed(cue string) []byte {
 	return []byte(cue + string(id))
@@ -38,48 +65,77 @@ const (
 	MoodSecret    Mood = "secret"
 )
 
+// AllMoods lists all moods.
+var AllMoods = []Mood{MoodIdle, MoodIdleBlink, MoodIdleHappy, MoodImpatient, MoodPat, MoodSecret}
+
+// NoiseKind selects the noise source behind the cat's behavior.
+//
+// Changing it changes the cat's past and future behavior, so it should only be
+// chosen when the cat is created.
+type NoiseKind string
+
+const (
+	// NoiseMd5 uses behavior.Md5Noise. It's the default, which all cats created
+	// before the choice was introduced use.
+	NoiseMd5 NoiseKind = "md5"
+	// NoiseHash uses the faster behavior.HashNoise.
+	NoiseHash NoiseKind = "hash"
+)
+
+// Valid returns true for known noise kinds, including the empty default.
+func (k NoiseKind) Valid() bool {
+	return k == "" || k == NoiseMd5 || k == NoiseHash
+}
+
 // Cat represents a database record about a single cat.
 type Cat struct {
 	ID        CatID     // Unique identifier of the cat, must be URL-safe.
 	Name      string    // Human-readable name of the cat.
 	Pats      uint64    // Total number of pats received by the cat.
 	LatestPat time.Time // Time when the latest pat was received.
-}
+	FirstPat  time.Time // Time when the first pat was received. Zero if unknown.
+	Noise     NoiseKind // Noise source for the cat's behavior. Empty means NoiseMd5.
+	Model     MoodModel // How the cat's mood is determined. Empty means MoodModelClassic.
 
-// Mood corresponding to Cat's current state.
-func (c Cat) Mood() Mood {
-	now := chrono.Now()
-	sincePat := now.Sub(c.LatestPat)
-
-	// Someone is petting the cat right now!
-	if sincePat < 5*time.Second {
-		return MoodPat
-	}
+	Personality Personality `gorm:"embedded;embeddedPrefix:personality_"`
+	Needs       Needs       `gorm:"embedded;embeddedPrefix:needs_"`
+}
 
-	// Someone petted the cat recently, he's happy.
-	if sincePat < 30*time.Minute {
-		return MoodIdleHappy
+// MoodAt returns the cat's mood at the given time, according to its mood model
+// and needs.
+func (c Cat) MoodAt(now time.Time) Mood {
+	switch c.Model {
+	case MoodModelMarkov:
+		return c.needsMood(now, c.markovMood(now))
+	default:
+		return c.needsMood(now, c.classicMood(now))
 	}
+}
 
-	// In the next three hours, the cat may remember getting petted and get happy again.
-	moodSwing := behavior.Spread(-3*time.Hour, 3*time.Hour, c.noise(c.ID.Seed("happy"), 5*time.Minute).At(now))
-	if sincePat+moodSwing < 30*time.Minute {
-		return MoodIdleHappy
+func (c Cat) noise(seed []byte, period time.Duration) behavior.SmoothNoise {
+	return behavior.SmoothNoise{
+		Underlying: c.rawNoise(seed),
+		Period:     period,
 	}
+}
 
-	// Cat's just chillin'.
-	if sincePat < 7*24*time.Hour {
-		return MoodIdle
+// rawNoise returns the cat's non-smooth noise source for the given seed.
+func (c Cat) rawNoise(seed []byte) behavior.TemporalNoise {
+	switch c.Noise {
+	case NoiseHash:
+		return behavior.NewHashNoise(seed)
+	default:
+		return behavior.Md5Noise{Seed: seed}
 	}
-
-	// It's been far too long since anyone played with the cat, she's bored.
-	return MoodImpatient
 }
 
-func (c Cat) noise(seed []byte, period time.Duration) behavior.TemporalNoise {
-	return behavior.SmoothNoise{
-		Underlying: behavior.Md5Noise{Seed: seed},
-		Period:     period,
+// Events returns the cat's deterministic schedule of an autonomous behavior,
+// such as knocking things off the table, identified by the cue. The behavior
+// happens once per interval on average.
+func (c Cat) Events(cue string, interval time.Duration) behavior.Events {
+	return behavior.Events{
+		Noise:    c.rawNoise(c.ID.Seed(cue)),
+		Interval: interval,
 	}
 }
 
@@ -93,12 +149,87 @@ func CatByID(tx *gorm.DB, id CatID) (Cat, error) {
 	return c, nil
 }
 
-// Pat records a new pat for the given Cat.
-func Pat(tx *gorm.DB, id CatID) error {
-	result := tx.Model(Cat{ID: id}).Updates(map[string]any{
-		"pats":       gorm.Expr("pats + 1"),
-		"latest_pat": chrono.Now(),
-	})
+// lockCat locks the cat's row until the end of the transaction, so that
+// concurrent interactions with the cat happen one after another. It's a no-op
+// update rather than SELECT ... FOR UPDATE, which SQLite doesn't support.
+func lockCat(tx *gorm.DB, id CatID) error {
+	result := tx.Model(&Cat{}).Where("id = ?", id).UpdateColumn("id", gorm.Expr("id"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != 1 {
+		return fmt.Errorf("updated %d rows: %w", result.RowsAffected, gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+// Cats returns all cats, ordered by ID.
+func Cats(tx *gorm.DB) ([]Cat, error) {
+	var cats []Cat
+	if result := tx.Order("id").Find(&cats); result.Error != nil {
+		return nil, result.Error
+	}
+	return cats, nil
+}
+
+// validCatID matches IDs that are safe to use in URLs.
+var validCatID = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// CreateCat adds a new cat to the database.
+func CreateCat(tx *gorm.DB, c Cat) error {
+	if !validCatID.MatchString(string(c.ID)) {
+		return fmt.Errorf("cat ID %q must consist of lowercase letters, digits and dashes", c.ID)
+	}
+	if !c.Noise.Valid() {
+		return fmt.Errorf("unknown noise kind %q", c.Noise)
+	}
+	if !c.Model.Valid() {
+		return fmt.Errorf("unknown mood model %q", c.Model)
+	}
+	if err := c.Personality.Validate(); err != nil {
+		return err
+	}
+	if c.Name == "" {
+		c.Name = c.ID.Name()
+	}
+	return tx.Create(&c).Error
+}
+
+// RenameCat changes the human-readable name of the cat.
+func RenameCat(tx *gorm.DB, id CatID, name string) error {
+	result := tx.Model(Cat{ID: id}).Update("name", name)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != 1 {
+		return fmt.Errorf("updated %d rows: %w", result.RowsAffected, gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+// SetMoodModel changes the model that determines the cat's mood.
+func SetMoodModel(tx *gorm.DB, id CatID, model MoodModel) error {
+	if !model.Valid() {
+		return fmt.Errorf("unknown mood model %q", model)
+	}
+	result := tx.Model(Cat{ID: id}).Update("model", model)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != 1 {
+		return fmt.Errorf("updated %d rows: %w", result.RowsAffected, gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+// SetPersonality changes the cat's personality.
+func SetPersonality(tx *gorm.DB, id CatID, p Personality) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	result := tx.Model(Cat{ID: id}).Select(
+		"personality_clinginess", "personality_patience", "personality_moodiness", "personality_sleepiness",
+	).Updates(Cat{Personality: p})
 	if result.Error != nil {
 		return result.Error
 	}
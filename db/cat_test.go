@@ -6,7 +6,6 @@ import (
 	"time"
 
 	"github.com/google/go-cmp/cmp"
-	"github.com/nevkontakte/pat/chrono/chronotest"
 	"github.com/nevkontakte/pat/db/dbtest"
 	"gorm.io/gorm"
 )
@@ -76,9 +75,45 @@ func TestCatByID(t *testing.T) {
 	})
 }
 
+func TestCreateAndRenameCat(t *testing.T) {
+	tx := dbtest.InMemory(t)
+	tx.AutoMigrate(Cat{})
+
+	for _, id := range []CatID{"", "Black", "black cat", "-black", "black/../red"} {
+		if err := CreateCat(tx, Cat{ID: id}); err == nil {
+			t.Errorf("Got: CreateCat(%q) succeeded. Want: error for invalid ID.", id)
+		}
+	}
+
+	if err := CreateCat(tx, Cat{ID: "red"}); err != nil {
+		t.Fatalf("Got: CreateCat() returned error: %s. Want: no error.", err)
+	}
+	if err := CreateCat(tx, Cat{ID: "black", Name: "Captain Black"}); err != nil {
+		t.Fatalf("Got: CreateCat() returned error: %s. Want: no error.", err)
+	}
+	if err := CreateCat(tx, Cat{ID: "black"}); err == nil {
+		t.Errorf("Got: CreateCat() with a duplicate ID succeeded. Want: error.")
+	}
+	if err := RenameCat(tx, "red", "Loaf"); err != nil {
+		t.Fatalf("Got: RenameCat() returned error: %s. Want: no error.", err)
+	}
+	if err := RenameCat(tx, "stray", "Stray"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Got: RenameCat() of a missing cat returned error: %v. Want: %v.", err, gorm.ErrRecordNotFound)
+	}
+
+	got, err := Cats(tx)
+	if err != nil {
+		t.Fatalf("Got: Cats() returned error: %s. Want: no error.", err)
+	}
+	want := []Cat{{ID: "black", Name: "Captain Black"}, {ID: "red", Name: "Loaf"}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Cats() returned diff (-want,+got):\n%s", diff)
+	}
+}
+
 func TestCat_Mood(t *testing.T) {
+	t.Parallel()
 	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
-	chronotest.OverrideNow(t, now)
 
 	// generalTestCat is used for most mood calculations, its ID affects noise generation.
 	generalTestCat := Cat{ID: "testcat", Name: "Test Cat Moods"}
@@ -137,7 +172,7 @@ func TestCat_Mood(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			catToTest := tc.cat
 			catToTest.LatestPat = tc.latestPat
-			mood := catToTest.Mood()
+			mood := catToTest.MoodAt(now)
 			if mood != tc.expectedMood {
 				t.Errorf("For cat ID '%s', LatestPat %v (sincePat %v), expected mood %s, but got %s.",
 					tc.cat.ID, tc.latestPat, now.Sub(tc.latestPat), tc.expectedMood, mood)
@@ -154,10 +189,7 @@ func TestCat_Mood(t *testing.T) {
 		moods := map[Mood]int{}
 		latest := Mood("")
 		for delay := 30 * time.Minute; delay <= 3*time.Hour+30*time.Minute; delay += time.Minute {
-			var current Mood
-			chronotest.OverrideScope(now.Add(delay), func() {
-				current = cat.Mood()
-			})
+			current := cat.MoodAt(now.Add(delay))
 
 			if current != latest {
 				moods[current]++
@@ -175,36 +207,141 @@ func TestCat_Mood(t *testing.T) {
 	})
 }
 
-func TestPat(t *testing.T) {
+func TestCat_Noise(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// Sweep the mood swing window, where the noise decides between happy and idle.
+	sweep := func(c Cat) []Mood {
+		var moods []Mood
+		for delay := 30 * time.Minute; delay <= 3*time.Hour+30*time.Minute; delay += time.Minute {
+			moods = append(moods, c.MoodAt(now.Add(delay)))
+		}
+		return moods
+	}
+
+	splotch := Cat{ID: SplotchID, LatestPat: now}
+	explicit := splotch
+	explicit.Noise = NoiseMd5
+	if diff := cmp.Diff(sweep(splotch), sweep(explicit)); diff != "" {
+		t.Errorf("Default noise differs from %q (-default +explicit):\n%s", NoiseMd5, diff)
+	}
+
+	hashed := splotch
+	hashed.Noise = NoiseHash
+	if cmp.Equal(sweep(splotch), sweep(hashed)) {
+		t.Errorf("Got: %q noise produced the same moods as %q. Want: different.", NoiseHash, NoiseMd5)
+	}
+
 	tx := dbtest.InMemory(t)
 	tx.AutoMigrate(Cat{})
+	if err := CreateCat(tx, Cat{ID: "red", Noise: "perlin"}); err == nil {
+		t.Errorf("Got: CreateCat() with unknown noise succeeded. Want: error.")
+	}
+}
 
-	t.Run("exists", func(t *testing.T) {
-		now := time.Now()
-		c := Cat{
-			ID:        "black",
-			Name:      "Captain Black",
-			Pats:      2,
-			LatestPat: now.Add(-time.Minute),
-		}
-		dbtest.Save(t, tx, c)
+func TestCat_Events(t *testing.T) {
+	t.Parallel()
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(7 * 24 * time.Hour)
 
-		if err := Pat(tx, c.ID); err != nil {
-			t.Fatalf("Got: Pat() returned error: %s. Want: no error.", err)
+	black := Cat{ID: "black"}
+	knocks := black.Events("knock", 6*time.Hour).Between(from, to)
+	if len(knocks) == 0 {
+		t.Fatalf("Got: no events over a week. Want: about 28.")
+	}
+	if diff := cmp.Diff(knocks, black.Events("knock", 6*time.Hour).Between(from, to)); diff != "" {
+		t.Errorf("Events() are not deterministic (-first +second):\n%s", diff)
+	}
+	if cmp.Equal(knocks, black.Events("zoomies", 6*time.Hour).Between(from, to)) {
+		t.Errorf("Got: different cues produced the same events. Want: different.")
+	}
+	if cmp.Equal(knocks, Cat{ID: "red"}.Events("knock", 6*time.Hour).Between(from, to)) {
+		t.Errorf("Got: different cats produced the same events. Want: different.")
+	}
+}
+
+func TestCat_MarkovMood(t *testing.T) {
+	t.Parallel()
+	patted := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	cat := Cat{ID: SplotchID, LatestPat: patted, Noise: NoiseHash, Model: MoodModelMarkov}
+
+	if got := cat.MoodAt(patted.Add(4 * time.Second)); got != MoodPat {
+		t.Errorf("Got: mood %q right after a pat. Want: %q.", got, MoodPat)
+	}
+	if got := cat.MoodAt(patted.Add(10 * time.Second)); got != MoodIdleHappy {
+		t.Errorf("Got: mood %q after the pat ended. Want: %q.", got, MoodIdleHappy)
+	}
+	if got := cat.MoodAt(patted.Add(impatienceDelay)); got != MoodImpatient {
+		t.Errorf("Got: mood %q after a week without pats. Want: %q.", got, MoodImpatient)
+	}
+
+	// Replaying the chain agrees with the mood at every step.
+	start := patted.Add(patDuration)
+	for at, mood := range cat.moodChain().All(MoodIdleHappy, start, start.Add(6*time.Hour)) {
+		if got := cat.MoodAt(at); got != mood {
+			t.Fatalf("Got: MoodAt(%v) = %q. Want: %q from the chain.", at, got, mood)
 		}
-		var got Cat
-		dbtest.First(t, tx, &got, "id = ?", c.ID)
-		if got.Pats != 3 {
-			t.Errorf("Got: recorded pats didn't increment: pats = %v. Want: 3.", got.Pats)
+	}
+
+	// The chain is replayed once per pat and personality.
+	if cat.trajectory() != cat.trajectory() {
+		t.Errorf("Got: the trajectory was replayed again. Want: cached.")
+	}
+	clingy := cat
+	clingy.Personality.Clinginess = 1
+	if clingy.trajectory() == cat.trajectory() {
+		t.Errorf("Got: the same trajectory for a different personality. Want: a separate one.")
+	}
+	for elapsed := 30 * time.Second; elapsed < 6*time.Hour; elapsed += 7 * time.Minute {
+		at := start.Add(elapsed)
+		if got, want := clingy.MoodAt(at), clingy.moodChain().At(MoodIdleHappy, start, at); got != want {
+			t.Fatalf("Got: MoodAt(%v) = %q. Want: %q from the chain.", at, got, want)
 		}
-		if got.LatestPat.Before(now) {
-			t.Errorf("Got: latest pat time didn't get updated: %v. Want: at or after %v.", got.LatestPat, now)
+	}
+
+	// Count moods over many cats, which were patted at different times.
+	moodsAt := func(elapsed time.Duration) map[Mood]int {
+		moods := map[Mood]int{}
+		for i := range 200 {
+			c := cat
+			c.LatestPat = patted.Add(time.Duration(i) * 7 * time.Minute)
+			moods[c.MoodAt(c.LatestPat.Add(elapsed))]++
 		}
-	})
+		return moods
+	}
+	if moods := moodsAt(10 * time.Minute); moods[MoodIdleHappy] < 100 {
+		t.Errorf("Got: moods %v 10 minutes after a pat. Want: mostly happy.", moods)
+	}
+	if moods := moodsAt(24 * time.Hour); moods[MoodIdle] < 150 || moods[MoodImpatient] > 0 {
+		t.Errorf("Got: moods %v a day after a pat. Want: mostly idle, never impatient.", moods)
+	}
+	if moods := moodsAt(6*24*time.Hour + 23*time.Hour); moods[MoodImpatient] < 100 {
+		t.Errorf("Got: moods %v almost a week after a pat. Want: mostly impatient.", moods)
+	}
+}
+
+func TestCat_MoodModel(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	classic := Cat{ID: SplotchID, LatestPat: now}
+	markov := classic
+	markov.Model = MoodModelMarkov
 
-	t.Run("doesn't exist", func(t *testing.T) {
-		if err := Pat(tx, "stray"); !errors.Is(err, gorm.ErrRecordNotFound) {
-			t.Errorf("Got: Pat() returned error: %s. Want: %s.", err, gorm.ErrRecordNotFound)
+	differ := false
+	for delay := 30 * time.Minute; delay <= 6*time.Hour; delay += time.Minute {
+		if classic.MoodAt(now.Add(delay)) != markov.MoodAt(now.Add(delay)) {
+			differ = true
+			break
 		}
-	})
+	}
+	if !differ {
+		t.Errorf("Got: classic and Markov models produced the same moods. Want: different.")
+	}
+
+	tx := dbtest.InMemory(t)
+	tx.AutoMigrate(Cat{})
+	if err := CreateCat(tx, Cat{ID: "red", Model: "neural"}); err == nil {
+		t.Errorf("Got: CreateCat() with unknown mood model succeeded. Want: error.")
+	}
 }
@@ -2,6 +2,7 @@
 package dbtest
 
 import (
+	"path/filepath"
 	"testing"
 
 	"gorm.io/driver/sqlite"
@@ -28,6 +29,27 @@ func InMemory(t *testing.T) *gorm.DB {
 	return dbconn
 }
 
+// OnDisk creates a disposable SQLite database in a temporary file.
+//
+// Unlike InMemory, the database is shared by all connections of the pool, so
+// it suits tests of concurrent transactions.
+func OnDisk(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_busy_timeout=10000"
+	dbconn, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create SQLite database: %s", err)
+	}
+	t.Cleanup(func() {
+		sqlDB, err := dbconn.DB()
+		if err != nil { // Should never happen.
+			t.Fatalf("Failed to get underlying *sql.DB: %s", err)
+		}
+		sqlDB.Close()
+	})
+	return dbconn
+}
+
 // First calls tx.First() and fails the test if it fails.
 func First(t *testing.T, tx *gorm.DB, dest any, conds ...any) {
 	t.Helper()
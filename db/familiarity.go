@@ -0,0 +1,80 @@
+package db
+
+import (
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// familiarityGain is the share of the remaining way to full familiarity a
+	// visitor makes with each pat.
+	familiarityGain = 0.15
+	// familiarityHalfLife is how long it takes the cat to forget half of
+	// what it knows about an absent visitor.
+	familiarityHalfLife = 7 * 24 * time.Hour
+	// familiarityOccasion is the time within which repeated pats count as a
+	// single one, so that visitors get familiar by coming back rather than by
+	// clicking a lot.
+	familiarityOccasion = time.Hour
+	// familiarityHorizon is how far back pats are taken into account. Older
+	// pats have decayed to nothing anyway.
+	familiarityHorizon = 10 * familiarityHalfLife
+)
+
+// Familiarity is how well a cat knows a visitor, from 0 for a stranger to 1.
+type Familiarity float64
+
+// Recognizes returns true if the cat knows the visitor well enough to
+// recognize them.
+func (f Familiarity) Recognizes() bool { return f >= 0.3 }
+
+// Adores returns true if the visitor is one of the cat's closest friends.
+func (f Familiarity) Adores() bool { return f >= 0.5 }
+
+// familiarity computes how well a cat knows a visitor at the given time from
+// the times of their pats, in chronological order.
+//
+// Each pat takes the visitor a step closer to full familiarity, while the
+// familiarity decays exponentially in between.
+func familiarity(pats []time.Time, now time.Time) Familiarity {
+	var f float64
+	var latest time.Time
+	for _, at := range pats {
+		if at.After(now) {
+			break
+		}
+		if !latest.IsZero() && at.Sub(latest) < familiarityOccasion {
+			continue
+		}
+		f = decay(f, at.Sub(latest))
+		f += (1 - f) * familiarityGain
+		latest = at
+	}
+	if latest.IsZero() {
+		return 0
+	}
+	return Familiarity(decay(f, now.Sub(latest)))
+}
+
+// decay returns the familiarity left after the visitor has been absent for
+// the given time.
+func decay(f float64, absent time.Duration) float64 {
+	return f * math.Exp2(-float64(absent)/float64(familiarityHalfLife))
+}
+
+// VisitorFamiliarity returns how well the cat knows the identity at the given
+// time, computed from the identity's pats in the journal.
+func VisitorFamiliarity(tx *gorm.DB, cat CatID, identity uint64, now time.Time) (Familiarity, error) {
+	var pats []time.Time
+	result := tx.Model(&Journal{}).
+		Where("cat_id = ? AND type = ? AND created_at > ? AND created_at <= ?", cat, EventPat, now.Add(-familiarityHorizon), now).
+		Where("identity_id = ?", identity).
+		Order("created_at").
+		Pluck("created_at", &pats)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return familiarity(pats, now), nil
+}
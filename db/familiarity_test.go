@@ -0,0 +1,90 @@
+package db
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/nevkontakte/pat/db/dbtest"
+)
+
+func TestFamiliarity(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	daily := func(n int) []time.Time {
+		var pats []time.Time
+		for i := range n {
+			pats = append(pats, start.AddDate(0, 0, i))
+		}
+		return pats
+	}
+
+	if got := familiarity(nil, start); got != 0 {
+		t.Errorf("Got: familiarity without pats = %v. Want: 0.", got)
+	}
+	if got, want := familiarity(daily(1), start), Familiarity(familiarityGain); got != want {
+		t.Errorf("Got: familiarity after a pat = %v. Want: %v.", got, want)
+	}
+
+	// Clicking a lot doesn't help, coming back does.
+	burst := []time.Time{start, start.Add(time.Second), start.Add(time.Minute), start.Add(59 * time.Minute)}
+	if got, want := familiarity(burst, start.Add(time.Hour)), familiarity(daily(1), start.Add(time.Hour)); got != want {
+		t.Errorf("Got: familiarity after a burst of pats = %v. Want: %v, same as a single pat.", got, want)
+	}
+	var prev Familiarity
+	for n := 1; n <= 10; n++ {
+		now := start.AddDate(0, 0, n-1)
+		got := familiarity(daily(n), now)
+		if got <= prev || got >= 1 {
+			t.Errorf("Got: familiarity after %d daily pats = %v. Want: between %v and 1.", n, got, prev)
+		}
+		prev = got
+	}
+	if !prev.Recognizes() || !prev.Adores() {
+		t.Errorf("Got: familiarity after 10 daily pats = %v. Want: the cat adores the visitor.", prev)
+	}
+	if f := familiarity(daily(2), start.AddDate(0, 0, 1)); f.Recognizes() {
+		t.Errorf("Got: the cat recognizes a visitor after 2 pats (%v). Want: not yet.", f)
+	}
+
+	// Absence decays familiarity.
+	latest := start.AddDate(0, 0, 9)
+	if got, want := familiarity(daily(10), latest.Add(familiarityHalfLife)), prev/2; math.Abs(float64(got-want)) > 1e-9 {
+		t.Errorf("Got: familiarity after a half-life of absence = %v. Want: %v.", got, want)
+	}
+	if f := familiarity(daily(10), latest.AddDate(0, 3, 0)); f.Recognizes() {
+		t.Errorf("Got: the cat recognizes a visitor absent for 3 months (%v). Want: forgotten.", f)
+	}
+	// Future pats don't count.
+	if got, want := familiarity(daily(10), start), familiarity(daily(1), start); got != want {
+		t.Errorf("Got: familiarity with future pats = %v. Want: %v.", got, want)
+	}
+}
+
+func TestVisitorFamiliarity(t *testing.T) {
+	tx := dbtest.InMemory(t)
+	tx.AutoMigrate(Cat{}, Journal{})
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	var pats []time.Time
+	for i := range 5 {
+		at := start.AddDate(0, 0, i)
+		pats = append(pats, at)
+		dbtest.Save(t, tx, &Journal{CreatedAt: at, Visitor: &Visitor{IdentityID: 1}, CatID: SplotchID, Event: Event{Type: EventPat}})
+	}
+	// Records that don't count: other visitors, cats and events.
+	dbtest.Save(t, tx, &Journal{CreatedAt: start, Visitor: &Visitor{IdentityID: 2}, CatID: SplotchID, Event: Event{Type: EventPat}})
+	dbtest.Save(t, tx, &Journal{CreatedAt: start.Add(2 * time.Hour), Visitor: &Visitor{IdentityID: 1}, CatID: "black", Event: Event{Type: EventPat}})
+	dbtest.Save(t, tx, &Journal{CreatedAt: start.Add(2 * time.Hour), Visitor: &Visitor{IdentityID: 1}, CatID: SplotchID, Event: Event{Type: EventFeed}})
+
+	now := start.AddDate(0, 0, 6)
+	got, err := VisitorFamiliarity(tx, SplotchID, 1, now)
+	if err != nil {
+		t.Fatalf("Got: VisitorFamiliarity() returned error: %s. Want: no error.", err)
+	}
+	if want := familiarity(pats, now); math.Abs(float64(got-want)) > 1e-9 {
+		t.Errorf("Got: VisitorFamiliarity() = %v. Want: %v.", got, want)
+	}
+	if got, err := VisitorFamiliarity(tx, SplotchID, 3, now); err != nil || got != 0 {
+		t.Errorf("Got: VisitorFamiliarity() of a stranger = %v, %v. Want: 0, no error.", got, err)
+	}
+}
@@ -0,0 +1,94 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// maxNickname is the maximum length of a nickname in characters.
+const maxNickname = 32
+
+// Identity is a visitor who introduced themselves with a nickname.
+//
+// Visitors keep the identity ID in a signed cookie, so the identity lasts as
+// long as the cookie. Nicknames aren't unique.
+type Identity struct {
+	ID        uint64 `gorm:"primaryKey"`
+	CreatedAt time.Time
+	Nickname  string
+	// Hidden identities opted out of the leaderboard.
+	Hidden bool
+}
+
+// NormalizeNickname trims the nickname and checks that it's suitable for
+// display to other visitors.
+func NormalizeNickname(nickname string) (string, error) {
+	nickname = strings.Join(strings.Fields(nickname), " ")
+	if nickname == "" {
+		return "", fmt.Errorf("nickname must not be empty")
+	}
+	if utf8.RuneCountInString(nickname) > maxNickname {
+		return "", fmt.Errorf("nickname must be at most %d characters long", maxNickname)
+	}
+	for _, r := range nickname {
+		if !unicode.IsPrint(r) {
+			return "", fmt.Errorf("nickname must not contain special characters")
+		}
+	}
+	return nickname, nil
+}
+
+// CreateIdentity stores a new identity with the nickname.
+func CreateIdentity(tx *gorm.DB, nickname string) (Identity, error) {
+	nickname, err := NormalizeNickname(nickname)
+	if err != nil {
+		return Identity{}, err
+	}
+	i := Identity{Nickname: nickname}
+	if result := tx.Create(&i); result.Error != nil {
+		return Identity{}, result.Error
+	}
+	return i, nil
+}
+
+// IdentityByID queries the identity with the given ID.
+func IdentityByID(tx *gorm.DB, id uint64) (Identity, error) {
+	var i Identity
+	if result := tx.First(&i, id); result.Error != nil {
+		return Identity{}, result.Error
+	}
+	return i, nil
+}
+
+// RenameIdentity changes the nickname of the identity.
+func RenameIdentity(tx *gorm.DB, id uint64, nickname string) error {
+	nickname, err := NormalizeNickname(nickname)
+	if err != nil {
+		return err
+	}
+	result := tx.Model(&Identity{ID: id}).Update("nickname", nickname)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != 1 {
+		return fmt.Errorf("updated %d rows: %w", result.RowsAffected, gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+// SetIdentityHidden opts the identity out of the leaderboard, or back in.
+func SetIdentityHidden(tx *gorm.DB, id uint64, hidden bool) error {
+	result := tx.Model(&Identity{ID: id}).Update("hidden", hidden)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != 1 {
+		return fmt.Errorf("updated %d rows: %w", result.RowsAffected, gorm.ErrRecordNotFound)
+	}
+	return nil
+}
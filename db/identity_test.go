@@ -0,0 +1,49 @@
+package db
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/nevkontakte/pat/db/dbtest"
+	"gorm.io/gorm"
+)
+
+func TestNormalizeNickname(t *testing.T) {
+	valid := map[string]string{
+		"Alice":             "Alice",
+		"  Bob   the  cat ": "Bob the cat",
+		"Кошатник":          "Кошатник",
+	}
+	for in, want := range valid {
+		got, err := NormalizeNickname(in)
+		if err != nil || got != want {
+			t.Errorf("Got: NormalizeNickname(%q) = %q, %v. Want: %q, no error.", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "   ", strings.Repeat("x", maxNickname+1), "bell\a"} {
+		if got, err := NormalizeNickname(in); err == nil {
+			t.Errorf("Got: NormalizeNickname(%q) = %q. Want: error.", in, got)
+		}
+	}
+}
+
+func TestIdentity(t *testing.T) {
+	tx := dbtest.InMemory(t)
+	tx.AutoMigrate(&Identity{})
+
+	created, err := CreateIdentity(tx, " Alice ")
+	if err != nil {
+		t.Fatalf("Got: CreateIdentity() returned error: %s. Want: no error.", err)
+	}
+	if err := RenameIdentity(tx, created.ID, "Alice the Great"); err != nil {
+		t.Fatalf("Got: RenameIdentity() returned error: %s. Want: no error.", err)
+	}
+	got, err := IdentityByID(tx, created.ID)
+	if err != nil || got.Nickname != "Alice the Great" {
+		t.Errorf("Got: IdentityByID() = %+v, %v. Want: renamed identity.", got, err)
+	}
+	if err := RenameIdentity(tx, created.ID+1, "Bob"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Got: RenameIdentity() of a missing identity returned error: %v. Want: %v.", err, gorm.ErrRecordNotFound)
+	}
+}
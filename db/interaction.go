@@ -0,0 +1,215 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nevkontakte/pat/chrono"
+	"gorm.io/gorm"
+)
+
+// Interaction is something a visitor can do to a cat.
+//
+// Interactions are declarative: the web server derives routes, buttons and
+// reactions from the list of Interactions, and Interact applies the effects,
+// so adding an interaction only takes a new entry in the list.
+type Interaction struct {
+	ID    string // Unique URL-safe identifier, used as the route.
+	Label string // Button label. Empty if the interaction has no button.
+	Event EventType
+	// Cooldown is the minimum time between interactions of this kind with the
+	// same cat.
+	Cooldown time.Duration
+	// TooSoon is the message shown during the cooldown, with the cat's name as
+	// the only argument.
+	TooSoon string
+	// Reaction is the mood shown to the visitor after the interaction.
+	Reaction Mood
+	// Done describes what happened to the cat, to summarize repeated
+	// interactions, e.g. "patted" in "Splotch was patted 12 times".
+	Done string
+
+	// Effects on the cat's state.
+	Pat   bool   // Counts as a pat and cheers the cat up.
+	Cheer bool   // Cheers the cat up as if patted, without counting a pat.
+	Needs []Need // Needs the interaction satisfies.
+}
+
+// Interactions lists all interactions in display order.
+var Interactions = []Interaction{
+	{
+		ID:       "pat",
+		Done:     "patted",
+		Event:    EventPat,
+		Reaction: MoodPat,
+		Pat:      true,
+	}, {
+		ID:       "feed",
+		Done:     "fed",
+		Label:    "Feed",
+		Event:    EventFeed,
+		Cooldown: time.Hour,
+		TooSoon:  "%s isn't hungry yet.",
+		Reaction: MoodIdleHappy,
+		Needs:    []Need{NeedFood},
+	}, {
+		ID:       "play",
+		Done:     "played with",
+		Label:    "Play with a toy",
+		Event:    EventPlay,
+		Cooldown: 10 * time.Minute,
+		TooSoon:  "%s is catching their breath.",
+		Reaction: MoodIdleHappy,
+		Needs:    []Need{NeedPlay},
+	}, {
+		ID:       "brush",
+		Done:     "brushed",
+		Label:    "Brush",
+		Event:    EventBrush,
+		Cooldown: 30 * time.Minute,
+		TooSoon:  "%s's fur is already perfect.",
+		Reaction: MoodIdleHappy,
+		Cheer:    true,
+	}, {
+		ID:       "treat",
+		Done:     "given a treat",
+		Label:    "Give a treat",
+		Event:    EventTreat,
+		Cooldown: 2 * time.Hour,
+		TooSoon:  "%s had a treat not long ago.",
+		Reaction: MoodPat,
+		Cheer:    true,
+	}, {
+		ID:       "sleep",
+		Done:     "tucked in for a nap",
+		Label:    "Let nap",
+		Event:    EventSleep,
+		Cooldown: 4 * time.Hour,
+		TooSoon:  "%s isn't sleepy.",
+		Reaction: MoodIdleBlink,
+		Needs:    []Need{NeedSleep},
+	},
+}
+
+// InteractionByID returns the interaction with the given ID.
+func InteractionByID(id string) (Interaction, bool) {
+	for _, i := range Interactions {
+		if i.ID == id {
+			return i, true
+		}
+	}
+	return Interaction{}, false
+}
+
+// InteractionByEvent returns the interaction recorded with the event type.
+func InteractionByEvent(event EventType) (Interaction, bool) {
+	for _, i := range Interactions {
+		if i.Event == event {
+			return i, true
+		}
+	}
+	return Interaction{}, false
+}
+
+// ErrTooSoon is returned by Interact during the interaction's cooldown.
+var ErrTooSoon = errors.New("too soon since the previous interaction")
+
+// Interact applies the interaction's effects to the cat at the current time and
+// records it in the journal, attributed to the visitor. The description depends
+// on the cat's mood before the interaction.
+//
+// The cooldown is checked against the journal, so removing a journal record
+// also lifts the cooldown it caused.
+//
+// Pats may reach milestones, which are recorded in the same transaction and
+// returned.
+func Interact(tx *gorm.DB, clock chrono.Clock, id CatID, i Interaction, visitor *Visitor) ([]Milestone, error) {
+	now := clock.Now()
+	var reached []Milestone
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		// Without the lock, concurrent interactions could all pass the cooldown
+		// check before any of them is recorded.
+		if err := lockCat(tx, id); err != nil {
+			return err
+		}
+		cat, err := CatByID(tx, id)
+		if err != nil {
+			return err
+		}
+		if i.Cooldown > 0 {
+			var recent int64
+			result := tx.Model(&Journal{}).
+				Where("cat_id = ? AND type = ? AND created_at > ?", id, i.Event, now.Add(-i.Cooldown)).
+				Count(&recent)
+			if result.Error != nil {
+				return result.Error
+			}
+			if recent > 0 {
+				return ErrTooSoon
+			}
+		}
+
+		prev := cat.MoodAt(now)
+		updates := map[string]any{}
+		if i.Pat {
+			updates["pats"] = gorm.Expr("pats + 1")
+			if cat.FirstPat.IsZero() {
+				first, err := firstPat(tx, id, now)
+				if err != nil {
+					return err
+				}
+				updates["first_pat"] = first
+			}
+		}
+		if i.Pat || i.Cheer {
+			updates["latest_pat"] = now
+		}
+		for _, need := range i.Needs {
+			updates[need.column()] = now
+		}
+		if len(updates) > 0 {
+			result := tx.Model(Cat{ID: id}).Updates(updates)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected != 1 {
+				return fmt.Errorf("updated %d rows: %w", result.RowsAffected, gorm.ErrRecordNotFound)
+			}
+		}
+
+		description := cat.Describe(i.Event, now, visitor)
+		if len(updates) > 0 {
+			// The row is locked until the transaction ends, so the pat count is
+			// exactly the one this pat reached.
+			if cat, err = CatByID(tx, id); err != nil {
+				return err
+			}
+		}
+		record := Journal{
+			CreatedAt: now,
+			Visitor:   visitor,
+			CatID:     id,
+			Event: Event{
+				Type:        i.Event,
+				Description: description,
+				PrevMood:    prev,
+				Mood:        cat.MoodAt(now),
+			},
+		}
+		if err := tx.Create(&record).Error; err != nil {
+			return err
+		}
+		if err := EnqueueDeliveries(tx, record); err != nil {
+			return err
+		}
+		if i.Pat {
+			reached, err = recordMilestones(tx, cat, now, visitor)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reached, nil
+}
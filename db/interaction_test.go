@@ -0,0 +1,187 @@
+package db
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nevkontakte/pat/chrono/chronotest"
+	"github.com/nevkontakte/pat/db/dbtest"
+	"gorm.io/gorm"
+)
+
+func TestInteractions(t *testing.T) {
+	ids := map[string]bool{}
+	events := map[EventType]bool{}
+	for _, i := range Interactions {
+		if !validCatID.MatchString(i.ID) || ids[i.ID] {
+			t.Errorf("Got: interaction ID %q. Want: unique and URL-safe.", i.ID)
+		}
+		ids[i.ID] = true
+		if _, known := eventNames[i.Event]; events[i.Event] || !known {
+			t.Errorf("Got: interaction %q event %v. Want: a unique known event.", i.ID, i.Event)
+		}
+		events[i.Event] = true
+		if i.Cooldown > 0 && i.TooSoon == "" {
+			t.Errorf("Got: interaction %q has a cooldown without a message. Want: a message.", i.ID)
+		}
+		for _, need := range i.Needs {
+			if !need.Valid() {
+				t.Errorf("Got: interaction %q satisfies unknown need %q. Want: known needs.", i.ID, need)
+			}
+		}
+		if got, ok := InteractionByID(i.ID); !ok || got.ID != i.ID {
+			t.Errorf("Got: InteractionByID(%q) = %+v, %v. Want: the interaction.", i.ID, got, ok)
+		}
+	}
+	if _, ok := InteractionByID("bathe"); ok {
+		t.Errorf("Got: InteractionByID() found an unknown interaction. Want: not found.")
+	}
+}
+
+func TestInteract(t *testing.T) {
+	tx := dbtest.InMemory(t)
+	tx.AutoMigrate(Cat{}, Journal{}, Webhook{}, Delivery{})
+	start := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	dbtest.Save(t, tx, &Cat{ID: SplotchID, Name: "Splotch", Pats: 1, LatestPat: start})
+	clock := chronotest.NewClock(start)
+	visitor := &Visitor{Agent: "test"}
+
+	interact := func(id string) error {
+		t.Helper()
+		i, ok := InteractionByID(id)
+		if !ok {
+			t.Fatalf("InteractionByID(%q) not found", id)
+		}
+		_, err := Interact(tx, clock, SplotchID, i, visitor)
+		return err
+	}
+
+	for _, id := range []string{"pat", "pat", "feed", "play", "sleep"} {
+		clock.Advance(time.Minute)
+		if err := interact(id); err != nil {
+			t.Fatalf("Got: Interact(%q) returned error: %s. Want: no error.", id, err)
+		}
+	}
+	cat, err := CatByID(tx, SplotchID)
+	if err != nil {
+		t.Fatalf("Got: CatByID() returned error: %s. Want: no error.", err)
+	}
+	if cat.Pats != 3 || !cat.LatestPat.Equal(start.Add(2*time.Minute)) {
+		t.Errorf("Got: %d pats, latest at %v. Want: 3 pats, latest at %v.", cat.Pats, cat.LatestPat, start.Add(2*time.Minute))
+	}
+	for i, need := range []Need{NeedFood, NeedPlay, NeedSleep} {
+		if want, got := start.Add(time.Duration(3+i)*time.Minute), *cat.Needs.at(need); !got.Equal(want) {
+			t.Errorf("Got: %s need satisfied at %v. Want: %v.", need, got, want)
+		}
+	}
+
+	t.Run("cooldown", func(t *testing.T) {
+		if err := interact("feed"); !errors.Is(err, ErrTooSoon) {
+			t.Errorf("Got: Interact(feed) during the cooldown returned error: %v. Want: %v.", err, ErrTooSoon)
+		}
+		clock.Advance(time.Hour)
+		if err := interact("feed"); err != nil {
+			t.Errorf("Got: Interact(feed) after the cooldown returned error: %s. Want: no error.", err)
+		}
+	})
+
+	t.Run("cheer", func(t *testing.T) {
+		if err := interact("brush"); err != nil {
+			t.Fatalf("Got: Interact(brush) returned error: %s. Want: no error.", err)
+		}
+		cat, _ := CatByID(tx, SplotchID)
+		if cat.Pats != 3 || !cat.LatestPat.Equal(clock.Now()) {
+			t.Errorf("Got: %d pats, latest at %v. Want: 3 pats, cheered up at %v.", cat.Pats, cat.LatestPat, clock.Now())
+		}
+	})
+
+	t.Run("missing cat", func(t *testing.T) {
+		i, _ := InteractionByID("pat")
+		if _, err := Interact(tx, clock, "stray", i, visitor); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("Got: Interact() with a missing cat returned error: %v. Want: %v.", err, gorm.ErrRecordNotFound)
+		}
+	})
+
+	var journal []Journal
+	tx.Order("id").Find(&journal)
+	var got []EventType
+	for _, j := range journal {
+		got = append(got, j.Event.Type)
+		if j.Visitor == nil || j.Visitor.Agent != "test" || j.CatID != SplotchID {
+			t.Errorf("Got: journal record %+v. Want: attributed to the visitor and Splotch.", j)
+		}
+	}
+	want := []EventType{EventPat, EventPat, EventFeed, EventPlay, EventSleep, EventFeed, EventBrush}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Journal events diff (-want,+got):\n%s", diff)
+	}
+}
+
+func TestInteract_Pat(t *testing.T) {
+	tx := dbtest.InMemory(t)
+	tx.AutoMigrate(Cat{}, Journal{}, Webhook{}, Delivery{})
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := Cat{
+		ID:        "black",
+		Name:      "Captain Black",
+		Pats:      2,
+		LatestPat: now.Add(-time.Minute),
+	}
+	dbtest.Save(t, tx, c)
+
+	pat, _ := InteractionByID("pat")
+	if _, err := Interact(tx, chronotest.NewClock(now), c.ID, pat, nil); err != nil {
+		t.Fatalf("Got: Interact(pat) returned error: %s. Want: no error.", err)
+	}
+	var got Cat
+	dbtest.First(t, tx, &got, "id = ?", c.ID)
+	if got.Pats != 3 {
+		t.Errorf("Got: recorded pats didn't increment: pats = %v. Want: 3.", got.Pats)
+	}
+	if !got.LatestPat.Equal(now) {
+		t.Errorf("Got: latest pat time didn't get updated: %v. Want: %v.", got.LatestPat, now)
+	}
+}
+
+func TestInteract_Concurrent(t *testing.T) {
+	tx := dbtest.OnDisk(t)
+	tx.AutoMigrate(Cat{}, Journal{}, Webhook{}, Delivery{})
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	dbtest.Save(t, tx, &Cat{ID: SplotchID, Name: "Splotch", LatestPat: now})
+	clock := chronotest.NewClock(now)
+	feed, _ := InteractionByID("feed")
+	// Let the other visitors catch up after the cooldown check.
+	tx.Callback().Query().After("gorm:query").Register("test:pause", func(db *gorm.DB) {
+		if db.Statement.Table == "journals" {
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+
+	const visitors = 8
+	errs := make([]error, visitors)
+	var wg sync.WaitGroup
+	for i := range visitors {
+		wg.Go(func() {
+			_, errs[i] = Interact(tx, clock, SplotchID, feed, &Visitor{Agent: "test"})
+		})
+	}
+	wg.Wait()
+
+	fed := 0
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			fed++
+		case !errors.Is(err, ErrTooSoon):
+			t.Errorf("Got: Interact() #%d returned error: %s. Want: no error or %v.", i, err, ErrTooSoon)
+		}
+	}
+	var records int64
+	tx.Model(&Journal{}).Where("type = ?", EventFeed).Count(&records)
+	if fed != 1 || records != 1 {
+		t.Errorf("Got: %d visitors fed the cat, %d journal records. Want: 1 and 1 within the cooldown.", fed, records)
+	}
+}
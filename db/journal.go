@@ -1,16 +1,20 @@
 package db
 
 import (
+	"fmt"
 	"net/netip"
+	"slices"
 	"time"
 
 	"github.com/labstack/echo/v5"
+	"github.com/nevkontakte/pat/narrative"
+	"gorm.io/gorm"
 )
 
 // Visitor represents information about a visitor.
 //
 // It doesn't uniquely identify the visitor, unless they volunteerely "introduce" themselves
-// (something we may implement in future).
+// with a nickname, see Identity.
 type Visitor struct {
 	// Visitor's IP address.
 	Addr Addr
@@ -18,12 +22,32 @@ type Visitor struct {
 	Agent string
 	// Referrer is the HTTP Referer header value.
 	Referrer string
+	// IdentityID links the visitor to the Identity they introduced themselves
+	// with. Zero for anonymous visitors.
+	IdentityID uint64 `gorm:"index"`
+	// Nickname of the identity at the time of the event, used for journal
+	// descriptions. Not stored, since the identity may be renamed later.
+	Nickname string `gorm:"-"`
+}
+
+// Name returns how the visitor is referred to in journal descriptions: by
+// nickname if they introduced themselves, otherwise by their browser.
+func (v *Visitor) Name() string {
+	switch {
+	case v == nil:
+		return "a visitor"
+	case v.Nickname != "":
+		return v.Nickname
+	default:
+		return narrative.Visitor(v.Agent)
+	}
 }
 
 // CurrentVisitor populates the Visitor instance from the request.
 //
 // Visitor identification is best-effort, if some aspects can't be identified,
-// we just leave them empty.
+// we just leave them empty. The address comes from c.RealIP(), so forwarding
+// headers are only honored if the server's echo.IPExtractor trusts them.
 func CurrentVisitor(c *echo.Context) Visitor {
 	v := Visitor{
 		Agent:    c.Request().UserAgent(),
@@ -39,30 +63,232 @@ func CurrentVisitor(c *echo.Context) Visitor {
 type EventType uint16
 
 const (
-	EventUnknown EventType = iota // Unknown, default value. Should never happen.
-	EventVisit                    // The cat was visited without explicit interaction.
-	EventPat                      // The cat received a pat.
+	EventUnknown        EventType = iota // Unknown, default value. Should never happen.
+	EventVisit                           // The cat was visited without explicit interaction.
+	EventPat                             // The cat received a pat.
+	EventAdminInvited                    // An owner invited a new admin.
+	EventAdminJoined                     // An invited admin accepted the invite.
+	EventAdminRemoved                    // An owner removed an admin account.
+	EventTokenCreated                    // An admin created an API token.
+	EventTokenRevoked                    // An admin revoked an API token.
+	EventCatCreated                      // An admin added a new cat.
+	EventCatUpdated                      // An admin changed cat details.
+	EventJournalRemoved                  // A moderator removed a journal record.
+	EventFeed                            // A visitor fed the cat.
+	EventPlay                            // A visitor played with the cat.
+	EventSleep                           // A visitor let the cat sleep.
+	EventBrush                           // A visitor brushed the cat.
+	EventTreat                           // A visitor gave the cat a treat.
+	EventNapped                          // The cat took a nap on its own.
+	EventChasedFly                       // The cat chased a fly.
+	EventStaredAtWall                    // The cat stared at the wall.
+	EventZoomies                         // The cat got the zoomies.
+	EventWebhookCreated                  // An owner subscribed a webhook to events.
+	EventWebhookDeleted                  // An owner removed a webhook subscription.
+	EventMoodChanged                     // The cat's mood changed on its own.
+	EventMilestone                       // A pat reached a milestone, credited to the visitor.
 )
 
+var eventNames = map[EventType]string{
+	EventUnknown:        "unknown",
+	EventVisit:          "visit",
+	EventPat:            "pat",
+	EventAdminInvited:   "admin_invited",
+	EventAdminJoined:    "admin_joined",
+	EventAdminRemoved:   "admin_removed",
+	EventTokenCreated:   "token_created",
+	EventTokenRevoked:   "token_revoked",
+	EventCatCreated:     "cat_created",
+	EventCatUpdated:     "cat_updated",
+	EventJournalRemoved: "journal_removed",
+	EventFeed:           "feed",
+	EventPlay:           "play",
+	EventSleep:          "sleep",
+	EventBrush:          "brush",
+	EventTreat:          "treat",
+	EventNapped:         "napped",
+	EventChasedFly:      "chased_fly",
+	EventStaredAtWall:   "stared_at_wall",
+	EventZoomies:        "zoomies",
+	EventWebhookCreated: "webhook_created",
+	EventWebhookDeleted: "webhook_deleted",
+	EventMoodChanged:    "mood_changed",
+	EventMilestone:      "milestone",
+}
+
+// publicEvents are events that happen to cats and are safe to show to anyone.
+var publicEvents = []EventType{
+	EventPat, EventFeed, EventPlay, EventBrush, EventTreat, EventSleep,
+	EventNapped, EventChasedFly, EventStaredAtWall, EventZoomies,
+	EventMoodChanged, EventMilestone,
+}
+
+// Public returns true for events that may be shown to the public.
+func (t EventType) Public() bool {
+	return slices.Contains(publicEvents, t)
+}
+
+// String returns a stable machine-readable name of the event type.
+func (t EventType) String() string {
+	if name, ok := eventNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("event_%d", uint16(t))
+}
+
+// ParseEventType returns the event type with the given String() name.
+func ParseEventType(name string) (EventType, bool) {
+	for t, n := range eventNames {
+		if n == name {
+			return t, true
+		}
+	}
+	return EventUnknown, false
+}
+
 // Event describes a game world event.
 type Event struct {
-	Type        EventType
-	Description string // Human-readable description.
+	Type        EventType `gorm:"index:idx_journals_cat_type,priority:2"`
+	Description string    // Human-readable description.
+	// PrevMood and Mood are the cat's moods right before and right after the
+	// event. Empty if the event doesn't track moods.
+	PrevMood Mood
+	Mood     Mood
+}
+
+// MoodChanged returns true if the event is known to have changed the cat's
+// mood.
+func (e Event) MoodChanged() bool {
+	return e.PrevMood != "" && e.Mood != "" && e.PrevMood != e.Mood
 }
 
 // Journal records a notable event that happened in the game world, which could be autonomous events or user interactions.
 type Journal struct {
 	ID uint64 `gorm:"primaryKey"`
 	// CreatedAt contains journal record creation time. Auto-populated by Gorm.
-	CreatedAt time.Time
+	CreatedAt time.Time `gorm:"index:idx_journals_cat_type,priority:3"`
 
 	// Visitor that triggered event. Nil if no visitor was involved.
 	Visitor *Visitor `gorm:"embedded"`
+	// Admin is the account that performed an admin action. Empty for events
+	// not caused by an admin.
+	Admin string `gorm:"index"`
 
 	// CatID identifies which cat the event happened to, most of the time this would be Splotch.
-	CatID CatID
+	// Empty for events not related to any cat, such as admin account management.
+	CatID CatID `gorm:"index:idx_journals_cat_type,priority:1"`
 	Cat   Cat
 
 	// Event metadata that the journal record represents.
 	Event Event `gorm:"embedded"`
+
+	// Key identifies records that several replicas may try to add for the same
+	// event, such as autonomous events, so that it's only recorded once. Nil
+	// for other records.
+	Key *string `gorm:"uniqueIndex"`
+}
+
+// JournalAfter returns up to limit journal records with IDs greater than after,
+// in the order of IDs. If cat is not empty, only records about that cat are
+// returned.
+func JournalAfter(tx *gorm.DB, cat CatID, after uint64, limit int) ([]Journal, error) {
+	q := tx.Where("id > ?", after)
+	if cat != "" {
+		q = q.Where("cat_id = ?", cat)
+	}
+	var records []Journal
+	if result := q.Order("id").Limit(limit).Find(&records); result.Error != nil {
+		return nil, result.Error
+	}
+	return records, nil
+}
+
+// JournalBefore returns up to limit journal records with IDs less than before,
+// newest first. Zero before starts with the newest record. If cat is not empty,
+// only records about that cat are returned.
+func JournalBefore(tx *gorm.DB, cat CatID, before uint64, limit int) ([]Journal, error) {
+	q := tx
+	if before > 0 {
+		q = q.Where("id < ?", before)
+	}
+	if cat != "" {
+		q = q.Where("cat_id = ?", cat)
+	}
+	var records []Journal
+	if result := q.Order("id desc").Limit(limit).Find(&records); result.Error != nil {
+		return nil, result.Error
+	}
+	return records, nil
+}
+
+// PublicJournal returns up to limit journal records of public events about the
+// cat with IDs less than before, newest first. Zero before starts with the
+// newest record.
+func PublicJournal(tx *gorm.DB, cat CatID, before uint64, limit int) ([]Journal, error) {
+	return JournalBefore(tx.Where("type IN ?", publicEvents), cat, before, limit)
+}
+
+// NotableJournal returns up to limit journal records of public events about
+// the cat that aren't visitor interactions, newest first.
+func NotableJournal(tx *gorm.DB, cat CatID, limit int) ([]Journal, error) {
+	notable := slices.DeleteFunc(slices.Clone(publicEvents), func(t EventType) bool {
+		_, ok := InteractionByEvent(t)
+		return ok
+	})
+	return JournalBefore(tx.Where("type IN ?", notable), cat, 0, limit)
+}
+
+// PatHour is the number of pats the cat got within an hour.
+type PatHour struct {
+	Start  time.Time // Start of the hour.
+	Latest time.Time // Time of the latest pat within the hour.
+	Pats   int64
+}
+
+// HourlyPats returns up to limit hours when the cat was patted, newest first,
+// skipping hours with no pats since the given time.
+//
+// Each hour takes a couple of queries over the journal index, so that the cost
+// doesn't depend on the number of pats.
+func HourlyPats(tx *gorm.DB, cat CatID, since time.Time, limit int) ([]PatHour, error) {
+	var hours []PatHour
+	pats := func() *gorm.DB {
+		return tx.Model(&Journal{}).Where("cat_id = ? AND type = ?", cat, EventPat)
+	}
+	var before time.Time
+	for len(hours) < limit {
+		var latest []time.Time
+		q := pats().Where("created_at >= ?", since)
+		if !before.IsZero() {
+			q = q.Where("created_at < ?", before)
+		}
+		if result := q.Order("created_at desc").Limit(1).Pluck("created_at", &latest); result.Error != nil {
+			return nil, result.Error
+		}
+		if len(latest) == 0 {
+			break
+		}
+		h := PatHour{Start: latest[0].Truncate(time.Hour), Latest: latest[0]}
+		result := pats().
+			Where("created_at >= ? AND created_at <= ?", h.Start, h.Latest).
+			Count(&h.Pats)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		hours = append(hours, h)
+		before = h.Start
+	}
+	return hours, nil
+}
+
+// DeleteJournal removes the journal record with the given ID.
+func DeleteJournal(tx *gorm.DB, id uint64) error {
+	result := tx.Delete(&Journal{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != 1 {
+		return fmt.Errorf("deleted %d rows: %w", result.RowsAffected, gorm.ErrRecordNotFound)
+	}
+	return nil
 }
@@ -1,6 +1,7 @@
 package db
 
 import (
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"net/netip"
@@ -11,6 +12,7 @@ import (
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/labstack/echo/v5"
 	"github.com/nevkontakte/pat/db/dbtest"
+	"gorm.io/gorm"
 )
 
 func TestJournal_SaveAndRestore(t *testing.T) {
@@ -48,6 +50,117 @@ func TestJournal_SaveAndRestore(t *testing.T) {
 	}
 }
 
+func TestJournal_WithoutCat(t *testing.T) {
+	tx := dbtest.InMemory(t)
+	tx.AutoMigrate(&Cat{}, &Journal{})
+
+	j := Journal{
+		Admin: DefaultAdmin,
+		Event: Event{
+			Type:        EventAdminInvited,
+			Description: "Invited alice as viewer.",
+		},
+	}
+	dbtest.Save(t, tx, &j)
+
+	var got Journal
+	dbtest.First(t, tx.Preload("Cat"), &got, "id = ?", j.ID)
+	if diff := cmp.Diff(j, got, cmpopts.EquateComparable(Addr{})); diff != "" {
+		t.Errorf("Journal mismatch (-want +got):\n%s", diff)
+	}
+
+	var count int64
+	tx.Model(&Journal{}).Where("cat_id IS NULL").Count(&count)
+	if count != 1 {
+		t.Errorf("Got: %d journal records with NULL cat_id. Want: 1.", count)
+	}
+}
+
+func TestJournalAfter(t *testing.T) {
+	tx := dbtest.InMemory(t)
+	tx.AutoMigrate(&Cat{}, &Journal{})
+
+	for _, id := range []CatID{"black", "red", "black", "black"} {
+		dbtest.Save(t, tx, &Journal{CatID: id, Event: Event{Type: EventVisit}})
+	}
+
+	ids := func(records []Journal) []uint64 {
+		var ids []uint64
+		for _, r := range records {
+			ids = append(ids, r.ID)
+		}
+		return ids
+	}
+
+	testCases := []struct {
+		name  string
+		cat   CatID
+		after uint64
+		limit int
+		want  []uint64
+	}{
+		{name: "all", limit: 10, want: []uint64{1, 2, 3, 4}},
+		{name: "limit", limit: 2, want: []uint64{1, 2}},
+		{name: "after", after: 2, limit: 10, want: []uint64{3, 4}},
+		{name: "cat", cat: "black", after: 1, limit: 10, want: []uint64{3, 4}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := JournalAfter(tx, tc.cat, tc.after, tc.limit)
+			if err != nil {
+				t.Fatalf("Got: JournalAfter() returned error: %s. Want: no error.", err)
+			}
+			if diff := cmp.Diff(tc.want, ids(got)); diff != "" {
+				t.Errorf("JournalAfter() returned diff (-want,+got):\n%s", diff)
+			}
+		})
+	}
+
+	beforeCases := []struct {
+		name   string
+		cat    CatID
+		before uint64
+		limit  int
+		want   []uint64
+	}{
+		{name: "newest", limit: 10, want: []uint64{4, 3, 2, 1}},
+		{name: "limit", limit: 2, want: []uint64{4, 3}},
+		{name: "before", before: 3, limit: 10, want: []uint64{2, 1}},
+		{name: "cat", cat: "black", before: 4, limit: 10, want: []uint64{3, 1}},
+	}
+
+	for _, tc := range beforeCases {
+		t.Run("before/"+tc.name, func(t *testing.T) {
+			got, err := JournalBefore(tx, tc.cat, tc.before, tc.limit)
+			if err != nil {
+				t.Fatalf("Got: JournalBefore() returned error: %s. Want: no error.", err)
+			}
+			if diff := cmp.Diff(tc.want, ids(got)); diff != "" {
+				t.Errorf("JournalBefore() returned diff (-want,+got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("delete", func(t *testing.T) {
+		if err := DeleteJournal(tx, 2); err != nil {
+			t.Fatalf("Got: DeleteJournal() returned error: %s. Want: no error.", err)
+		}
+		if err := DeleteJournal(tx, 2); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("Got: second DeleteJournal() returned error: %v. Want: %v.", err, gorm.ErrRecordNotFound)
+		}
+	})
+}
+
+func TestEventType_String(t *testing.T) {
+	if got, want := EventPat.String(), "pat"; got != want {
+		t.Errorf("EventPat.String() = %q, want %q", got, want)
+	}
+	if got, want := EventType(999).String(), "event_999"; got != want {
+		t.Errorf("EventType(999).String() = %q, want %q", got, want)
+	}
+}
+
 func TestCurrentVisitor(t *testing.T) {
 	t.Run("populates IP, user agent, and referrer", func(t *testing.T) {
 		req := httptest.NewRequest(http.MethodGet, "/", nil)
@@ -84,3 +197,87 @@ func TestCurrentVisitor(t *testing.T) {
 		}
 	})
 }
+
+func TestPublicJournal(t *testing.T) {
+	tx := dbtest.InMemory(t)
+	tx.AutoMigrate(&Cat{}, &Journal{})
+
+	for _, r := range []Journal{
+		{CatID: SplotchID, Event: Event{Type: EventPat}},
+		{CatID: SplotchID, Event: Event{Type: EventVisit}},
+		{CatID: SplotchID, Admin: DefaultAdmin, Event: Event{Type: EventCatUpdated}},
+		{CatID: "red", Event: Event{Type: EventPat}},
+		{CatID: SplotchID, Event: Event{Type: EventNapped}},
+	} {
+		dbtest.Save(t, tx, &r)
+	}
+
+	got, err := PublicJournal(tx, SplotchID, 0, 10)
+	if err != nil {
+		t.Fatalf("Got: PublicJournal() returned error: %s. Want: no error.", err)
+	}
+	var types []EventType
+	for _, r := range got {
+		types = append(types, r.Event.Type)
+	}
+	if diff := cmp.Diff([]EventType{EventNapped, EventPat}, types); diff != "" {
+		t.Errorf("PublicJournal() returned diff (-want,+got):\n%s", diff)
+	}
+
+	got, err = NotableJournal(tx, SplotchID, 10)
+	if err != nil {
+		t.Fatalf("Got: NotableJournal() returned error: %s. Want: no error.", err)
+	}
+	types = nil
+	for _, r := range got {
+		types = append(types, r.Event.Type)
+	}
+	if diff := cmp.Diff([]EventType{EventNapped}, types); diff != "" {
+		t.Errorf("NotableJournal() returned diff (-want,+got):\n%s", diff)
+	}
+}
+
+func TestHourlyPats(t *testing.T) {
+	tx := dbtest.InMemory(t)
+	tx.AutoMigrate(&Cat{}, &Journal{})
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, r := range []Journal{
+		{CreatedAt: start.Add(10 * time.Minute), CatID: SplotchID, Event: Event{Type: EventPat}},
+		{CreatedAt: start.Add(20 * time.Minute), CatID: SplotchID, Event: Event{Type: EventPat}},
+		{CreatedAt: start.Add(30 * time.Minute), CatID: SplotchID, Event: Event{Type: EventFeed}},
+		{CreatedAt: start.Add(3*time.Hour + time.Minute), CatID: SplotchID, Event: Event{Type: EventPat}},
+		{CreatedAt: start.Add(3*time.Hour + time.Minute), CatID: "red", Event: Event{Type: EventPat}},
+		{CreatedAt: start.Add(5 * time.Hour), CatID: SplotchID, Event: Event{Type: EventPat}},
+	} {
+		dbtest.Save(t, tx, &r)
+	}
+
+	got, err := HourlyPats(tx, SplotchID, time.Time{}, 10)
+	if err != nil {
+		t.Fatalf("Got: HourlyPats() returned error: %s. Want: no error.", err)
+	}
+	want := []PatHour{
+		{Start: start.Add(5 * time.Hour), Latest: start.Add(5 * time.Hour), Pats: 1},
+		{Start: start.Add(3 * time.Hour), Latest: start.Add(3*time.Hour + time.Minute), Pats: 1},
+		{Start: start, Latest: start.Add(20 * time.Minute), Pats: 2},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("HourlyPats() returned diff (-want,+got):\n%s", diff)
+	}
+
+	got, err = HourlyPats(tx, SplotchID, start.Add(time.Hour), 1)
+	if err != nil {
+		t.Fatalf("Got: HourlyPats() returned error: %s. Want: no error.", err)
+	}
+	if diff := cmp.Diff(want[:1], got); diff != "" {
+		t.Errorf("HourlyPats() with a limit returned diff (-want,+got):\n%s", diff)
+	}
+	got, err = HourlyPats(tx, SplotchID, start.Add(time.Hour), 10)
+	if err != nil {
+		t.Fatalf("Got: HourlyPats() returned error: %s. Want: no error.", err)
+	}
+	if diff := cmp.Diff(want[:2], got); diff != "" {
+		t.Errorf("HourlyPats() since a later time returned diff (-want,+got):\n%s", diff)
+	}
+}
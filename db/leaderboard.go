@@ -0,0 +1,138 @@
+package db
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LeaderboardPeriod is the span of time a leaderboard ranks visitors over.
+type LeaderboardPeriod string
+
+const (
+	PeriodToday   LeaderboardPeriod = "today"
+	PeriodWeek    LeaderboardPeriod = "week"
+	PeriodAllTime LeaderboardPeriod = "all"
+)
+
+// LeaderboardPeriods lists all leaderboard periods, shortest first.
+var LeaderboardPeriods = []LeaderboardPeriod{PeriodToday, PeriodWeek, PeriodAllTime}
+
+// Title returns a human-readable name of the period.
+func (p LeaderboardPeriod) Title() string {
+	switch p {
+	case PeriodToday:
+		return "Today"
+	case PeriodWeek:
+		return "This week"
+	default:
+		return "All time"
+	}
+}
+
+// Since returns when the period that includes now started. Days start at
+// midnight UTC and weeks start on Monday. Zero time for all time.
+func (p LeaderboardPeriod) Since(now time.Time) time.Time {
+	now = now.UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	switch p {
+	case PeriodToday:
+		return today
+	case PeriodWeek:
+		return today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+	default:
+		return time.Time{}
+	}
+}
+
+// LeaderboardEntry is a visitor's place on the leaderboard.
+type LeaderboardEntry struct {
+	// Rank is the place of the visitor, starting at 1. Visitors with the same
+	// number of pats share the rank.
+	Rank int
+	// Nickname of the visitor, empty for visitors who didn't introduce
+	// themselves.
+	Nickname string
+	// Pats the visitor gave the cat over the period.
+	Pats int64
+	// Latest is the ID of the visitor's latest pat, used to order visitors
+	// with the same number of pats: whoever got there first is listed first.
+	Latest uint64
+}
+
+// Leaderboard returns up to limit visitors who patted the cat the most since
+// the given time, most pats first.
+//
+// Visitors who introduced themselves are ranked by their identity, unless they
+// opted out. Other visitors are ranked by their address, which is never
+// returned, unless visitors from the address opted out, see HiddenAddr.
+func Leaderboard(tx *gorm.DB, cat CatID, since time.Time, limit int) ([]LeaderboardEntry, error) {
+	var known []LeaderboardEntry
+	result := tx.Model(&Journal{}).
+		Select("identities.nickname AS nickname, COUNT(*) AS pats, MAX(journals.id) AS latest").
+		Joins("JOIN identities ON identities.id = journals.identity_id").
+		Where("journals.cat_id = ? AND journals.type = ? AND journals.created_at >= ?", cat, EventPat, since).
+		Where("identities.hidden = ?", false).
+		Group("identities.id, identities.nickname").
+		Order("pats DESC, latest").Limit(limit).
+		Scan(&known)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	var anonymous []LeaderboardEntry
+	result = tx.Model(&Journal{}).
+		Select("COUNT(*) AS pats, MAX(id) AS latest").
+		Where("cat_id = ? AND type = ? AND created_at >= ?", cat, EventPat, since).
+		Where("(identity_id IS NULL OR identity_id = 0) AND LENGTH(addr) > 0").
+		Where("NOT EXISTS (SELECT 1 FROM hidden_addrs WHERE hidden_addrs.addr = journals.addr)").
+		Group("addr").
+		Order("pats DESC, latest").Limit(limit).
+		Scan(&anonymous)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	entries := append(known, anonymous...)
+	slices.SortFunc(entries, func(a, b LeaderboardEntry) int {
+		return cmp.Or(cmp.Compare(b.Pats, a.Pats), cmp.Compare(a.Latest, b.Latest))
+	})
+	entries = entries[:min(limit, len(entries))]
+	for i := range entries {
+		entries[i].Rank = i + 1
+		if i > 0 && entries[i].Pats == entries[i-1].Pats {
+			entries[i].Rank = entries[i-1].Rank
+		}
+	}
+	return entries, nil
+}
+
+// HiddenAddr is an address whose anonymous visitors opted out of the
+// leaderboard. Visitors who introduced themselves opt out with their identity
+// instead, see Identity.Hidden.
+type HiddenAddr struct {
+	Addr      Addr `gorm:"primaryKey"`
+	CreatedAt time.Time
+}
+
+// SetAddrHidden opts anonymous visitors from the address out of the
+// leaderboard, or back in.
+func SetAddrHidden(tx *gorm.DB, addr Addr, hidden bool) error {
+	if hidden {
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&HiddenAddr{Addr: addr}).Error
+	}
+	return tx.Where("addr = ?", addr).Delete(&HiddenAddr{}).Error
+}
+
+// AddrHidden returns true if anonymous visitors from the address opted out of
+// the leaderboard.
+func AddrHidden(tx *gorm.DB, addr Addr) (bool, error) {
+	var n int64
+	if result := tx.Model(&HiddenAddr{}).Where("addr = ?", addr).Count(&n); result.Error != nil {
+		return false, result.Error
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,118 @@
+package db
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nevkontakte/pat/db/dbtest"
+)
+
+func TestLeaderboardPeriod_Since(t *testing.T) {
+	// Wednesday.
+	now := time.Date(2024, 1, 3, 15, 4, 5, 0, time.FixedZone("UTC+3", 3*60*60))
+	tests := map[LeaderboardPeriod]time.Time{
+		PeriodToday:   time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC),
+		PeriodWeek:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		PeriodAllTime: {},
+	}
+	for period, want := range tests {
+		if got := period.Since(now); !got.Equal(want) {
+			t.Errorf("Got: %s.Since(%s) = %s. Want: %s.", period, now, got, want)
+		}
+	}
+	sunday := time.Date(2024, 1, 7, 23, 0, 0, 0, time.UTC)
+	if got, want := PeriodWeek.Since(sunday), time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Got: week.Since(%s) = %s. Want: %s.", sunday, got, want)
+	}
+}
+
+func TestLeaderboard(t *testing.T) {
+	tx := dbtest.InMemory(t)
+	tx.AutoMigrate(Cat{}, Journal{}, Identity{}, HiddenAddr{})
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	alice := Identity{Nickname: "Alice"}
+	bob := Identity{Nickname: "Bob"}
+	shy := Identity{Nickname: "Shy", Hidden: true}
+	for _, i := range []*Identity{&alice, &bob, &shy} {
+		dbtest.Save(t, tx, i)
+	}
+	anonymous := func(addr string) *Visitor {
+		return &Visitor{Addr: Addr(netip.MustParseAddr(addr)), Agent: "test"}
+	}
+	known := func(i Identity) *Visitor {
+		v := anonymous("192.0.2.100")
+		v.IdentityID = i.ID
+		return v
+	}
+	pats := func(at time.Time, n int, v *Visitor) {
+		t.Helper()
+		for range n {
+			dbtest.Save(t, tx, &Journal{CreatedAt: at, Visitor: v, CatID: SplotchID, Event: Event{Type: EventPat}})
+		}
+	}
+
+	pats(start, 5, known(alice))
+	pats(start.Add(time.Hour), 3, known(bob))
+	pats(start.Add(2*time.Hour), 3, anonymous("192.0.2.1"))
+	pats(start.Add(3*time.Hour), 9, known(shy))
+	pats(start.Add(4*time.Hour), 2, anonymous("192.0.2.2"))
+	pats(start.Add(4*time.Hour), 1, &Visitor{Agent: "no address"})
+	pats(start.Add(4*time.Hour), 7, nil)
+	dbtest.Save(t, tx, &Journal{CreatedAt: start, Visitor: known(bob), CatID: "black", Event: Event{Type: EventPat}})
+	dbtest.Save(t, tx, &Journal{CreatedAt: start, Visitor: known(bob), CatID: SplotchID, Event: Event{Type: EventFeed}})
+
+	got, err := Leaderboard(tx, SplotchID, time.Time{}, 10)
+	if err != nil {
+		t.Fatalf("Got: Leaderboard() returned error: %s. Want: no error.", err)
+	}
+	want := []LeaderboardEntry{
+		{Rank: 1, Nickname: "Alice", Pats: 5},
+		{Rank: 2, Nickname: "Bob", Pats: 3},
+		{Rank: 2, Pats: 3},
+		{Rank: 4, Pats: 2},
+	}
+	ignoreLatest := cmp.Transformer("", func(e LeaderboardEntry) LeaderboardEntry { e.Latest = 0; return e })
+	if diff := cmp.Diff(want, got, ignoreLatest); diff != "" {
+		t.Errorf("Leaderboard() mismatch (-want +got):\n%s", diff)
+	}
+
+	got, err = Leaderboard(tx, SplotchID, start.Add(90*time.Minute), 2)
+	if err != nil {
+		t.Fatalf("Got: Leaderboard() returned error: %s. Want: no error.", err)
+	}
+	want = []LeaderboardEntry{{Rank: 1, Pats: 3}, {Rank: 2, Pats: 2}}
+	if diff := cmp.Diff(want, got, ignoreLatest); diff != "" {
+		t.Errorf("Leaderboard() since a later time mismatch (-want +got):\n%s", diff)
+	}
+
+	hidden := anonymous("192.0.2.1").Addr
+	for range 2 {
+		if err := SetAddrHidden(tx, hidden, true); err != nil {
+			t.Fatalf("Got: SetAddrHidden() returned error: %s. Want: no error.", err)
+		}
+	}
+	if ok, err := AddrHidden(tx, hidden); err != nil || !ok {
+		t.Errorf("Got: AddrHidden() = %v, %v. Want: true, no error.", ok, err)
+	}
+	got, err = Leaderboard(tx, SplotchID, time.Time{}, 10)
+	if err != nil {
+		t.Fatalf("Got: Leaderboard() returned error: %s. Want: no error.", err)
+	}
+	want = []LeaderboardEntry{
+		{Rank: 1, Nickname: "Alice", Pats: 5},
+		{Rank: 2, Nickname: "Bob", Pats: 3},
+		{Rank: 3, Pats: 2},
+	}
+	if diff := cmp.Diff(want, got, ignoreLatest); diff != "" {
+		t.Errorf("Leaderboard() with a hidden address mismatch (-want +got):\n%s", diff)
+	}
+	if err := SetAddrHidden(tx, hidden, false); err != nil {
+		t.Fatalf("Got: SetAddrHidden() returned error: %s. Want: no error.", err)
+	}
+	if ok, err := AddrHidden(tx, hidden); err != nil || ok {
+		t.Errorf("Got: AddrHidden() after opting back in = %v, %v. Want: false, no error.", ok, err)
+	}
+}
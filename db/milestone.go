@@ -0,0 +1,153 @@
+package db
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MilestoneKind is a reason to celebrate a pat.
+type MilestoneKind string
+
+const (
+	MilestoneCount       MilestoneKind = "count"       // The pat count reached one of MilestonePats.
+	MilestonePalindrome  MilestoneKind = "palindrome"  // The pat count reads the same backwards.
+	MilestoneAnniversary MilestoneKind = "anniversary" // The first pat since an anniversary of the first pat.
+)
+
+// MilestonePats lists pat counts that are milestones. Change it before
+// starting the server to celebrate other counts.
+var MilestonePats = []uint64{100, 1000, 10_000, 100_000, 1_000_000}
+
+// minPalindrome is the smallest palindromic pat count that is a milestone.
+// Smaller palindromes are too common to celebrate.
+const minPalindrome = 101
+
+// Milestone is a notable pat.
+type Milestone struct {
+	Kind MilestoneKind
+	// Value is the pat count, or the number of years since the first pat for
+	// anniversaries.
+	Value uint64
+}
+
+// Title returns a short celebratory headline for the milestone.
+func (m Milestone) Title(cat string) string {
+	switch m.Kind {
+	case MilestoneAnniversary:
+		if m.Value == 1 {
+			return fmt.Sprintf("A year since %s's first pat!", cat)
+		}
+		return fmt.Sprintf("%d years since %s's first pat!", m.Value, cat)
+	case MilestonePalindrome:
+		return fmt.Sprintf("Pat number %d reads the same backwards!", m.Value)
+	default:
+		return fmt.Sprintf("%s's %s pat!", cat, humanize.Ordinal(int(m.Value)))
+	}
+}
+
+// describe returns the journal description of the milestone reached by the
+// visitor's pat.
+func (m Milestone) describe(cat string, v *Visitor) string {
+	visitor := v.Name()
+	switch m.Kind {
+	case MilestoneAnniversary:
+		return fmt.Sprintf("%s Celebrated with a pat from %s.", m.Title(cat), visitor)
+	case MilestonePalindrome:
+		return fmt.Sprintf("%s got the palindromic pat number %d from %s.", cat, m.Value, visitor)
+	default:
+		return fmt.Sprintf("%s got their %s pat from %s!", cat, humanize.Ordinal(int(m.Value)), visitor)
+	}
+}
+
+// key returns the journal record key for the cat's milestone, which makes sure
+// it's only reached once.
+func (m Milestone) key(cat CatID) *string {
+	key := fmt.Sprintf("milestone:%s:%s:%d", cat, m.Kind, m.Value)
+	return &key
+}
+
+// palindromic returns true if the number reads the same backwards.
+func palindromic(n uint64) bool {
+	s := strconv.FormatUint(n, 10)
+	for i := range len(s) / 2 {
+		if s[i] != s[len(s)-1-i] {
+			return false
+		}
+	}
+	return true
+}
+
+// yearsSince returns the number of full years between the times.
+func yearsSince(from, to time.Time) int {
+	years := to.Year() - from.Year()
+	if from.AddDate(years, 0, 0).After(to) {
+		years--
+	}
+	return years
+}
+
+// milestones returns candidate milestones of the cat's latest pat at the given
+// time. The cat must reflect the pat already. Anniversaries are candidates for
+// every pat after them; only the first one gets recorded.
+func (c Cat) milestones(now time.Time) []Milestone {
+	var ms []Milestone
+	if slices.Contains(MilestonePats, c.Pats) {
+		ms = append(ms, Milestone{Kind: MilestoneCount, Value: c.Pats})
+	}
+	if c.Pats >= minPalindrome && palindromic(c.Pats) {
+		ms = append(ms, Milestone{Kind: MilestonePalindrome, Value: c.Pats})
+	}
+	if years := yearsSince(c.FirstPat, now); !c.FirstPat.IsZero() && years > 0 {
+		ms = append(ms, Milestone{Kind: MilestoneAnniversary, Value: uint64(years)})
+	}
+	return ms
+}
+
+// firstPat returns the time of the cat's first pat according to the journal, or
+// the given time if the journal has none. It backfills Cat.FirstPat for cats
+// that were patted before it was introduced.
+func firstPat(tx *gorm.DB, id CatID, now time.Time) (time.Time, error) {
+	var first Journal
+	result := tx.Where("cat_id = ? AND type = ?", id, EventPat).Order("created_at").Limit(1).Find(&first)
+	if result.Error != nil {
+		return time.Time{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return now, nil
+	}
+	return first.CreatedAt, nil
+}
+
+// recordMilestones adds journal records for the milestones reached by the
+// cat's latest pat, credited to the visitor, and returns the newly reached
+// ones. It must run in the pat transaction.
+func recordMilestones(tx *gorm.DB, cat Cat, now time.Time, visitor *Visitor) ([]Milestone, error) {
+	var reached []Milestone
+	for _, m := range cat.milestones(now) {
+		r := Journal{
+			CreatedAt: now,
+			Visitor:   visitor,
+			CatID:     cat.ID,
+			Event:     Event{Type: EventMilestone, Description: m.describe(cat.Name, visitor)},
+			Key:       m.key(cat.ID),
+		}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&r)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue // Already reached, e.g. an anniversary.
+		}
+		if err := EnqueueDeliveries(tx, r); err != nil {
+			return nil, err
+		}
+		reached = append(reached, m)
+	}
+	return reached, nil
+}
@@ -0,0 +1,95 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nevkontakte/pat/chrono/chronotest"
+	"github.com/nevkontakte/pat/db/dbtest"
+)
+
+func TestPalindromic(t *testing.T) {
+	for n, want := range map[uint64]bool{0: true, 7: true, 10: false, 101: true, 1221: true, 1231: false, 12321: true} {
+		if got := palindromic(n); got != want {
+			t.Errorf("Got: palindromic(%d) = %t. Want: %t.", n, got, want)
+		}
+	}
+}
+
+func TestYearsSince(t *testing.T) {
+	first := time.Date(2020, 2, 29, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		to   time.Time
+		want int
+	}{
+		{first, 0},
+		{time.Date(2021, 2, 28, 12, 0, 0, 0, time.UTC), 0},
+		{time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC), 1},
+		{time.Date(2024, 2, 29, 11, 59, 0, 0, time.UTC), 3},
+		{time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC), 4},
+	}
+	for _, tc := range tests {
+		if got := yearsSince(first, tc.to); got != tc.want {
+			t.Errorf("Got: yearsSince(%v, %v) = %d. Want: %d.", first, tc.to, got, tc.want)
+		}
+	}
+}
+
+func TestInteract_Milestones(t *testing.T) {
+	tx := dbtest.InMemory(t)
+	tx.AutoMigrate(Cat{}, Journal{}, Webhook{}, Delivery{})
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := chronotest.NewClock(now)
+	pat, _ := InteractionByID("pat")
+	visitor := &Visitor{Agent: "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0"}
+
+	// Splotch was first patted before FirstPat was introduced, so it's
+	// backfilled from the journal.
+	first := now.Add(-2*365*24*time.Hour - time.Hour)
+	dbtest.Save(t, tx, &Cat{ID: SplotchID, Name: "Splotch", Pats: 99, LatestPat: now.Add(-time.Hour)})
+	dbtest.Save(t, tx, &Journal{CreatedAt: first, CatID: SplotchID, Event: Event{Type: EventPat}})
+
+	var got [][]Milestone
+	for range 3 {
+		clock.Advance(time.Minute)
+		reached, err := Interact(tx, clock, SplotchID, pat, visitor)
+		if err != nil {
+			t.Fatalf("Got: Interact() returned error: %s. Want: no error.", err)
+		}
+		got = append(got, reached)
+	}
+	want := [][]Milestone{
+		{{Kind: MilestoneCount, Value: 100}, {Kind: MilestoneAnniversary, Value: 2}},
+		{{Kind: MilestonePalindrome, Value: 101}},
+		nil,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Interact() milestones mismatch (-want +got):\n%s", diff)
+	}
+
+	var cat Cat
+	dbtest.First(t, tx, &cat)
+	if !cat.FirstPat.Equal(first) {
+		t.Errorf("Got: first pat at %v. Want: %v from the journal.", cat.FirstPat, first)
+	}
+
+	var records []Journal
+	tx.Where("type = ?", EventMilestone).Order("id").Find(&records)
+	var descriptions []string
+	for _, r := range records {
+		if r.Visitor == nil || r.Visitor.Agent != visitor.Agent {
+			t.Errorf("Got: milestone credited to %+v. Want: %+v.", r.Visitor, visitor)
+		}
+		descriptions = append(descriptions, r.Event.Description)
+	}
+	wantDescriptions := []string{
+		"Splotch got their 100th pat from a visitor in Firefox!",
+		"2 years since Splotch's first pat! Celebrated with a pat from a visitor in Firefox.",
+		"Splotch got the palindromic pat number 101 from a visitor in Firefox.",
+	}
+	if diff := cmp.Diff(wantDescriptions, descriptions); diff != "" {
+		t.Errorf("Milestone records mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,265 @@
+package db
+
+import (
+	"iter"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/nevkontakte/pat/behavior"
+)
+
+// MoodModel determines how the cat's mood changes over time.
+type MoodModel string
+
+const (
+	// MoodModelClassic switches moods at fixed thresholds since the latest
+	// pat, with a noisy window for happiness. It's the default.
+	MoodModelClassic MoodModel = "classic"
+	// MoodModelMarkov changes moods randomly after the latest pat, following
+	// time-dependent transition probabilities.
+	MoodModelMarkov MoodModel = "markov"
+)
+
+// Valid returns true for known mood models, including the empty default.
+func (m MoodModel) Valid() bool {
+	return m == "" || m == MoodModelClassic || m == MoodModelMarkov
+}
+
+const (
+	// patDuration is how long the cat enjoys a pat.
+	patDuration = 5 * time.Second
+	// impatienceDelay is the time since the latest pat after which the cat is
+	// always impatient.
+	impatienceDelay = 7 * 24 * time.Hour
+	// moodStep is how often the Markov mood model may change the mood.
+	moodStep = time.Minute
+)
+
+// classicMood implements MoodModelClassic.
+func (c Cat) classicMood(now time.Time) Mood {
+	sincePat := now.Sub(c.LatestPat)
+
+	// Someone is petting the cat right now!
+	if sincePat < patDuration {
+		return MoodPat
+	}
+
+	// Someone petted the cat recently, he's happy.
+	happyWindow := c.happyWindow()
+	if sincePat < happyWindow {
+		return MoodIdleHappy
+	}
+
+	// It's been far too long since anyone played with the cat, she's bored.
+	// Checked before mood swings, so that they can't overflow sincePat, which
+	// is huge for cats that were never petted.
+	if sincePat >= c.impatienceDelay() {
+		return MoodImpatient
+	}
+
+	// In the next three hours, the cat may remember getting petted and get happy again.
+	swing := c.moodSwing()
+	moodSwing := behavior.Spread(-swing, swing, c.swingNoise().At(now))
+	if sincePat+moodSwing < happyWindow {
+		return MoodIdleHappy
+	}
+
+	// Cat's just chillin'.
+	return MoodIdle
+}
+
+// happyWindow returns how long the cat stays happy after a pat in the classic
+// model, not counting mood swings.
+func (c Cat) happyWindow() time.Duration {
+	return scaleDuration(30*time.Minute, c.Personality.Clinginess)
+}
+
+// moodSwing returns how much mood swings shift the time since the latest pat
+// in the classic model, at most, in either direction.
+func (c Cat) moodSwing() time.Duration {
+	return scaleDuration(3*time.Hour, c.Personality.Moodiness)
+}
+
+// swingNoise returns the noise behind the classic model's mood swings.
+func (c Cat) swingNoise() behavior.SmoothNoise {
+	return c.noise(c.ID.Seed("happy"), scaleDuration(5*time.Minute, c.Personality.Sleepiness))
+}
+
+// NoiseCurve is a named noise source that the cat's mood depends on.
+type NoiseCurve struct {
+	Cue   string
+	Noise behavior.TemporalNoise
+}
+
+// MoodNoise returns the noise sources behind the cat's mood model, so that
+// its decisions can be explained.
+func (c Cat) MoodNoise() []NoiseCurve {
+	switch c.Model {
+	case MoodModelMarkov:
+		return []NoiseCurve{{Cue: "mood", Noise: c.moodChain().Noise}}
+	default:
+		return []NoiseCurve{{Cue: "happy", Noise: c.swingNoise()}}
+	}
+}
+
+// impatienceDelay returns the time since the latest pat after which the cat is
+// always impatient.
+func (c Cat) impatienceDelay() time.Duration {
+	return scaleDuration(impatienceDelay, c.Personality.Patience)
+}
+
+// markovMood implements MoodModelMarkov.
+//
+// The chain starts in MoodIdleHappy when the pat ends. It's replayed up to
+// impatienceDelay once per pat, see trajectory, so that the mood can be looked
+// up cheaply on every request.
+func (c Cat) markovMood(now time.Time) Mood {
+	sincePat := now.Sub(c.LatestPat)
+	if sincePat < patDuration {
+		return MoodPat
+	}
+	if sincePat >= c.impatienceDelay() {
+		return MoodImpatient
+	}
+	return c.trajectory().moodAt(now)
+}
+
+// maxTrajectories bounds the number of cached Markov mood trajectories. With
+// one trajectory per cat and pat, only the latest ones are ever used.
+const maxTrajectories = 256
+
+// moodTrajectory is a replayed Markov chain of the cat's moods after a pat:
+// the moods it changes to and when.
+type moodTrajectory struct {
+	at    []time.Time
+	moods []Mood
+}
+
+// moodAt returns the chain's mood at t, which must be after the pat ended.
+func (tr *moodTrajectory) moodAt(t time.Time) Mood {
+	i, found := slices.BinarySearchFunc(tr.at, t, time.Time.Compare)
+	if found {
+		return tr.moods[i]
+	}
+	if i == 0 {
+		return MoodIdleHappy
+	}
+	return tr.moods[i-1]
+}
+
+// trajectoryKey identifies a trajectory by everything the chain depends on.
+type trajectoryKey struct {
+	cat         CatID
+	noise       NoiseKind
+	personality Personality
+	latestPat   int64
+}
+
+var trajectories struct {
+	sync.Mutex
+	cache map[trajectoryKey]*moodTrajectory
+}
+
+// trajectory returns the cat's Markov mood trajectory after the latest pat,
+// up to impatience.
+//
+// Replaying the chain takes up to impatienceDelay/moodStep steps, so the
+// trajectory is cached until the next pat.
+func (c Cat) trajectory() *moodTrajectory {
+	key := trajectoryKey{cat: c.ID, noise: c.Noise, personality: c.Personality, latestPat: c.LatestPat.UnixNano()}
+	trajectories.Lock()
+	tr, ok := trajectories.cache[key]
+	trajectories.Unlock()
+	if ok {
+		return tr
+	}
+
+	tr = &moodTrajectory{}
+	state := MoodIdleHappy
+	start := c.LatestPat.Add(patDuration)
+	for at, mood := range c.moodChain().All(MoodIdleHappy, start, c.LatestPat.Add(c.impatienceDelay())) {
+		if mood != state {
+			tr.at = append(tr.at, at)
+			tr.moods = append(tr.moods, mood)
+			state = mood
+		}
+	}
+
+	trajectories.Lock()
+	defer trajectories.Unlock()
+	if len(trajectories.cache) >= maxTrajectories {
+		trajectories.cache = nil
+	}
+	if trajectories.cache == nil {
+		trajectories.cache = map[trajectoryKey]*moodTrajectory{}
+	}
+	trajectories.cache[key] = tr
+	return tr
+}
+
+// moodChain returns the Markov chain of the cat's moods after a pat.
+func (c Cat) moodChain() behavior.Markov[Mood] {
+	p := c.Personality
+	return behavior.Markov[Mood]{
+		Noise: c.rawNoise(c.ID.Seed("mood")),
+		Step:  moodStep,
+		Transitions: func(mood Mood, elapsed time.Duration) []behavior.Weighted[Mood] {
+			return moodTransitions(p, mood, elapsed)
+		},
+	}
+}
+
+// moodTransitions returns relative probabilities of the next mood, given the
+// time elapsed since the pat ended.
+func moodTransitions(p Personality, mood Mood, elapsed time.Duration) []behavior.Weighted[Mood] {
+	hours := elapsed.Hours()
+	switch mood {
+	case MoodIdleHappy:
+		// Happiness lasts about half an hour, fading faster as time passes.
+		return []behavior.Weighted[Mood]{
+			{Value: MoodIdleHappy, Weight: 30 * scale(p.Clinginess)},
+			{Value: MoodIdle, Weight: 1 + hours},
+		}
+	case MoodIdleBlink:
+		return []behavior.Weighted[Mood]{{Value: MoodIdle, Weight: 1}}
+	case MoodImpatient:
+		// Only a pat can cheer the cat up.
+		return []behavior.Weighted[Mood]{{Value: MoodImpatient, Weight: 1}}
+	default:
+		// The cat may get bored after three days without pats.
+		boredAfter := 3 * 24 * scale(p.Patience)
+		return []behavior.Weighted[Mood]{
+			{Value: MoodIdle, Weight: 100},
+			{Value: MoodIdleBlink, Weight: 2 * scale(p.Sleepiness)},
+			// The cat may remember the pat and get happy again, less likely as
+			// time passes.
+			{Value: MoodIdleHappy, Weight: 3 * scale(p.Moodiness) * math.Exp(-hours/3)},
+			{Value: MoodImpatient, Weight: max(hours-boredAfter, 0) / 24 / 20},
+		}
+	}
+}
+
+// Moods iterates over the cat's moods from the given time until the end time
+// inclusive, sampled every step.
+func (c Cat) Moods(from, until time.Time, step time.Duration) iter.Seq2[time.Time, Mood] {
+	return c.moodsAt(func(yield func(time.Time) bool) {
+		for t := from; !t.After(until); t = t.Add(step) {
+			if !yield(t) {
+				return
+			}
+		}
+	})
+}
+
+// moodsAt iterates over the cat's moods at the given times.
+func (c Cat) moodsAt(times iter.Seq[time.Time]) iter.Seq2[time.Time, Mood] {
+	return func(yield func(time.Time, Mood) bool) {
+		for t := range times {
+			if !yield(t, c.MoodAt(t)) {
+				return
+			}
+		}
+	}
+}
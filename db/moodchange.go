@@ -0,0 +1,225 @@
+package db
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MoodChange is a transition of the cat's mood.
+type MoodChange struct {
+	At   time.Time // The first moment with the new mood.
+	From Mood
+	To   Mood
+}
+
+// moodBreakpoints returns the times when the cat's mood may change regardless
+// of noise: when a pat ends, when happiness fades, when the cat runs out of
+// patience and when it starts lacking one of its needs.
+func (c Cat) moodBreakpoints() []time.Time {
+	points := []time.Time{
+		c.LatestPat.Add(patDuration),
+		c.LatestPat.Add(c.happyWindow()),
+		c.LatestPat.Add(c.impatienceDelay()),
+	}
+	for _, need := range AllNeeds {
+		if t := c.lacksSince(need); !t.IsZero() {
+			points = append(points, t)
+		}
+	}
+	return points
+}
+
+// swingPoints returns times within (from, to] that split it into spans where
+// the classic model's mood swing can start or end at most once.
+//
+// Between happiness fading and impatience, the cat is happy while
+//
+//	sincePat + swing·(2·noise - 1) < happyWindow.
+//
+// Within each period of the swing noise, noise = v0 + S(u)·(v1 - v0), where v0
+// and v1 are the noise values at the ends of the period and S is the
+// smootherstep of u, the position within the period. The left side grows at
+// the rate of 1 + k·S'(u), with k = 2·swing·(v1 - v0)/period and
+// S'(u) = 30u²(1 - u)². If the noise falls steeply enough, the rate is negative
+// between the two points where S'(u) = -1/k, so the left side is monotonic,
+// and crosses happyWindow at most once, between the ends of the period and
+// those points.
+func (c Cat) swingPoints(from, to time.Time) []time.Time {
+	if start := c.LatestPat.Add(c.happyWindow()); from.Before(start) {
+		from = start
+	}
+	if end := c.LatestPat.Add(c.impatienceDelay()); to.After(end) {
+		to = end
+	}
+	noise := c.swingNoise()
+	period := float64(noise.Period)
+	var points []time.Time
+	for t0 := from.Truncate(noise.Period); t0.Before(to); t0 = t0.Add(noise.Period) {
+		points = append(points, t0)
+		k := 2 * float64(c.moodSwing()) * (noise.Underlying.At(t0.Add(noise.Period)) - noise.Underlying.At(t0)) / period
+		if k >= 0 {
+			continue
+		}
+		// Solve u²(1 - u)² = -1/(30k) for u in [0, 1].
+		r := math.Sqrt(-1 / (30 * k))
+		if r > 0.25 {
+			continue
+		}
+		d := math.Sqrt(1 - 4*r)
+		points = append(points,
+			t0.Add(time.Duration((1-d)/2*period)),
+			t0.Add(time.Duration((1+d)/2*period)),
+		)
+	}
+	return points
+}
+
+// MoodChanges returns the cat's mood transitions within (from, to], in order,
+// assuming the cat's state doesn't change in between.
+//
+// Transition times are exact: they follow from the structure of the mood model
+// rather than from sampling. The mood is evaluated at breakpoints and points
+// where the model may change its mind, see swingPoints and moodTrajectory,
+// between which the mood changes at most once. Each change is then pinpointed
+// with a binary search.
+func (c Cat) MoodChanges(from, to time.Time) []MoodChange {
+	points := c.moodBreakpoints()
+	if c.Model == MoodModelMarkov {
+		if to.After(c.LatestPat.Add(patDuration)) && from.Before(c.LatestPat.Add(c.impatienceDelay())) {
+			points = append(points, c.trajectory().at...)
+		}
+	} else {
+		points = append(points, c.swingPoints(from, to)...)
+	}
+	times := []time.Time{from}
+	for _, t := range points {
+		if t.After(from) && !t.After(to) {
+			times = append(times, t)
+		}
+	}
+	times = append(times, to)
+	slices.SortFunc(times, time.Time.Compare)
+	times = slices.CompactFunc(times, time.Time.Equal)
+
+	var changes []MoodChange
+	var prevAt time.Time
+	var prev Mood
+	for t, mood := range c.moodsAt(slices.Values(times)) {
+		if prev != "" && mood != prev {
+			changes = append(changes, MoodChange{At: c.moodChangeAt(prevAt, t, prev), From: prev, To: mood})
+		}
+		prevAt, prev = t, mood
+	}
+	return changes
+}
+
+// moodChangeAt returns the earliest time in (lo, hi] when the cat's mood
+// differs from the given one, assuming it's the mood at lo and not at hi.
+func (c Cat) moodChangeAt(lo, hi time.Time, mood Mood) time.Time {
+	for hi.Sub(lo) > 1 {
+		mid := lo.Add(hi.Sub(lo) / 2)
+		if c.MoodAt(mid) == mood {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return hi
+}
+
+// ChangedAt returns the latest time when the cat's state was updated by an
+// interaction. The cat's current state doesn't tell its moods before that.
+func (c Cat) ChangedAt() time.Time {
+	latest := c.LatestPat
+	for _, need := range AllNeeds {
+		if t := *c.Needs.at(need); t.After(latest) {
+			latest = t
+		}
+	}
+	return latest
+}
+
+// moodKey returns the journal record key for the mood change.
+func moodKey(cat CatID, at time.Time) *string {
+	key := fmt.Sprintf("mood:%s:%d", cat, at.UnixNano())
+	return &key
+}
+
+// RecordMoodChanges adds EventMoodChanged journal records for the cat's mood
+// transitions within (from, to] and returns the number of added records.
+//
+// Transitions before the latest interaction with the cat are skipped, since
+// the cat's current state no longer describes that time. The interactions
+// record mood changes they cause themselves. So are the ends of pats, which
+// follow every pat a few seconds later and aren't news.
+//
+// Each transition is recorded once, even if the function is called repeatedly
+// or concurrently for overlapping intervals.
+func RecordMoodChanges(tx *gorm.DB, cat Cat, from, to time.Time) (int, error) {
+	if changed := cat.ChangedAt(); changed.After(from) {
+		from = changed
+	}
+	added := 0
+	for _, change := range cat.MoodChanges(from, to) {
+		if change.From == MoodPat {
+			continue
+		}
+		r := Journal{
+			CreatedAt: change.At,
+			CatID:     cat.ID,
+			Event: Event{
+				Type:        EventMoodChanged,
+				Description: cat.Describe(EventMoodChanged, change.At, nil),
+				PrevMood:    change.From,
+				Mood:        change.To,
+			},
+			Key: moodKey(cat.ID, change.At),
+		}
+		inserted := false
+		err := tx.Transaction(func(tx *gorm.DB) error {
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&r)
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+			inserted = true
+			return EnqueueDeliveries(tx, r)
+		})
+		if err != nil {
+			return added, err
+		}
+		if inserted {
+			added++
+		}
+	}
+	return added, nil
+}
+
+// MoodSince returns the time since which the cat has been in its current mood,
+// according to the journal, or zero time if the journal doesn't know.
+//
+// Pats end without a journal record, so the end of the latest pat is taken
+// from the cat's state.
+func MoodSince(tx *gorm.DB, cat Cat, now time.Time) (time.Time, error) {
+	var r Journal
+	result := tx.Where("cat_id = ? AND mood <> '' AND prev_mood <> mood AND created_at <= ?", cat.ID, now).
+		Order("created_at desc").Limit(1).Find(&r)
+	if result.Error != nil {
+		return time.Time{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return time.Time{}, nil
+	}
+	since, mood := r.CreatedAt, r.Event.Mood
+	if end := cat.LatestPat.Add(patDuration); mood == MoodPat && end.After(since) && !now.Before(end) {
+		since, mood = end, cat.MoodAt(end)
+	}
+	if mood != cat.MoodAt(now) {
+		return time.Time{}, nil
+	}
+	return since, nil
+}
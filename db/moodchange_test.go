@@ -0,0 +1,254 @@
+package db
+
+import (
+	"cmp"
+	"testing"
+	"time"
+
+	"github.com/nevkontakte/pat/behavior"
+	"github.com/nevkontakte/pat/db/dbtest"
+)
+
+// checkMoodChanges verifies that the transitions are exact and that the cat's
+// mood doesn't change between them, sampling it every step.
+func checkMoodChanges(t *testing.T, cat Cat, from, to time.Time, step time.Duration) []MoodChange {
+	t.Helper()
+	changes := cat.MoodChanges(from, to)
+	for _, c := range changes {
+		if got := cat.MoodAt(c.At); got != c.To {
+			t.Errorf("Got: mood %q at the %v transition at %v. Want: %q.", got, c, c.At, c.To)
+		}
+		if got := cat.MoodAt(c.At.Add(-1)); got != c.From {
+			t.Errorf("Got: mood %q right before the %v transition. Want: %q.", got, c, c.From)
+		}
+	}
+
+	next := 0
+	mood := cat.MoodAt(from)
+	for at, got := range cat.Moods(from, to, step) {
+		for next < len(changes) && !changes[next].At.After(at) {
+			if changes[next].From != mood {
+				t.Fatalf("Got: transition %v from %q. Want: from %q.", changes[next], changes[next].From, mood)
+			}
+			mood = changes[next].To
+			next++
+		}
+		if got != mood {
+			t.Fatalf("Got: mood %q at %v. Want: %q after transitions %v.", got, at, mood, changes[:next])
+		}
+	}
+	return changes
+}
+
+func TestCat_MoodChanges(t *testing.T) {
+	t.Parallel()
+	patted := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("classic", func(t *testing.T) {
+		cat := Cat{ID: SplotchID, LatestPat: patted}
+		changes := checkMoodChanges(t, cat, patted, patted.Add(8*24*time.Hour), time.Second)
+		if len(changes) < 3 {
+			t.Fatalf("Got: transitions %v. Want: at least the end of the pat, happiness and impatience.", changes)
+		}
+		if want := (MoodChange{At: patted.Add(patDuration), From: MoodPat, To: MoodIdleHappy}); changes[0] != want {
+			t.Errorf("Got: first transition %v. Want: %v.", changes[0], want)
+		}
+		if want := (MoodChange{At: patted.Add(impatienceDelay), From: MoodIdle, To: MoodImpatient}); changes[len(changes)-1] != want {
+			t.Errorf("Got: last transition %v. Want: %v.", changes[len(changes)-1], want)
+		}
+	})
+
+	t.Run("fastest swings", func(t *testing.T) {
+		// The swing noise changes fastest and matters most for sleepy, moody
+		// cats, so the mood may change several times within a noise period.
+		for _, noise := range []NoiseKind{NoiseMd5, NoiseHash} {
+			cat := Cat{ID: SplotchID, LatestPat: patted, Noise: noise, Personality: Personality{Moodiness: 1, Sleepiness: -1}}
+			from := patted.Add(cat.happyWindow())
+			changes := checkMoodChanges(t, cat, from, from.Add(3*time.Hour), 100*time.Millisecond)
+			if len(changes) < 10 {
+				t.Errorf("Got: %d transitions with %s noise. Want: frequent mood swings.", len(changes), noise)
+			}
+		}
+	})
+
+	t.Run("markov", func(t *testing.T) {
+		cat := Cat{ID: SplotchID, LatestPat: patted, Noise: NoiseHash, Model: MoodModelMarkov}
+		changes := checkMoodChanges(t, cat, patted, patted.Add(12*time.Hour), 10*time.Second)
+		start := patted.Add(patDuration)
+		for _, c := range changes[1:] {
+			if c.At.Sub(start)%moodStep != 0 {
+				t.Errorf("Got: transition %v between chain steps. Want: at a step.", c)
+			}
+		}
+	})
+
+	t.Run("needs", func(t *testing.T) {
+		cat := Cat{ID: SplotchID, LatestPat: patted, Needs: Needs{Food: patted}}
+		hungry := cat.lacksSince(NeedFood)
+		changes := checkMoodChanges(t, cat, hungry.Add(-time.Minute), hungry.Add(time.Minute), time.Second)
+		if want := []MoodChange{{At: hungry, From: MoodIdle, To: MoodImpatient}}; len(changes) != 1 || changes[0] != want[0] {
+			t.Errorf("Got: transitions %v. Want: %v.", changes, want)
+		}
+	})
+
+	t.Run("never patted", func(t *testing.T) {
+		cat := Cat{ID: SplotchID}
+		if changes := cat.MoodChanges(patted, patted.Add(24*time.Hour)); len(changes) != 0 {
+			t.Errorf("Got: transitions %v of a cat that was never patted. Want: none.", changes)
+		}
+	})
+}
+
+func TestCat_SwingPoints(t *testing.T) {
+	t.Parallel()
+	patted := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	cat := Cat{ID: SplotchID, LatestPat: patted, Noise: NoiseHash, Personality: Personality{Moodiness: 1, Sleepiness: -1}}
+	from := patted.Add(cat.happyWindow())
+	to := from.Add(6 * time.Hour)
+	points := append(cat.swingPoints(from, to), to)
+
+	// The shifted time since the pat, which the classic model compares with
+	// the happy window, must be monotonic between the points.
+	shifted := func(at time.Time) time.Duration {
+		swing := cat.moodSwing()
+		return at.Sub(patted) + behavior.Spread(-swing, swing, cat.swingNoise().At(at))
+	}
+	turns := 0
+	for i := 1; i < len(points); i++ {
+		var prev time.Duration
+		var dir int
+		for at := points[i-1]; at.Before(points[i]); at = at.Add(time.Second) {
+			v := shifted(at)
+			if at != points[i-1] {
+				d := cmp.Compare(v, prev)
+				if dir != 0 && d != 0 && d != dir {
+					t.Fatalf("Got: shifted time since the pat turns at %v, between swing points %v and %v. Want: monotonic.", at, points[i-1], points[i])
+				}
+				if d != 0 {
+					dir = d
+				}
+			}
+			prev = v
+		}
+		if dir < 0 {
+			turns++
+		}
+	}
+	if turns == 0 {
+		t.Errorf("Got: the shifted time since the pat never decreases. Want: some steep swings to test with.")
+	}
+}
+
+func TestRecordMoodChanges(t *testing.T) {
+	tx := dbtest.InMemory(t)
+	tx.AutoMigrate(Cat{}, Journal{}, Webhook{}, Delivery{})
+
+	patted := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	cat := Cat{ID: SplotchID, Name: "Splotch", LatestPat: patted}
+	dbtest.Save(t, tx, &cat)
+	want := cat.MoodChanges(patted, patted.Add(time.Hour))
+	if want[0].From != MoodPat {
+		t.Fatalf("Got: first mood change %v. Want: the end of the pat.", want[0])
+	}
+	// The end of the pat isn't recorded.
+	want = want[1:]
+
+	// The interval before the pat is skipped.
+	for range 2 {
+		n, err := RecordMoodChanges(tx, cat, patted.Add(-time.Hour), patted.Add(time.Hour))
+		if err != nil {
+			t.Fatalf("Got: RecordMoodChanges() returned error: %s. Want: no error.", err)
+		}
+		if n != len(want) && n != 0 {
+			t.Errorf("Got: RecordMoodChanges() added %d records. Want: %d the first time and 0 after.", n, len(want))
+		}
+	}
+
+	var records []Journal
+	tx.Order("created_at").Find(&records)
+	if len(records) != len(want) {
+		t.Fatalf("Got: %d journal records. Want: %d.", len(records), len(want))
+	}
+	for i, r := range records {
+		if !r.CreatedAt.Equal(want[i].At) || r.Event.Type != EventMoodChanged || r.Event.PrevMood != want[i].From || r.Event.Mood != want[i].To || r.Event.Description == "" {
+			t.Errorf("Got: journal record %+v. Want: described %v.", r, want[i])
+		}
+	}
+
+	last := want[len(want)-1]
+	since, err := MoodSince(tx, cat, patted.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Got: MoodSince() returned error: %s. Want: no error.", err)
+	}
+	if !since.Equal(last.At) {
+		t.Errorf("Got: MoodSince() = %v. Want: %v.", since, last.At)
+	}
+}
+
+func TestMoodSince(t *testing.T) {
+	patted := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	cat := Cat{ID: SplotchID, Name: "Splotch", LatestPat: patted.Add(-30 * 24 * time.Hour)}
+	impatient := patted.Add(-3 * 24 * time.Hour)
+
+	tests := []struct {
+		name    string
+		cat     Cat
+		records []Journal
+		now     time.Time
+		want    time.Time
+	}{{
+		name:    "unknown",
+		cat:     cat,
+		records: []Journal{{CreatedAt: impatient, Event: Event{Type: EventFeed}}},
+		now:     patted,
+	}, {
+		name: "interaction without a mood change",
+		cat:  cat,
+		records: []Journal{
+			{CreatedAt: impatient, Event: Event{Type: EventMoodChanged, PrevMood: MoodIdle, Mood: MoodImpatient}},
+			{CreatedAt: impatient.Add(time.Hour), Event: Event{Type: EventFeed, PrevMood: MoodImpatient, Mood: MoodImpatient}},
+		},
+		now:  patted.Add(-time.Minute),
+		want: impatient,
+	}, {
+		name: "during a pat",
+		cat:  Cat{ID: SplotchID, Name: "Splotch", LatestPat: patted},
+		records: []Journal{
+			{CreatedAt: patted, Event: Event{Type: EventPat, PrevMood: MoodImpatient, Mood: MoodPat}},
+		},
+		now:  patted.Add(time.Second),
+		want: patted,
+	}, {
+		name: "after a pat",
+		cat:  Cat{ID: SplotchID, Name: "Splotch", LatestPat: patted},
+		records: []Journal{
+			{CreatedAt: patted, Event: Event{Type: EventPat, PrevMood: MoodImpatient, Mood: MoodPat}},
+		},
+		now:  patted.Add(time.Minute),
+		want: patted.Add(patDuration),
+	}, {
+		name: "mood changed after a pat",
+		cat:  Cat{ID: SplotchID, Name: "Splotch", LatestPat: patted},
+		records: []Journal{
+			{CreatedAt: patted, Event: Event{Type: EventPat, PrevMood: MoodImpatient, Mood: MoodPat}},
+		},
+		now: patted.Add(30 * 24 * time.Hour),
+	}}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tx := dbtest.InMemory(t)
+			tx.AutoMigrate(Cat{}, Journal{})
+			for _, r := range tc.records {
+				r.CatID = SplotchID
+				dbtest.Save(t, tx, &r)
+			}
+			got, err := MoodSince(tx, tc.cat, tc.now)
+			if err != nil {
+				t.Fatalf("Got: MoodSince() returned error: %s. Want: no error.", err)
+			}
+			if !got.Equal(tc.want) {
+				t.Errorf("Got: MoodSince() = %v. Want: %v.", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package db
+
+import (
+	"time"
+
+	"github.com/nevkontakte/pat/narrative"
+)
+
+// Narrator describes journal events. Register more phrases with it to extend
+// the defaults.
+var Narrator = defaultNarrator()
+
+func defaultNarrator() *narrative.Narrator {
+	n := &narrative.Narrator{}
+	phrases := func(event EventType, mood Mood, phrases ...string) {
+		n.Register(event.String(), string(mood), phrases...)
+	}
+
+	phrases(EventVisit, "",
+		"{visitor} dropped by to see {cat}.",
+		"{visitor} peeked in on {cat}.",
+		"{visitor} came to say hi to {cat}.")
+	phrases(EventVisit, MoodIdleBlink,
+		"{visitor} found {cat} dozing.",
+		"{visitor} tiptoed past a sleepy {cat}.")
+	phrases(EventVisit, MoodImpatient,
+		"{visitor} looked in on {cat}, who stared back grumpily.",
+		"{cat} meowed loudly at {visitor}.")
+
+	phrases(EventPat, "",
+		"{cat} got a pat from {visitor}.",
+		"{visitor} gave {cat} a gentle pat.",
+		"{cat} accepted a pat from {visitor}.")
+	phrases(EventPat, MoodIdleHappy,
+		"{cat} leaned into the pat from {visitor}.",
+		"{cat} purred as {visitor} patted them.",
+		"{cat} rolled over for more pats from {visitor}.")
+	phrases(EventPat, MoodPat,
+		"{cat} got yet another pat, this time from {visitor}, and purred louder.",
+		"{visitor} joined in patting {cat}.")
+	phrases(EventPat, MoodIdleBlink,
+		"{cat} blinked slowly at {visitor} and accepted a pat.",
+		"{visitor} woke {cat} up with a pat.")
+	phrases(EventPat, MoodImpatient,
+		"{cat} ignored the pat from {visitor}.",
+		"{cat} flicked their tail at the pat from {visitor}.")
+
+	phrases(EventFeed, "",
+		"{visitor} fed {cat}.",
+		"{cat} gobbled up the food from {visitor}.",
+		"{visitor} filled {cat}'s bowl.")
+	phrases(EventFeed, MoodImpatient,
+		"{cat} pounced on the food from {visitor}.",
+		"{visitor} fed {cat} just in time.")
+	phrases(EventPlay, "",
+		"{visitor} waved a toy and {cat} chased it.",
+		"{cat} batted at the toy {visitor} dangled.",
+		"{cat} wrestled a toy mouse with {visitor}.")
+	phrases(EventBrush, "",
+		"{visitor} brushed {cat}, who purred the whole time.",
+		"{cat} got a brushing from {visitor}.")
+	phrases(EventTreat, "",
+		"{visitor} gave {cat} a treat.",
+		"{cat} crunched a treat from {visitor}.",
+		"{cat} begged {visitor} for a treat and got one.")
+	phrases(EventSleep, "",
+		"{visitor} dimmed the lights and {cat} curled up for a nap.",
+		"{visitor} tucked {cat} in for a nap.")
+
+	phrases(EventNapped, "",
+		"{cat} took a nap.",
+		"{cat} curled up in a sunbeam for a nap.",
+		"{cat} dozed off on the windowsill.")
+	phrases(EventChasedFly, "",
+		"{cat} chased a fly.",
+		"{cat} chased a fly around the room and missed.",
+		"{cat} caught a fly, then let it go.")
+	phrases(EventStaredAtWall, "",
+		"{cat} stared at the wall for a while.",
+		"{cat} stared intently at an empty corner.")
+	phrases(EventZoomies, "",
+		"{cat} got the zoomies.",
+		"{cat} sprinted through the house for no reason.",
+		"{cat} raced up and down the hallway.")
+
+	// Mood changes are described by the new mood.
+	phrases(EventMoodChanged, "",
+		"{cat}'s mood changed.")
+	phrases(EventMoodChanged, MoodIdle,
+		"{cat} settled down.",
+		"{cat} went back to just chillin'.")
+	phrases(EventMoodChanged, MoodIdleHappy,
+		"{cat} remembered a nice pat and perked up.",
+		"{cat} looks pleased with life.")
+	phrases(EventMoodChanged, MoodIdleBlink,
+		"{cat} started dozing off.",
+		"{cat}'s eyes are getting heavy.")
+	phrases(EventMoodChanged, MoodImpatient,
+		"{cat} got impatient.",
+		"{cat} started pacing around, demanding attention.")
+	return n
+}
+
+// Describe returns a description of the event that happened to the cat at the
+// given time, based on the cat's mood at that time. The visitor is nil for
+// autonomous events.
+//
+// Events before the latest interaction with the cat are described without the
+// mood, which the cat's current state doesn't tell.
+func (c Cat) Describe(event EventType, at time.Time, v *Visitor) string {
+	ctx := narrative.Context{Cat: c.Name, Visitor: v.Name()}
+	noise := c.rawNoise(c.ID.Seed("narrative"))
+	mood := Mood("")
+	if !at.Before(c.ChangedAt()) {
+		mood = c.MoodAt(at)
+	}
+	return Narrator.Describe(noise, at, event.String(), string(mood), ctx)
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCat_Describe(t *testing.T) {
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	firefox := &Visitor{Agent: "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0"}
+
+	// bankOf returns the phrases for the event and mood, filled in for Splotch
+	// and the visitor in Firefox.
+	bankOf := func(event EventType, mood Mood) []string {
+		var phrases []string
+		for _, p := range Narrator.Bank(event.String(), string(mood)) {
+			p = strings.NewReplacer("{cat}", "Splotch", "{visitor}", "a visitor in Firefox").Replace(p)
+			phrases = append(phrases, p)
+		}
+		return phrases
+	}
+
+	tests := []struct {
+		name    string
+		cat     Cat
+		event   EventType
+		visitor *Visitor
+		mood    Mood
+	}{
+		{"happy pat", Cat{LatestPat: now.Add(-time.Minute)}, EventPat, firefox, MoodIdleHappy},
+		{"impatient pat", Cat{LatestPat: now.Add(-30 * 24 * time.Hour)}, EventPat, firefox, MoodImpatient},
+		{"visit", Cat{LatestPat: now.Add(-time.Hour)}, EventVisit, firefox, ""},
+		{"activity", Cat{LatestPat: now.Add(-time.Hour)}, EventZoomies, nil, ""},
+		{"before the latest pat", Cat{LatestPat: now.Add(time.Second)}, EventPat, firefox, ""},
+		{"before the latest meal", Cat{LatestPat: now.Add(-30 * 24 * time.Hour), Needs: Needs{Food: now.Add(time.Hour)}}, EventPat, firefox, ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cat := tc.cat
+			cat.ID = SplotchID
+			cat.Name = "Splotch"
+			got := cat.Describe(tc.event, now, tc.visitor)
+			if bank := bankOf(tc.event, tc.mood); !slices.Contains(bank, got) {
+				t.Errorf("Got: Describe() = %q. Want: one of %q.", got, bank)
+			}
+		})
+	}
+
+	for _, event := range []EventType{EventVisit, EventPat, EventFeed, EventPlay, EventBrush, EventTreat, EventSleep, EventNapped, EventChasedFly, EventStaredAtWall, EventZoomies} {
+		if len(Narrator.Bank(event.String(), "")) == 0 {
+			t.Errorf("Got: no phrases for %v events. Want: some.", event)
+		}
+	}
+}
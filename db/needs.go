@@ -0,0 +1,128 @@
+package db
+
+import (
+	"fmt"
+	"time"
+)
+
+// Need is something the cat requires regularly. Each need is fully satisfied
+// by an interaction and depletes over time.
+type Need string
+
+const (
+	NeedFood  Need = "food"  // Restored by feeding the cat.
+	NeedPlay  Need = "play"  // Restored by playing with the cat.
+	NeedSleep Need = "sleep" // Restored by letting the cat sleep.
+)
+
+// AllNeeds lists all needs in display order.
+var AllNeeds = []Need{NeedFood, NeedPlay, NeedSleep}
+
+// Valid returns true for known needs.
+func (n Need) Valid() bool {
+	return n == NeedFood || n == NeedPlay || n == NeedSleep
+}
+
+// lowNeed is the level below which a need affects the cat's mood.
+const lowNeed = 0.25
+
+// depletion is how long it takes an average cat to go from a satisfied need to
+// a completely unsatisfied one.
+var depletion = map[Need]time.Duration{
+	NeedFood:  12 * time.Hour,
+	NeedPlay:  2 * 24 * time.Hour,
+	NeedSleep: 16 * time.Hour,
+}
+
+// Needs records when each of the cat's needs was last satisfied.
+//
+// A zero time means the need has never been tracked for the cat, in which case
+// it's considered satisfied, so that cats created before needs were introduced
+// behave as they always did.
+type Needs struct {
+	Food  time.Time
+	Play  time.Time
+	Sleep time.Time
+}
+
+// at returns a pointer to the time when the need was last satisfied.
+func (n *Needs) at(need Need) *time.Time {
+	switch need {
+	case NeedFood:
+		return &n.Food
+	case NeedPlay:
+		return &n.Play
+	case NeedSleep:
+		return &n.Sleep
+	default:
+		panic(fmt.Errorf("unknown need %q", need))
+	}
+}
+
+// depletion returns how long it takes for the cat's need to run out.
+func (c Cat) depletion(need Need) time.Duration {
+	if need == NeedSleep {
+		// Sleepy cats get tired faster.
+		return scaleDuration(depletion[need], -c.Personality.Sleepiness)
+	}
+	return depletion[need]
+}
+
+// NeedLevel returns how satisfied the cat's need is at the given time, from 1
+// right after it was satisfied down to 0 once it ran out.
+func (c Cat) NeedLevel(need Need, now time.Time) float64 {
+	satisfied := *c.Needs.at(need)
+	if satisfied.IsZero() {
+		return 1
+	}
+	level := 1 - float64(now.Sub(satisfied))/float64(c.depletion(need))
+	return min(max(level, 0), 1)
+}
+
+// Lacks returns true if the cat's need is low enough to affect its mood.
+func (c Cat) Lacks(need Need, now time.Time) bool {
+	return c.NeedLevel(need, now) < lowNeed
+}
+
+// lacksSince returns the earliest time when the cat lacks the need, or zero
+// time if the need isn't tracked.
+func (c Cat) lacksSince(need Need) time.Time {
+	satisfied := *c.Needs.at(need)
+	if satisfied.IsZero() {
+		return time.Time{}
+	}
+	t := satisfied.Add(time.Duration((1 - lowNeed) * float64(c.depletion(need))))
+	// Correct for rounding in NeedLevel, so that the time is exact.
+	for !c.Lacks(need, t) {
+		t = t.Add(1)
+	}
+	for c.Lacks(need, t.Add(-1)) {
+		t = t.Add(-1)
+	}
+	return t
+}
+
+// column returns the name of the Needs column for the need.
+func (n Need) column() string {
+	return "needs_" + string(n)
+}
+
+// needsMood adjusts the mood given by the mood model to the cat's needs.
+func (c Cat) needsMood(now time.Time, mood Mood) Mood {
+	if c.Lacks(NeedFood, now) {
+		// Hungry cats ignore pats and demand food.
+		return MoodImpatient
+	}
+	if mood != MoodIdle {
+		return mood
+	}
+	if c.Lacks(NeedSleep, now) {
+		// Tired cats doze off.
+		return MoodIdleBlink
+	}
+	if c.Lacks(NeedPlay, now) {
+		// Bored cats look for trouble.
+		return MoodImpatient
+	}
+	return mood
+}
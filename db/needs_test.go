@@ -0,0 +1,84 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCat_NeedLevel(t *testing.T) {
+	fed := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	cat := Cat{ID: SplotchID, Needs: Needs{Food: fed}}
+
+	tests := []struct {
+		need    Need
+		elapsed time.Duration
+		want    float64
+	}{
+		{NeedFood, 0, 1},
+		{NeedFood, 6 * time.Hour, 0.5},
+		{NeedFood, 12 * time.Hour, 0},
+		{NeedFood, 48 * time.Hour, 0},
+		{NeedFood, -time.Hour, 1},
+		{NeedSleep, 48 * time.Hour, 1}, // Never tracked.
+	}
+	for _, tc := range tests {
+		if got := cat.NeedLevel(tc.need, fed.Add(tc.elapsed)); got != tc.want {
+			t.Errorf("Got: NeedLevel(%q) = %v after %v. Want: %v.", tc.need, got, tc.elapsed, tc.want)
+		}
+	}
+
+	rested := Cat{ID: SplotchID, Needs: Needs{Sleep: fed}}
+	sleepy := rested
+	sleepy.Personality.Sleepiness = 1
+	if r, s := rested.NeedLevel(NeedSleep, fed.Add(4*time.Hour)), sleepy.NeedLevel(NeedSleep, fed.Add(4*time.Hour)); s >= r {
+		t.Errorf("Got: sleepy cat's energy %v, average cat's %v. Want: sleepy cat tired sooner.", s, r)
+	}
+}
+
+func TestCat_NeedsMood(t *testing.T) {
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	longAgo := now.Add(-7 * 24 * time.Hour)
+
+	tests := []struct {
+		name string
+		cat  Cat
+		want Mood
+	}{
+		{
+			name: "hungry cat ignores pats",
+			cat:  Cat{LatestPat: now, Needs: Needs{Food: longAgo}},
+			want: MoodImpatient,
+		}, {
+			name: "fed cat enjoys pats",
+			cat:  Cat{LatestPat: now, Needs: Needs{Food: now}},
+			want: MoodPat,
+		}, {
+			name: "tired cat dozes off",
+			cat:  Cat{LatestPat: now.Add(-24 * time.Hour), Needs: Needs{Sleep: longAgo}},
+			want: MoodIdleBlink,
+		}, {
+			name: "bored cat looks for trouble",
+			cat:  Cat{LatestPat: now.Add(-24 * time.Hour), Needs: Needs{Play: longAgo}},
+			want: MoodImpatient,
+		}, {
+			name: "tired cat is still happy after a pat",
+			cat:  Cat{LatestPat: now.Add(-time.Minute), Needs: Needs{Sleep: longAgo}},
+			want: MoodIdleHappy,
+		},
+	}
+	for _, tc := range tests {
+		for _, model := range []MoodModel{MoodModelClassic, MoodModelMarkov} {
+			cat := tc.cat
+			cat.ID = SplotchID
+			cat.Model = model
+			if got := cat.MoodAt(now); got != tc.want {
+				t.Errorf("%s: Got: MoodAt() = %q for the %s model. Want: %q.", tc.name, got, model, tc.want)
+			}
+			for _, got := range cat.Moods(now, now, time.Minute) {
+				if got != tc.want {
+					t.Errorf("%s: Got: Moods() = %q for the %s model. Want: %q.", tc.name, got, model, tc.want)
+				}
+			}
+		}
+	}
+}
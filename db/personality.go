@@ -0,0 +1,74 @@
+package db
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Personality adjusts the cat's behavior relative to an average cat.
+//
+// Each trait is in the [-1, 1] range and scales the related durations or
+// probabilities by 2^trait: from half to twice the average. The zero value is
+// the average cat.
+type Personality struct {
+	// Clinginess makes the cat stay happy longer after a pat.
+	Clinginess float64
+	// Patience delays the cat getting impatient without pats.
+	Patience float64
+	// Moodiness makes mood swings stronger.
+	Moodiness float64
+	// Sleepiness makes the cat doze off more and change moods slower.
+	Sleepiness float64
+}
+
+// Traits lists the personality traits along with their names, for display and
+// editing.
+func (p *Personality) Traits() []Trait {
+	return []Trait{
+		{Name: "clinginess", Label: "Clinginess", Value: &p.Clinginess},
+		{Name: "patience", Label: "Patience", Value: &p.Patience},
+		{Name: "moodiness", Label: "Moodiness", Value: &p.Moodiness},
+		{Name: "sleepiness", Label: "Sleepiness", Value: &p.Sleepiness},
+	}
+}
+
+// Trait is a named reference to a Personality field.
+type Trait struct {
+	Name  string // Machine-readable name.
+	Label string // Human-readable name.
+	Value *float64
+}
+
+// Validate returns an error if any trait is outside of the [-1, 1] range.
+func (p Personality) Validate() error {
+	for _, t := range p.Traits() {
+		if math.IsNaN(*t.Value) || *t.Value < -1 || *t.Value > 1 {
+			return fmt.Errorf("%s must be between -1 and 1, got %v", t.Name, *t.Value)
+		}
+	}
+	return nil
+}
+
+// scale returns the multiplier for the trait value.
+func scale(trait float64) float64 {
+	return math.Exp2(trait)
+}
+
+// scaleDuration multiplies the duration by the trait's multiplier.
+func scaleDuration(d time.Duration, trait float64) time.Duration {
+	if trait == 0 {
+		return d // Avoid rounding errors for the average cat.
+	}
+	return time.Duration(float64(d) * scale(trait))
+}
+
+// String formats the personality as a list of traits.
+func (p Personality) String() string {
+	var parts []string
+	for _, t := range p.Traits() {
+		parts = append(parts, fmt.Sprintf("%s=%g", t.Name, *t.Value))
+	}
+	return strings.Join(parts, ", ")
+}
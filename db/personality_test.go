@@ -0,0 +1,119 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nevkontakte/pat/db/dbtest"
+	"gorm.io/gorm"
+)
+
+func TestPersonality_Validate(t *testing.T) {
+	valid := []Personality{{}, {Clinginess: -1, Patience: 1, Moodiness: 0.5, Sleepiness: -0.5}}
+	for _, p := range valid {
+		if err := p.Validate(); err != nil {
+			t.Errorf("Got: %+v.Validate() returned error: %s. Want: no error.", p, err)
+		}
+	}
+	invalid := []Personality{{Clinginess: 1.5}, {Patience: -2}, {Moodiness: 100}}
+	for _, p := range invalid {
+		if err := p.Validate(); err == nil {
+			t.Errorf("Got: %+v.Validate() succeeded. Want: error.", p)
+		}
+	}
+}
+
+func TestCat_Personality(t *testing.T) {
+	t.Parallel()
+	patted := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// shareOf returns the share of samples in the given mood over the period
+	// after a pat.
+	shareOf := func(cat Cat, mood Mood, from, until time.Duration) float64 {
+		cat.LatestPat = patted
+		total, matched := 0, 0
+		for _, m := range cat.Moods(patted.Add(from), patted.Add(until), time.Minute) {
+			total++
+			if m == mood {
+				matched++
+			}
+		}
+		return float64(matched) / float64(total)
+	}
+
+	for _, model := range []MoodModel{MoodModelClassic, MoodModelMarkov} {
+		t.Run(string(model), func(t *testing.T) {
+			average := Cat{ID: SplotchID, Noise: NoiseHash, Model: model}
+
+			clingy := average
+			clingy.Personality.Clinginess = 1
+			aloof := average
+			aloof.Personality.Clinginess = -1
+			if c, a := shareOf(clingy, MoodIdleHappy, 0, 3*time.Hour), shareOf(aloof, MoodIdleHappy, 0, 3*time.Hour); c <= a {
+				t.Errorf("Got: clingy cat is happy %.2f of the time, aloof one %.2f. Want: clingy cat happy longer.", c, a)
+			}
+
+			patient := average
+			patient.Personality.Patience = 1
+			impatient := average
+			impatient.Personality.Patience = -1
+			week := 7 * 24 * time.Hour
+			if p, i := shareOf(patient, MoodImpatient, 0, week), shareOf(impatient, MoodImpatient, 0, week); p >= i {
+				t.Errorf("Got: patient cat is impatient %.2f of the week, impatient one %.2f. Want: patient cat less impatient.", p, i)
+			}
+			if got := shareOf(patient, MoodImpatient, 0, week); got > 0 {
+				t.Errorf("Got: the most patient cat is impatient %.2f of the week. Want: never.", got)
+			}
+		})
+	}
+}
+
+func TestCat_Moods(t *testing.T) {
+	t.Parallel()
+	patted := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, model := range []MoodModel{MoodModelClassic, MoodModelMarkov} {
+		cat := Cat{ID: SplotchID, LatestPat: patted, Noise: NoiseHash, Model: model, Personality: Personality{Moodiness: 0.5}}
+		from := patted.Add(-time.Minute)
+		until := patted.Add(8 * 24 * time.Hour)
+		samples := 0
+		for at, mood := range cat.Moods(from, until, 7*time.Minute+3*time.Second) {
+			samples++
+			if want := cat.MoodAt(at); mood != want {
+				t.Fatalf("Got: Moods() yields %q at %v for the %s model. Want: %q from MoodAt().", mood, at, model, want)
+			}
+		}
+		if samples == 0 {
+			t.Errorf("Got: Moods() yielded no samples for the %s model. Want: some.", model)
+		}
+	}
+}
+
+func TestSetPersonality(t *testing.T) {
+	tx := dbtest.InMemory(t)
+	tx.AutoMigrate(Cat{})
+	dbtest.Save(t, tx, &Cat{ID: SplotchID, Name: "Splotch"})
+
+	want := Personality{Clinginess: 0.5, Patience: -0.25, Moodiness: 1, Sleepiness: -1}
+	if err := SetPersonality(tx, SplotchID, want); err != nil {
+		t.Fatalf("Got: SetPersonality() returned error: %s. Want: no error.", err)
+	}
+	cat, err := CatByID(tx, SplotchID)
+	if err != nil {
+		t.Fatalf("Got: CatByID() returned error: %s. Want: no error.", err)
+	}
+	if cat.Personality != want {
+		t.Errorf("Got: personality %+v. Want: %+v.", cat.Personality, want)
+	}
+
+	if err := SetPersonality(tx, SplotchID, Personality{Patience: 2}); err == nil {
+		t.Errorf("Got: SetPersonality() with an out of range trait succeeded. Want: error.")
+	}
+	if err := SetPersonality(tx, "stray", want); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Got: SetPersonality() for a missing cat returned error: %v. Want: %v.", err, gorm.ErrRecordNotFound)
+	}
+	// Setting the same personality again still finds the cat.
+	if err := SetPersonality(tx, SplotchID, want); err != nil {
+		t.Errorf("Got: repeated SetPersonality() returned error: %s. Want: no error.", err)
+	}
+}
@@ -0,0 +1,150 @@
+package db
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nevkontakte/pat/chrono"
+	"gorm.io/gorm"
+)
+
+// Scope limits what an API token can be used for.
+type Scope string
+
+const (
+	ScopeReadJournal Scope = "journal:read" // Read and export the journal.
+	ScopeManageCats  Scope = "cats:manage"  // Create and update cats.
+	ScopeModerate    Scope = "moderate"     // Remove journal entries.
+)
+
+// AllScopes lists all known scopes.
+var AllScopes = []Scope{ScopeReadJournal, ScopeManageCats, ScopeModerate}
+
+// Role returns the least privileged role allowed to use the scope.
+func (s Scope) Role() Role {
+	switch s {
+	case ScopeReadJournal:
+		return RoleViewer
+	case ScopeModerate:
+		return RoleModerator
+	case ScopeManageCats:
+		return RoleOwner
+	default:
+		return ""
+	}
+}
+
+// Scopes is a set of scopes, stored in the database as a comma-separated list.
+type Scopes []Scope
+
+// Has returns true if any of the given scopes is in the set.
+func (s Scopes) Has(scopes ...Scope) bool {
+	for _, scope := range scopes {
+		if slices.Contains(s, scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// Value implements driver.Valuer.
+func (s Scopes) Value() (driver.Value, error) {
+	parts := make([]string, len(s))
+	for i, scope := range s {
+		parts[i] = string(scope)
+	}
+	return strings.Join(parts, ","), nil
+}
+
+// Scan implements sql.Scanner. It accepts string or []byte input.
+func (s *Scopes) Scan(src any) error {
+	var raw string
+	switch src := src.(type) {
+	case string:
+		raw = src
+	case []byte:
+		raw = string(src)
+	default:
+		return fmt.Errorf("unsupported Scopes source type %T", src)
+	}
+	*s = nil
+	for part := range strings.SplitSeq(raw, ",") {
+		if part != "" {
+			*s = append(*s, Scope(part))
+		}
+	}
+	return nil
+}
+
+// APIToken is a personal token that lets automation act on behalf of an admin
+// account, within the limits of the token scopes.
+//
+// Only a hash of the token is stored, see HashToken.
+type APIToken struct {
+	ID        uint64 `gorm:"primaryKey"`
+	CreatedAt time.Time
+	// Admin is the account the token belongs to. The token can't be used for
+	// anything the account itself isn't allowed to do.
+	Admin      string `gorm:"index"`
+	Name       string // Human-readable token name.
+	Hash       []byte `gorm:"uniqueIndex"`
+	Scopes     Scopes `gorm:"type:text"`
+	LastUsedAt time.Time
+}
+
+// CreateAPIToken stores a new token for the admin account.
+func CreateAPIToken(tx *gorm.DB, admin string, name string, scopes Scopes, token string) (APIToken, error) {
+	for _, scope := range scopes {
+		if !scope.Role().Valid() {
+			return APIToken{}, fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+	t := APIToken{
+		Admin:  admin,
+		Name:   name,
+		Hash:   HashToken(token),
+		Scopes: scopes,
+	}
+	if result := tx.Create(&t); result.Error != nil {
+		return APIToken{}, result.Error
+	}
+	return t, nil
+}
+
+// APITokens returns all tokens of the admin account, newest first.
+func APITokens(tx *gorm.DB, admin string) ([]APIToken, error) {
+	var tokens []APIToken
+	if result := tx.Where("admin = ?", admin).Order("id desc").Find(&tokens); result.Error != nil {
+		return nil, result.Error
+	}
+	return tokens, nil
+}
+
+// UseAPIToken looks up the token and records its use at the current time.
+//
+// Returns gorm.ErrRecordNotFound if the token doesn't exist or was revoked.
+func UseAPIToken(tx *gorm.DB, clock chrono.Clock, token string) (APIToken, error) {
+	var t APIToken
+	if result := tx.First(&t, "hash = ?", HashToken(token)); result.Error != nil {
+		return APIToken{}, result.Error
+	}
+	if result := tx.Model(&t).Update("last_used_at", clock.Now()); result.Error != nil {
+		return APIToken{}, result.Error
+	}
+	return t, nil
+}
+
+// RevokeAPIToken deletes the admin account's token with the given ID.
+func RevokeAPIToken(tx *gorm.DB, admin string, id uint64) error {
+	result := tx.Where("admin = ? AND id = ?", admin, id).Delete(&APIToken{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != 1 {
+		return fmt.Errorf("deleted %d rows: %w", result.RowsAffected, gorm.ErrRecordNotFound)
+	}
+	return nil
+}
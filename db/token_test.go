@@ -0,0 +1,75 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nevkontakte/pat/chrono/chronotest"
+	"github.com/nevkontakte/pat/db/dbtest"
+	"gorm.io/gorm"
+)
+
+func TestScopes_ValueScanRoundtrip(t *testing.T) {
+	for _, scopes := range []Scopes{nil, {ScopeReadJournal}, {ScopeReadJournal, ScopeModerate}} {
+		value, err := scopes.Value()
+		if err != nil {
+			t.Fatalf("Scopes.Value() returned error: %v", err)
+		}
+		var got Scopes
+		if err := got.Scan(value); err != nil {
+			t.Fatalf("Scopes.Scan(%q) returned error: %v", value, err)
+		}
+		if diff := cmp.Diff(scopes, got); diff != "" {
+			t.Errorf("Scopes roundtrip mismatch (-want +got):\n%s", diff)
+		}
+	}
+}
+
+func TestAPIToken(t *testing.T) {
+	tx := dbtest.InMemory(t)
+	tx.AutoMigrate(&APIToken{})
+
+	if _, err := CreateAPIToken(tx, "alice", "bad", Scopes{"root"}, "secret-0"); err == nil {
+		t.Errorf("Got: CreateAPIToken() with unknown scope succeeded. Want: error.")
+	}
+
+	created, err := CreateAPIToken(tx, "alice", "export", Scopes{ScopeReadJournal}, "secret-1")
+	if err != nil {
+		t.Fatalf("Got: CreateAPIToken() returned error: %s. Want: no error.", err)
+	}
+	if _, err := CreateAPIToken(tx, "bob", "cats", Scopes{ScopeManageCats}, "secret-2"); err != nil {
+		t.Fatalf("Got: CreateAPIToken() returned error: %s. Want: no error.", err)
+	}
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := chronotest.NewClock(now)
+	used, err := UseAPIToken(tx, clock, "secret-1")
+	if err != nil {
+		t.Fatalf("Got: UseAPIToken() returned error: %s. Want: no error.", err)
+	}
+	if used.ID != created.ID || used.Admin != "alice" || !used.Scopes.Has(ScopeReadJournal) || used.Scopes.Has(ScopeModerate) {
+		t.Errorf("Got: UseAPIToken() = %+v. Want: alice's export token.", used)
+	}
+	tokens, err := APITokens(tx, "alice")
+	if err != nil {
+		t.Fatalf("Got: APITokens() returned error: %s. Want: no error.", err)
+	}
+	if len(tokens) != 1 || !tokens[0].LastUsedAt.Equal(now) {
+		t.Errorf("Got: APITokens() = %+v. Want: a single token last used at %v.", tokens, now)
+	}
+
+	if _, err := UseAPIToken(tx, clock, "wrong"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Got: UseAPIToken() with unknown token returned error: %v. Want: %v.", err, gorm.ErrRecordNotFound)
+	}
+	if err := RevokeAPIToken(tx, "bob", created.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Got: RevokeAPIToken() of another admin's token returned error: %v. Want: %v.", err, gorm.ErrRecordNotFound)
+	}
+	if err := RevokeAPIToken(tx, "alice", created.ID); err != nil {
+		t.Fatalf("Got: RevokeAPIToken() returned error: %s. Want: no error.", err)
+	}
+	if _, err := UseAPIToken(tx, clock, "secret-1"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Got: UseAPIToken() after revocation returned error: %v. Want: %v.", err, gorm.ErrRecordNotFound)
+	}
+}
@@ -0,0 +1,338 @@
+package db
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// EventTypes is a set of event types, stored in the database as a
+// comma-separated list of names.
+type EventTypes []EventType
+
+// Value implements driver.Valuer.
+func (e EventTypes) Value() (driver.Value, error) {
+	parts := make([]string, len(e))
+	for i, t := range e {
+		parts[i] = t.String()
+	}
+	return strings.Join(parts, ","), nil
+}
+
+// Scan implements sql.Scanner. It accepts string or []byte input.
+func (e *EventTypes) Scan(src any) error {
+	var raw string
+	switch src := src.(type) {
+	case string:
+		raw = src
+	case []byte:
+		raw = string(src)
+	default:
+		return fmt.Errorf("unsupported EventTypes source type %T", src)
+	}
+	*e = nil
+	for part := range strings.SplitSeq(raw, ",") {
+		if part == "" {
+			continue
+		}
+		t, ok := ParseEventType(part)
+		if !ok {
+			return fmt.Errorf("unknown event type %q", part)
+		}
+		*e = append(*e, t)
+	}
+	return nil
+}
+
+// WebhookEvents lists event types webhooks may subscribe to.
+var WebhookEvents = publicEvents
+
+// Webhook subscribes an external URL to journal events that match all of its
+// filters. Matching events are delivered as JSON-encoded WebhookPayload.
+type Webhook struct {
+	ID        uint64 `gorm:"primaryKey"`
+	CreatedAt time.Time
+	Admin     string // Account that created the subscription.
+	URL       string
+	// Secret is the key for HMAC-SHA256 signatures of the payloads, so that
+	// the receiver can verify they come from us.
+	Secret string
+	// Events the subscription is limited to. Empty means all WebhookEvents.
+	Events EventTypes `gorm:"type:text"`
+	// CatID limits the subscription to events about the cat. Empty means all
+	// cats.
+	CatID CatID
+	// FromMood and ToMood limit the subscription to events that changed the
+	// cat's mood from and to the given moods. Empty means any mood, and if
+	// both are empty the mood doesn't matter at all.
+	FromMood Mood
+	ToMood   Mood
+}
+
+// Validate returns an error describing the first invalid subscription field.
+func (w Webhook) Validate() error {
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("URL must be an absolute http or https URL")
+	}
+	for _, t := range w.Events {
+		if !slices.Contains(WebhookEvents, t) {
+			return fmt.Errorf("webhooks can't subscribe to %q events", t)
+		}
+	}
+	for _, m := range []Mood{w.FromMood, w.ToMood} {
+		if m != "" && !slices.Contains(AllMoods, m) {
+			return fmt.Errorf("unknown mood %q", m)
+		}
+	}
+	return nil
+}
+
+// Matches returns true if the journal record passes the subscription filters.
+func (w Webhook) Matches(r Journal) bool {
+	if !slices.Contains(WebhookEvents, r.Event.Type) {
+		return false
+	}
+	if len(w.Events) > 0 && !slices.Contains(w.Events, r.Event.Type) {
+		return false
+	}
+	if w.CatID != "" && w.CatID != r.CatID {
+		return false
+	}
+	if w.FromMood != "" || w.ToMood != "" {
+		if !r.Event.MoodChanged() {
+			return false
+		}
+		if w.FromMood != "" && w.FromMood != r.Event.PrevMood {
+			return false
+		}
+		if w.ToMood != "" && w.ToMood != r.Event.Mood {
+			return false
+		}
+	}
+	return true
+}
+
+// CreateWebhook validates and stores a new subscription.
+func CreateWebhook(tx *gorm.DB, w *Webhook) error {
+	if err := w.Validate(); err != nil {
+		return err
+	}
+	return tx.Create(w).Error
+}
+
+// Webhooks returns all subscriptions, oldest first.
+func Webhooks(tx *gorm.DB) ([]Webhook, error) {
+	var webhooks []Webhook
+	if result := tx.Order("id").Find(&webhooks); result.Error != nil {
+		return nil, result.Error
+	}
+	return webhooks, nil
+}
+
+// DeleteWebhook removes the subscription with the given ID together with its
+// deliveries.
+func DeleteWebhook(tx *gorm.DB, id uint64) error {
+	return tx.Transaction(func(tx *gorm.DB) error {
+		if result := tx.Where("webhook_id = ?", id).Delete(&Delivery{}); result.Error != nil {
+			return result.Error
+		}
+		result := tx.Delete(&Webhook{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return fmt.Errorf("deleted %d rows: %w", result.RowsAffected, gorm.ErrRecordNotFound)
+		}
+		return nil
+	})
+}
+
+// WebhookPayload is the body of webhook requests.
+type WebhookPayload struct {
+	ID          uint64    `json:"id"` // Journal record ID.
+	Event       string    `json:"event"`
+	Cat         CatID     `json:"cat"`
+	Description string    `json:"description"`
+	Time        time.Time `json:"time"`
+	PrevMood    Mood      `json:"prev_mood,omitempty"`
+	Mood        Mood      `json:"mood,omitempty"`
+}
+
+// DeliveryStatus is the state of a webhook delivery.
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"   // Waiting for the next attempt.
+	DeliveryDelivered DeliveryStatus = "delivered" // The receiver accepted the payload.
+	DeliveryFailed    DeliveryStatus = "failed"    // All attempts failed.
+)
+
+const (
+	// MaxDeliveryAttempts is the number of attempts after which a delivery
+	// fails for good.
+	MaxDeliveryAttempts = 10
+	// deliveryBackoff is the delay after the first failed attempt. It doubles
+	// after each subsequent failure, up to maxDeliveryBackoff.
+	deliveryBackoff    = 30 * time.Second
+	maxDeliveryBackoff = 6 * time.Hour
+)
+
+// DeliveryBackoff returns the delay before the next attempt after the given
+// number of failed attempts.
+func DeliveryBackoff(attempts int) time.Duration {
+	d := deliveryBackoff
+	for i := 1; i < attempts && d < maxDeliveryBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxDeliveryBackoff)
+}
+
+// Delivery is a queued webhook request, which also serves as the delivery log.
+type Delivery struct {
+	ID        uint64 `gorm:"primaryKey"`
+	CreatedAt time.Time
+	WebhookID uint64 `gorm:"index"`
+	Webhook   Webhook
+	JournalID uint64
+	Event     EventType
+	Payload   []byte // JSON-encoded WebhookPayload.
+
+	Status      DeliveryStatus `gorm:"index"`
+	Attempts    int            // Number of started attempts.
+	NextAttempt time.Time      `gorm:"index"`
+	LastAttempt time.Time      // Zero if there were no attempts yet.
+	LastError   string         // Why the latest attempt failed. Empty on success.
+}
+
+// EnqueueDeliveries queues deliveries of the journal record to all matching
+// subscriptions. It should be called in the transaction that created the
+// record, so that deliveries aren't lost.
+func EnqueueDeliveries(tx *gorm.DB, r Journal) error {
+	if !slices.Contains(WebhookEvents, r.Event.Type) {
+		return nil
+	}
+	webhooks, err := Webhooks(tx)
+	if err != nil {
+		return fmt.Errorf("failed to load webhooks: %w", err)
+	}
+	var payload []byte
+	for _, w := range webhooks {
+		if !w.Matches(r) {
+			continue
+		}
+		if payload == nil {
+			payload, err = json.Marshal(WebhookPayload{
+				ID:          r.ID,
+				Event:       r.Event.Type.String(),
+				Cat:         r.CatID,
+				Description: r.Event.Description,
+				Time:        r.CreatedAt.UTC(),
+				PrevMood:    r.Event.PrevMood,
+				Mood:        r.Event.Mood,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to encode webhook payload: %w", err)
+			}
+		}
+		if result := tx.Create(&Delivery{
+			WebhookID:   w.ID,
+			JournalID:   r.ID,
+			Event:       r.Event.Type,
+			Payload:     payload,
+			Status:      DeliveryPending,
+			NextAttempt: r.CreatedAt,
+		}); result.Error != nil {
+			return result.Error
+		}
+	}
+	return nil
+}
+
+// DueDeliveries returns up to limit pending deliveries with the next attempt
+// due at now or earlier, most overdue first. Webhooks are preloaded.
+func DueDeliveries(tx *gorm.DB, now time.Time, limit int) ([]Delivery, error) {
+	var deliveries []Delivery
+	result := tx.Preload("Webhook").
+		Where("status = ? AND next_attempt <= ?", DeliveryPending, now).
+		Order("next_attempt").Limit(limit).Find(&deliveries)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return deliveries, nil
+}
+
+// ClaimDelivery starts a new attempt of the delivery, so that other workers
+// don't attempt it until the lease expires. Returns false if another worker
+// has claimed the delivery first.
+//
+// On success, d reflects the new attempt.
+func ClaimDelivery(tx *gorm.DB, d *Delivery, now time.Time, lease time.Duration) (bool, error) {
+	result := tx.Model(&Delivery{}).
+		Where("id = ? AND status = ? AND attempts = ?", d.ID, DeliveryPending, d.Attempts).
+		Updates(map[string]any{
+			"attempts":     d.Attempts + 1,
+			"next_attempt": now.Add(lease),
+			"last_attempt": now,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected != 1 {
+		return false, nil
+	}
+	d.Attempts++
+	d.NextAttempt = now.Add(lease)
+	d.LastAttempt = now
+	return true, nil
+}
+
+// FinishDelivery records the outcome of the claimed delivery attempt. A nil
+// failure marks the delivery as delivered, otherwise another attempt is
+// scheduled with exponential backoff, unless there were MaxDeliveryAttempts
+// already.
+func FinishDelivery(tx *gorm.DB, d *Delivery, now time.Time, failure error) error {
+	updates := map[string]any{"last_error": ""}
+	switch {
+	case failure == nil:
+		updates["status"] = DeliveryDelivered
+	case d.Attempts >= MaxDeliveryAttempts:
+		updates["status"] = DeliveryFailed
+		updates["last_error"] = failure.Error()
+	default:
+		updates["next_attempt"] = now.Add(DeliveryBackoff(d.Attempts))
+		updates["last_error"] = failure.Error()
+	}
+	result := tx.Model(&Delivery{}).Where("id = ? AND attempts = ?", d.ID, d.Attempts).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != 1 {
+		return fmt.Errorf("updated %d rows: %w", result.RowsAffected, gorm.ErrRecordNotFound)
+	}
+	return tx.Preload("Webhook").First(d, d.ID).Error
+}
+
+// Deliveries returns up to limit deliveries with IDs less than before, newest
+// first. Zero before starts with the newest delivery. If webhook is not zero,
+// only its deliveries are returned. Webhooks are preloaded.
+func Deliveries(tx *gorm.DB, webhook uint64, before uint64, limit int) ([]Delivery, error) {
+	q := tx.Preload("Webhook")
+	if before > 0 {
+		q = q.Where("id < ?", before)
+	}
+	if webhook != 0 {
+		q = q.Where("webhook_id = ?", webhook)
+	}
+	var deliveries []Delivery
+	if result := q.Order("id desc").Limit(limit).Find(&deliveries); result.Error != nil {
+		return nil, result.Error
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,145 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nevkontakte/pat/db/dbtest"
+	"gorm.io/gorm"
+)
+
+func TestEventTypes_ValueScanRoundtrip(t *testing.T) {
+	for _, events := range []EventTypes{nil, {EventPat}, {EventPat, EventZoomies}} {
+		value, err := events.Value()
+		if err != nil {
+			t.Fatalf("EventTypes.Value() returned error: %v", err)
+		}
+		var got EventTypes
+		if err := got.Scan(value); err != nil {
+			t.Fatalf("EventTypes.Scan(%q) returned error: %v", value, err)
+		}
+		if diff := cmp.Diff(events, got); diff != "" {
+			t.Errorf("EventTypes roundtrip mismatch (-want +got):\n%s", diff)
+		}
+	}
+}
+
+func TestWebhook_Matches(t *testing.T) {
+	record := func(event EventType, cat CatID, prev, mood Mood) Journal {
+		return Journal{CatID: cat, Event: Event{Type: event, PrevMood: prev, Mood: mood}}
+	}
+	tests := []struct {
+		name    string
+		webhook Webhook
+		record  Journal
+		want    bool
+	}{
+		{"any event", Webhook{}, record(EventZoomies, SplotchID, "", ""), true},
+		{"private event", Webhook{}, record(EventCatUpdated, SplotchID, "", ""), false},
+		{"event type", Webhook{Events: EventTypes{EventPat}}, record(EventPat, SplotchID, "", ""), true},
+		{"other event type", Webhook{Events: EventTypes{EventPat}}, record(EventFeed, SplotchID, "", ""), false},
+		{"cat", Webhook{CatID: SplotchID}, record(EventPat, SplotchID, "", ""), true},
+		{"other cat", Webhook{CatID: SplotchID}, record(EventPat, "red", "", ""), false},
+		{"to mood", Webhook{ToMood: MoodPat}, record(EventPat, SplotchID, MoodImpatient, MoodPat), true},
+		{"from mood", Webhook{FromMood: MoodImpatient}, record(EventPat, SplotchID, MoodImpatient, MoodPat), true},
+		{"other from mood", Webhook{FromMood: MoodIdle, ToMood: MoodPat}, record(EventPat, SplotchID, MoodImpatient, MoodPat), false},
+		{"mood unchanged", Webhook{ToMood: MoodPat}, record(EventPat, SplotchID, MoodPat, MoodPat), false},
+		{"mood untracked", Webhook{ToMood: MoodPat}, record(EventZoomies, SplotchID, "", ""), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.webhook.Matches(test.record); got != test.want {
+				t.Errorf("Got: Matches() = %t. Want: %t.", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDeliveryBackoff(t *testing.T) {
+	want := []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute}
+	for i, w := range want {
+		if got := DeliveryBackoff(i + 1); got != w {
+			t.Errorf("Got: DeliveryBackoff(%d) = %s. Want: %s.", i+1, got, w)
+		}
+	}
+	if got := DeliveryBackoff(100); got != maxDeliveryBackoff {
+		t.Errorf("Got: DeliveryBackoff(100) = %s. Want: %s.", got, maxDeliveryBackoff)
+	}
+}
+
+func TestWebhooks(t *testing.T) {
+	tx := dbtest.InMemory(t)
+	tx.AutoMigrate(&Cat{}, &Journal{}, &Webhook{}, &Delivery{})
+
+	for _, invalid := range []Webhook{
+		{URL: "ftp://example.com/"},
+		{URL: "/relative"},
+		{URL: "https://example.com/", Events: EventTypes{EventAdminJoined}},
+		{URL: "https://example.com/", ToMood: "grumpy"},
+	} {
+		if err := CreateWebhook(tx, &invalid); err == nil {
+			t.Errorf("Got: CreateWebhook(%+v) succeeded. Want: error.", invalid)
+		}
+	}
+
+	pats := Webhook{URL: "https://example.com/pats", Events: EventTypes{EventPat}}
+	all := Webhook{URL: "https://example.com/all"}
+	for _, w := range []*Webhook{&pats, &all} {
+		if err := CreateWebhook(tx, w); err != nil {
+			t.Fatalf("Got: CreateWebhook() returned error: %s. Want: no error.", err)
+		}
+	}
+
+	at := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, event := range []EventType{EventPat, EventNapped, EventVisit} {
+		r := Journal{CreatedAt: at, CatID: SplotchID, Event: Event{Type: event}}
+		dbtest.Save(t, tx, &r)
+		if err := EnqueueDeliveries(tx, r); err != nil {
+			t.Fatalf("Got: EnqueueDeliveries() returned error: %s. Want: no error.", err)
+		}
+	}
+
+	due, err := DueDeliveries(tx, at, 10)
+	if err != nil {
+		t.Fatalf("Got: DueDeliveries() returned error: %s. Want: no error.", err)
+	}
+	var got []string
+	for _, d := range due {
+		got = append(got, d.Webhook.URL+" "+d.Event.String())
+	}
+	want := []string{"https://example.com/pats pat", "https://example.com/all pat", "https://example.com/all napped"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("DueDeliveries() mismatch (-want +got):\n%s", diff)
+	}
+
+	// Only one worker may claim a delivery attempt.
+	first, second := due[0], due[0]
+	if ok, err := ClaimDelivery(tx, &first, at, time.Minute); !ok || err != nil {
+		t.Errorf("Got: first ClaimDelivery() = %t, %v. Want: true, no error.", ok, err)
+	}
+	if ok, err := ClaimDelivery(tx, &second, at, time.Minute); ok || err != nil {
+		t.Errorf("Got: second ClaimDelivery() = %t, %v. Want: false, no error.", ok, err)
+	}
+	if err := FinishDelivery(tx, &first, at, nil); err != nil {
+		t.Fatalf("Got: FinishDelivery() returned error: %s. Want: no error.", err)
+	}
+	if first.Status != DeliveryDelivered {
+		t.Errorf("Got: delivery status %q. Want: %q.", first.Status, DeliveryDelivered)
+	}
+
+	if err := DeleteWebhook(tx, all.ID); err != nil {
+		t.Fatalf("Got: DeleteWebhook() returned error: %s. Want: no error.", err)
+	}
+	if err := DeleteWebhook(tx, all.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Got: repeated DeleteWebhook() returned error: %v. Want: %v.", err, gorm.ErrRecordNotFound)
+	}
+	deliveries, err := Deliveries(tx, 0, 0, 10)
+	if err != nil {
+		t.Fatalf("Got: Deliveries() returned error: %s. Want: no error.", err)
+	}
+	if len(deliveries) != 1 || deliveries[0].WebhookID != pats.ID {
+		t.Errorf("Got: Deliveries() = %+v. Want: only the delivery of the remaining webhook.", deliveries)
+	}
+}
@@ -13,6 +13,7 @@ require (
 	gorm.io/driver/postgres v1.5.9
 	gorm.io/driver/sqlite v1.5.6
 	gorm.io/gorm v1.25.10
+	rsc.io/qr v0.2.0
 )
 
 require (
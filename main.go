@@ -1,33 +1,62 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log/slog"
 	"os"
 	"runtime/debug"
+	"strconv"
+	"strings"
 
 	"github.com/labstack/echo/v5"
 	"github.com/labstack/echo/v5/middleware"
+	"github.com/nevkontakte/pat/chrono"
 	"github.com/nevkontakte/pat/db"
+	"github.com/nevkontakte/pat/sim"
 	"github.com/nevkontakte/pat/static"
 	"github.com/nevkontakte/pat/tmpl"
 	"github.com/nevkontakte/pat/web"
+	"github.com/nevkontakte/pat/web/oidc"
+	"github.com/nevkontakte/pat/web/proxy"
+	"github.com/nevkontakte/pat/webhook"
 )
 
 var (
 	bind          = flag.String("bind", ":8080", "Address to start the HTTP server at.")
 	dsn           = flag.String("db", "host=localhost user=postgres password=postgres dbname=pat port=5432 sslmode=disable", "Database connection string.")
 	adminPassword = flag.String("admin-password", "", "Bcrypt hash of the admin password. Admin pages are disabled if unset.")
-	secret        = flag.String("secret", "", "Server-side signing secret for session cookies. Admin pages are disabled if unset.")
+	secret        = flag.String("secret", "", "Server-side signing secret for session and visitor cookies. Admin pages and nicknames are disabled if unset.")
+	proxies       = flag.String("trusted-proxies", "", "Comma-separated list of CIDRs or IP addresses of reverse proxies trusted to set forwarding headers.")
+	simulate      = flag.Bool("simulate", true, "Record autonomous cat activities in the journal.")
+	webhooks      = flag.Bool("webhooks", true, "Deliver journal events to webhook subscribers.")
+	milestones    = flag.String("milestones", "100,1000,10000,100000,1000000", "Comma-separated list of pat counts to celebrate.")
+
+	oidcIssuer       = flag.String("oidc-issuer", "", "OpenID Connect issuer URL for admin sign-in. Single sign-on is disabled if unset.")
+	oidcClientID     = flag.String("oidc-client-id", "", "OpenID Connect client ID.")
+	oidcClientSecret = flag.String("oidc-client-secret", "", "OpenID Connect client secret.")
+	oidcRedirectURL  = flag.String("oidc-redirect-url", "", "OpenID Connect redirect URL, e.g. https://example.com/admin/login/oidc/callback.")
+	oidcAllow        = flag.String("oidc-allow", "", "Comma-separated list of emails or group:<name> entries granted admin access, each optionally followed by =<role>.")
 )
 
 func run(e *echo.Echo) error {
+	trusted, err := proxy.ParseTrusted(*proxies)
+	if err != nil {
+		return fmt.Errorf("failed to parse -trusted-proxies: %w", err)
+	}
+	e.IPExtractor = trusted.ExtractIP
+
 	// Middleware
 	e.Use(middleware.RequestLogger())
 	e.Use(middleware.Recover())
 	e.Use(web.VisitorMiddleware)
 
+	db.MilestonePats, err = parseMilestones(*milestones)
+	if err != nil {
+		return fmt.Errorf("failed to parse -milestones: %w", err)
+	}
+
 	dbconn, err := db.Postgres(*dsn)
 	if err != nil {
 		return fmt.Errorf("failed to connect to the database: %w", err)
@@ -36,6 +65,15 @@ func run(e *echo.Echo) error {
 		return fmt.Errorf("failed to bootstrap the database: %w", err)
 	}
 
+	if *simulate {
+		worker := &sim.Worker{DB: dbconn, Clock: chrono.System}
+		go worker.Run(context.Background())
+	}
+	if *webhooks {
+		worker := &webhook.Worker{DB: dbconn, Clock: chrono.System}
+		go worker.Run(context.Background())
+	}
+
 	e.Renderer, err = tmpl.Load()
 	if err != nil {
 		return fmt.Errorf("failed to load templates: %w", err)
@@ -47,6 +85,23 @@ func run(e *echo.Echo) error {
 		DB:                dbconn,
 		AdminPasswordHash: []byte(*adminPassword),
 		Secret:            []byte(*secret),
+		Proxies:           trusted,
+		Clock:             chrono.System,
+	}
+	if *oidcIssuer != "" {
+		w.OIDC, err = oidc.Discover(context.Background(), oidc.Config{
+			Issuer:       *oidcIssuer,
+			ClientID:     *oidcClientID,
+			ClientSecret: *oidcClientSecret,
+			RedirectURL:  *oidcRedirectURL,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to set up OpenID Connect: %w", err)
+		}
+		w.OIDCAccess, err = web.ParseOIDCAccess(*oidcAllow)
+		if err != nil {
+			return fmt.Errorf("failed to parse -oidc-allow: %w", err)
+		}
 	}
 	w.Bind(e)
 
@@ -63,13 +118,61 @@ func run(e *echo.Echo) error {
 	return e.Start(*bind)
 }
 
+// parseMilestones parses a comma-separated list of pat counts.
+func parseMilestones(s string) ([]uint64, error) {
+	var counts []uint64
+	for part := range strings.SplitSeq(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		n, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pat count %q: %w", part, err)
+		}
+		counts = append(counts, n)
+	}
+	return counts, nil
+}
+
+// reset2FA removes the account's second factor enrollment, e.g. if they lost
+// access to both the authenticator app and recovery codes.
+func reset2FA(account string) error {
+	dbconn, err := db.Postgres(*dsn)
+	if err != nil {
+		return fmt.Errorf("failed to connect to the database: %w", err)
+	}
+	if err := db.Bootstrap(dbconn); err != nil {
+		return fmt.Errorf("failed to bootstrap the database: %w", err)
+	}
+	if err := db.ResetSecondFactor(dbconn, account); err != nil {
+		return fmt.Errorf("failed to reset two-factor authentication: %w", err)
+	}
+	fmt.Printf("Two-factor authentication for %q has been reset.\n", account)
+	return nil
+}
+
 func main() {
 	flag.Parse()
 
-	e := echo.New()
-
-	if err := run(e); err != nil {
-		slog.Error("server error", "err", err)
-		os.Exit(1)
+	switch cmd := flag.Arg(0); cmd {
+	case "":
+		e := echo.New()
+		if err := run(e); err != nil {
+			slog.Error("server error", "err", err)
+			os.Exit(1)
+		}
+	case "reset-2fa":
+		account := flag.Arg(1)
+		if account == "" {
+			account = db.DefaultAdmin
+		}
+		if err := reset2FA(account); err != nil {
+			slog.Error("reset-2fa failed", "err", err)
+			os.Exit(1)
+		}
+	default:
+		slog.Error("unknown command", "cmd", cmd)
+		os.Exit(2)
 	}
 }
@@ -0,0 +1,99 @@
+// Package narrative generates varied, but deterministic, human-readable
+// descriptions of game events from banks of phrase templates.
+package narrative
+
+import (
+	"strings"
+	"time"
+
+	"github.com/nevkontakte/pat/behavior"
+)
+
+// Bank is a list of interchangeable phrase templates.
+//
+// Templates refer to Context fields as {cat} and {visitor}.
+type Bank []string
+
+// Context contains what the descriptions may refer to.
+type Context struct {
+	Cat     string // Name of the cat.
+	Visitor string // Description of the visitor, e.g. "a visitor in Firefox".
+}
+
+func (c Context) replacer() *strings.Replacer {
+	return strings.NewReplacer("{cat}", c.Cat, "{visitor}", c.Visitor)
+}
+
+type bankKey struct {
+	event string
+	mood  string
+}
+
+// Narrator picks phrases for events from the registered banks. The zero value
+// has no phrases.
+type Narrator struct {
+	banks map[bankKey]Bank
+}
+
+// Register adds phrases for the event when the cat is in the given mood. Phrases
+// registered for an empty mood are used for moods without phrases of their own.
+func (n *Narrator) Register(event, mood string, phrases ...string) {
+	if n.banks == nil {
+		n.banks = map[bankKey]Bank{}
+	}
+	key := bankKey{event: event, mood: mood}
+	n.banks[key] = append(n.banks[key], phrases...)
+}
+
+// Bank returns phrases that may describe the event when the cat is in the
+// given mood.
+func (n *Narrator) Bank(event, mood string) Bank {
+	if bank := n.banks[bankKey{event: event, mood: mood}]; len(bank) > 0 {
+		return bank
+	}
+	return n.banks[bankKey{event: event}]
+}
+
+// Describe returns a description of the event that happened at the given time.
+// The noise picks the phrase, so the same noise and time always give the same
+// description. Returns an empty string if there are no phrases for the event.
+func (n *Narrator) Describe(noise behavior.TemporalNoise, at time.Time, event, mood string, ctx Context) string {
+	bank := n.Bank(event, mood)
+	if len(bank) == 0 {
+		return ""
+	}
+	phrase := behavior.Sample(noise, at, 1, bank)[0]
+	return ctx.replacer().Replace(phrase)
+}
+
+// browsers maps User-Agent header fragments to browser names, in the order
+// they must be checked, since most browsers mention others for compatibility.
+var browsers = []struct{ fragment, name string }{
+	{"Edg/", "Edge"},
+	{"OPR/", "Opera"},
+	{"Firefox/", "Firefox"},
+	{"FxiOS/", "Firefox"},
+	{"CriOS/", "Chrome"},
+	{"Chrome/", "Chrome"},
+	{"Safari/", "Safari"},
+	{"curl/", "curl"},
+}
+
+// Browser returns the name of the browser in the User-Agent header value, or an
+// empty string if it's not recognized.
+func Browser(userAgent string) string {
+	for _, b := range browsers {
+		if strings.Contains(userAgent, b.fragment) {
+			return b.name
+		}
+	}
+	return ""
+}
+
+// Visitor describes the visitor by their browser, e.g. "a visitor in Firefox".
+func Visitor(userAgent string) string {
+	if b := Browser(userAgent); b != "" {
+		return "a visitor in " + b
+	}
+	return "a visitor"
+}
@@ -0,0 +1,67 @@
+package narrative
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/nevkontakte/pat/behavior"
+)
+
+func TestNarrator_Describe(t *testing.T) {
+	n := &Narrator{}
+	n.Register("pat", "", "{cat} got a pat from {visitor}.", "{visitor} patted {cat}.")
+	n.Register("pat", "happy", "{cat} purred at {visitor}.")
+	ctx := Context{Cat: "Splotch", Visitor: "a visitor in Firefox"}
+	noise := behavior.NewHashNoise([]byte("test"))
+	at := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	if got, want := n.Describe(noise, at, "pat", "happy", ctx), "Splotch purred at a visitor in Firefox."; got != want {
+		t.Errorf("Got: Describe() with a mood bank = %q. Want: %q.", got, want)
+	}
+	if got := n.Describe(noise, at, "visit", "", ctx); got != "" {
+		t.Errorf("Got: Describe() without phrases = %q. Want: empty.", got)
+	}
+
+	// Fallback phrases are used for moods without their own, and all of them
+	// get picked eventually, but the same time always gives the same phrase.
+	want := []string{"Splotch got a pat from a visitor in Firefox.", "a visitor in Firefox patted Splotch."}
+	seen := map[string]bool{}
+	for i := range 100 {
+		at := at.Add(time.Duration(i) * time.Second)
+		got := n.Describe(noise, at, "pat", "grumpy", ctx)
+		if !slices.Contains(want, got) {
+			t.Fatalf("Got: Describe() = %q. Want: one of %q.", got, want)
+		}
+		if again := n.Describe(noise, at, "pat", "grumpy", ctx); again != got {
+			t.Fatalf("Got: Describe() = %q, then %q for the same time. Want: the same description.", got, again)
+		}
+		seen[got] = true
+	}
+	if len(seen) != len(want) {
+		t.Errorf("Got: descriptions %v. Want: all of %q.", seen, want)
+	}
+}
+
+func TestBrowser(t *testing.T) {
+	tests := []struct {
+		agent string
+		want  string
+	}{
+		{"Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0", "Firefox"},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36", "Chrome"},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36 Edg/126.0.0.0", "Edge"},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 14_5) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Safari/605.1.15", "Safari"},
+		{"curl/8.5.0", "curl"},
+		{"", ""},
+		{"SomethingElse/1.0", ""},
+	}
+	for _, tc := range tests {
+		if got := Browser(tc.agent); got != tc.want {
+			t.Errorf("Got: Browser(%q) = %q. Want: %q.", tc.agent, got, tc.want)
+		}
+	}
+	if got, want := Visitor(""), "a visitor"; got != want {
+		t.Errorf("Got: Visitor(%q) = %q. Want: %q.", "", got, want)
+	}
+}
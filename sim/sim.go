@@ -0,0 +1,100 @@
+// Package sim runs the autonomous life of the cats.
+//
+// The cats' behavior is deterministic, so the simulation doesn't keep any
+// state: each step records activities and mood changes that happened recently
+// according to the cats' noise, and the journal takes care of deduplicating
+// them. Any number of workers may run at the same time, e.g. one per server
+// replica.
+package sim
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/nevkontakte/pat/chrono"
+	"github.com/nevkontakte/pat/db"
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultPeriod is the default time between simulation steps.
+	DefaultPeriod = time.Minute
+	// DefaultLookback is the default length of the interval each step covers.
+	DefaultLookback = time.Hour
+)
+
+// Worker periodically records the cats' autonomous activities and mood changes
+// in the journal.
+type Worker struct {
+	DB    *gorm.DB
+	Clock chrono.Clock // Source of the current time. Defaults to chrono.System.
+	// Period is the time between simulation steps. Defaults to DefaultPeriod.
+	Period time.Duration
+	// Lookback is how far into the past each step looks for activities, which
+	// lets the worker catch up after downtime shorter than that. It must be
+	// longer than Period. Defaults to DefaultLookback.
+	Lookback time.Duration
+}
+
+func (w *Worker) clock() chrono.Clock {
+	return chrono.Or(w.Clock)
+}
+
+func (w *Worker) period() time.Duration {
+	if w.Period <= 0 {
+		return DefaultPeriod
+	}
+	return w.Period
+}
+
+func (w *Worker) lookback() time.Duration {
+	if w.Lookback <= 0 {
+		return DefaultLookback
+	}
+	return w.Lookback
+}
+
+// Run performs simulation steps until the context is canceled. Failed steps
+// are logged and retried at the next step.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := w.clock().NewTicker(w.period())
+	defer ticker.Stop()
+	for {
+		if _, err := w.Step(ctx); err != nil {
+			slog.Error("simulation step failed", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+// Step records activities and mood changes of all cats that happened within
+// the lookback interval before the current time and returns the number of new
+// journal records.
+func (w *Worker) Step(ctx context.Context) (int, error) {
+	now := w.clock().Now()
+	tx := w.DB.WithContext(ctx)
+	cats, err := db.Cats(tx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load cats: %w", err)
+	}
+	total := 0
+	for _, cat := range cats {
+		added, err := db.RecordActivities(tx, cat, now.Add(-w.lookback()), now)
+		total += added
+		if err != nil {
+			return total, fmt.Errorf("failed to record activities of %s: %w", cat.ID, err)
+		}
+		added, err = db.RecordMoodChanges(tx, cat, now.Add(-w.lookback()), now)
+		total += added
+		if err != nil {
+			return total, fmt.Errorf("failed to record mood changes of %s: %w", cat.ID, err)
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,91 @@
+package sim
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nevkontakte/pat/chrono/chronotest"
+	"github.com/nevkontakte/pat/db"
+	"github.com/nevkontakte/pat/db/dbtest"
+)
+
+// scheduled returns the number of activities the cat does within [from, to).
+func scheduled(cat db.Cat, from, to time.Time) int64 {
+	var n int64
+	for _, a := range db.Activities {
+		n += int64(len(cat.Schedule(a).Between(from, to)))
+	}
+	return n
+}
+
+func TestWorker(t *testing.T) {
+	tx := dbtest.InMemory(t)
+	// The worker runs in a separate goroutine, and each connection to an
+	// in-memory database would get a separate database.
+	sqlDB, err := tx.DB()
+	if err != nil {
+		t.Fatalf("tx.DB: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	tx.AutoMigrate(db.Cat{}, db.Journal{}, db.Webhook{}, db.Delivery{})
+	cats := []db.Cat{{ID: db.SplotchID, Name: "Splotch"}, {ID: "red", Name: "Red", Noise: db.NoiseHash}}
+	for _, cat := range cats {
+		dbtest.Save(t, tx, &cat)
+	}
+
+	start := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := chronotest.NewClock(start)
+	w := &Worker{DB: tx, Clock: clock, Period: time.Minute, Lookback: time.Hour}
+
+	// waitFor waits until the worker records all activities within the
+	// intervals.
+	waitFor := func(intervals ...[2]time.Duration) {
+		t.Helper()
+		var want int64
+		for _, cat := range cats {
+			for _, i := range intervals {
+				want += scheduled(cat, start.Add(i[0]), start.Add(i[1]))
+			}
+		}
+		deadline := time.Now().Add(10 * time.Second)
+		var got int64
+		for time.Now().Before(deadline) {
+			tx.Model(&db.Journal{}).Count(&got)
+			if got == want {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+		t.Fatalf("Got: %d journal records. Want: %d.", got, want)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(done)
+	}()
+
+	// The first step happens right away.
+	clock.BlockUntil(1)
+	waitFor([2]time.Duration{-time.Hour, 0})
+
+	// Subsequent steps overlap with the previous ones.
+	clock.Advance(30 * time.Minute)
+	waitFor([2]time.Duration{-time.Hour, 30 * time.Minute})
+
+	// After downtime longer than the lookback, only the recent activities are
+	// recorded.
+	clock.Advance(10 * time.Hour)
+	waitFor([2]time.Duration{-time.Hour, 30 * time.Minute}, [2]time.Duration{9*time.Hour + 30*time.Minute, 10*time.Hour + 30*time.Minute})
+
+	cancel()
+	<-done
+
+	// Another replica doesn't record the same activities again.
+	replica := &Worker{DB: tx, Clock: clock}
+	if added, err := replica.Step(context.Background()); err != nil || added != 0 {
+		t.Errorf("Got: replica.Step() = %d, %v. Want: no new records.", added, err)
+	}
+}
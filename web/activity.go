@@ -0,0 +1,90 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v5"
+	"github.com/nevkontakte/pat/db"
+)
+
+const (
+	// activityPageSize is the number of journal records per activity page.
+	activityPageSize = 100
+	// activityAggregateMin is the number of interactions of the same kind
+	// within an hour that are summarized in a single line.
+	activityAggregateMin = 3
+)
+
+// activityLine is a line of the public activity page.
+type activityLine struct {
+	At   time.Time
+	Text string
+}
+
+type activityData struct {
+	Cat   db.Cat
+	Lines []activityLine
+	Older uint64 // ID to continue from for older records, zero if there are none.
+}
+
+// activity shows a public page with recent events that happened to the cat.
+//
+// Only event descriptions are shown, which never include visitors' addresses
+// or user agents.
+func (w *Web) activity(c *echo.Context) error {
+	cat, err := w.catFromParam(c)
+	if err != nil {
+		return err
+	}
+	var before uint64
+	if s := c.QueryParam("before"); s != "" {
+		v, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return echo.ErrBadRequest
+		}
+		before = v
+	}
+	records, err := db.PublicJournal(w.DB, cat.ID, before, activityPageSize)
+	if err != nil {
+		return fmt.Errorf("failed to load journal: %w", err)
+	}
+	data := &activityData{Cat: cat, Lines: summarize(cat, records)}
+	if len(records) == activityPageSize {
+		data.Older = records[len(records)-1].ID
+	}
+	return c.Render(http.StatusOK, "activity.html", data)
+}
+
+// summarize turns journal records, newest first, into activity lines.
+// Interactions of the same kind within an hour are summarized in a single line,
+// if there are many of them.
+func summarize(cat db.Cat, records []db.Journal) []activityLine {
+	var lines []activityLine
+	for i := 0; i < len(records); {
+		r := records[i]
+		j := i + 1
+		hour := r.CreatedAt.Truncate(time.Hour)
+		for j < len(records) && records[j].Event.Type == r.Event.Type && records[j].CreatedAt.Truncate(time.Hour).Equal(hour) {
+			j++
+		}
+		if interaction, ok := db.InteractionByEvent(r.Event.Type); ok && j-i >= activityAggregateMin {
+			lines = append(lines, activityLine{
+				At:   r.CreatedAt,
+				Text: fmt.Sprintf("%s was %s %d times in an hour.", cat.Name, interaction.Done, j-i),
+			})
+			i = j
+			continue
+		}
+		text := r.Event.Description
+		if text == "" {
+			// Records from before descriptions were introduced.
+			text = cat.Describe(r.Event.Type, r.CreatedAt, nil)
+		}
+		lines = append(lines, activityLine{At: r.CreatedAt, Text: text})
+		i++
+	}
+	return lines
+}
@@ -0,0 +1,84 @@
+package web
+
+import (
+	"net/http"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nevkontakte/pat/chrono/chronotest"
+	"github.com/nevkontakte/pat/db"
+)
+
+func TestActivity(t *testing.T) {
+	w, e := newTestServer(t)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := chronotest.NewClock(start)
+	w.Clock = clock
+
+	visitor := &db.Visitor{
+		Addr:  db.Addr(netip.MustParseAddr("192.0.2.1")),
+		Agent: "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 SecretBrowser/1.0",
+	}
+	pat, _ := db.InteractionByID("pat")
+	for range 4 {
+		clock.Advance(time.Minute)
+		if _, err := db.Interact(w.DB, clock, db.SplotchID, pat, visitor); err != nil {
+			t.Fatalf("db.Interact: %v", err)
+		}
+	}
+	feed, _ := db.InteractionByID("feed")
+	clock.Advance(time.Minute)
+	if _, err := db.Interact(w.DB, clock, db.SplotchID, feed, visitor); err != nil {
+		t.Fatalf("db.Interact: %v", err)
+	}
+	w.DB.Create(&db.Journal{CatID: db.SplotchID, Event: db.Event{Type: db.EventVisit, Description: "A secret visit."}})
+	w.DB.Create(&db.Journal{CatID: db.SplotchID, Admin: db.DefaultAdmin, Event: db.Event{Type: db.EventCatUpdated, Description: "A secret update."}})
+
+	rec := serve(e, http.MethodGet, "/cats/splotch/activity", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /cats/splotch/activity: status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, "Splotch was patted 4 times in an hour.") {
+		t.Errorf("activity page should summarize the pats:\n%s", body)
+	}
+	var fed db.Journal
+	w.DB.Where("type = ?", db.EventFeed).First(&fed)
+	if fed.Event.Description == "" || !strings.Contains(body, strings.ReplaceAll(fed.Event.Description, "'", "&#39;")) {
+		t.Errorf("activity page should show the feeding description %q", fed.Event.Description)
+	}
+	for _, secret := range []string{"192.0.2.1", "SecretBrowser", "A secret visit.", "A secret update."} {
+		if strings.Contains(body, secret) {
+			t.Errorf("activity page shouldn't reveal %q", secret)
+		}
+	}
+
+	if rec := serve(e, http.MethodGet, "/cats/stray/activity", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("GET of a missing cat: status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	cat := db.Cat{ID: db.SplotchID, Name: "Splotch"}
+	at := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	record := func(event db.EventType, minutes int) db.Journal {
+		return db.Journal{CreatedAt: at.Add(time.Duration(minutes) * time.Minute), Event: db.Event{Type: event, Description: "d"}}
+	}
+
+	// Newest first.
+	records := []db.Journal{
+		record(db.EventPat, 70), record(db.EventPat, 65), record(db.EventPat, 61), // Three pats in an hour.
+		record(db.EventPat, 59), record(db.EventPat, 58), // Only two in the previous hour.
+		record(db.EventNapped, 30), record(db.EventNapped, 20), record(db.EventNapped, 10), // Not interactions.
+	}
+	var got []string
+	for _, l := range summarize(cat, records) {
+		got = append(got, l.Text)
+	}
+	want := []string{"Splotch was patted 3 times in an hour.", "d", "d", "d", "d", "d"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("summarize() = %q, want %q", got, want)
+	}
+}
@@ -1,52 +1,244 @@
 package web
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/labstack/echo/v5"
 	"github.com/nevkontakte/pat/db"
 	"github.com/nevkontakte/pat/web/cookie"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
-const adminCookieName = "admin_session"
+const (
+	adminCookieName = "admin_session"
+	adminKey        = "admin"
+)
 
 type AdminCookie struct {
 	IsAdmin bool
+	// Account the session belongs to. Sessions issued before multiple admin
+	// accounts were supported don't have it, and belong to db.DefaultAdmin.
+	Account string
+	// OIDC is set for sessions authenticated by the OpenID provider. Such
+	// accounts don't exist in the database, their role is determined by
+	// Web.OIDCAccess based on the email (stored in Account) and Groups.
+	OIDC   bool
+	Groups []string
+}
+
+// AdminIdentity describes the admin account behind the current request.
+type AdminIdentity struct {
+	Account string
+	Role    db.Role
+	// External is set for accounts managed by the OpenID provider.
+	External bool
+	// Token is set if the request was authenticated with an API token instead
+	// of a session cookie.
+	Token *db.APIToken
 }
 
-// requireAdmin is an Echo middleware that enforces admin authentication.
+// adminFromContext returns the admin authenticated by requireRole.
+func adminFromContext(c *echo.Context) AdminIdentity {
+	a, _ := echo.ContextGet[AdminIdentity](c, adminKey)
+	return a
+}
+
+// requireRole returns an Echo middleware that enforces admin authentication
+// with at least the given role.
+//
 // Requests without a valid session cookie are redirected to the login page.
-func (w *Web) requireAdmin(next echo.HandlerFunc) echo.HandlerFunc {
-	return func(c *echo.Context) error {
-		raw, err := c.Cookie(adminCookieName)
-		if err != nil {
-			return c.Redirect(http.StatusFound, "/admin/login")
+// Requests from admins lacking the role are rejected. If scopes are given, the
+// route also accepts API tokens with any of them, see requireToken.
+func (w *Web) requireRole(role db.Role, scopes ...db.Scope) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c *echo.Context) error {
+			if token, ok := bearerToken(c); ok {
+				if err := w.authenticateToken(c, token, role, scopes); err != nil {
+					return err
+				}
+				return next(c)
+			}
+
+			raw, err := c.Cookie(adminCookieName)
+			if err != nil {
+				return c.Redirect(http.StatusFound, "/admin/login")
+			}
+			ac, err := cookie.ParseCookie[AdminCookie](raw.Value, w.Secret)
+			if err != nil || !ac.IsAdmin {
+				return c.Redirect(http.StatusFound, "/admin/login")
+			}
+			if ac.Account == "" {
+				ac.Account = db.DefaultAdmin
+			}
+			admin, err := w.adminIdentity(ac)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				// The account has been removed since the session started.
+				return c.Redirect(http.StatusFound, "/admin/login")
+			} else if err != nil {
+				return err
+			}
+			if !admin.Role.Includes(role) {
+				return echo.ErrForbidden
+			}
+			c.Set(adminKey, admin)
+			return next(c)
+		}
+	}
+}
+
+// requireToken returns an Echo middleware that only accepts requests with an
+// API token in the Authorization header.
+//
+// The token must have any of the given scopes, and its account must have at
+// least the given role.
+func (w *Web) requireToken(role db.Role, scopes ...db.Scope) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c *echo.Context) error {
+			token, ok := bearerToken(c)
+			if !ok {
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, "Bearer")
+				return echo.ErrUnauthorized
+			}
+			if err := w.authenticateToken(c, token, role, scopes); err != nil {
+				return err
+			}
+			return next(c)
+		}
+	}
+}
+
+// bearerToken extracts the API token from the Authorization header.
+func bearerToken(c *echo.Context) (string, bool) {
+	scheme, token, found := strings.Cut(c.Request().Header.Get(echo.HeaderAuthorization), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return token, true
+}
+
+// authenticateToken sets the identity of the API token's account in the
+// context.
+//
+// The token is rejected if it doesn't have any of the scopes, or its account is
+// no longer allowed to act with the role.
+func (w *Web) authenticateToken(c *echo.Context, token string, role db.Role, scopes []db.Scope) error {
+	t, err := db.UseAPIToken(w.DB, w.clock(), token)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+		return echo.ErrUnauthorized
+	} else if err != nil {
+		return fmt.Errorf("failed to look up API token: %w", err)
+	}
+	admin, err := w.adminIdentity(AdminCookie{Account: t.Admin})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// The account has been removed or lost access since the token was created.
+		c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+		return echo.ErrUnauthorized
+	} else if err != nil {
+		return err
+	}
+	if !t.Scopes.Has(scopes...) || !admin.Role.Includes(role) {
+		return echo.ErrForbidden
+	}
+	admin.Token = &t
+	c.Set(adminKey, admin)
+	return nil
+}
+
+// adminIdentity looks up the current role of the session's account.
+//
+// Returns gorm.ErrRecordNotFound if the account doesn't exist or can't be used
+// to log in.
+func (w *Web) adminIdentity(ac AdminCookie) (AdminIdentity, error) {
+	account := ac.Account
+	if ac.OIDC {
+		if w.OIDC == nil {
+			return AdminIdentity{}, gorm.ErrRecordNotFound
+		}
+		role, ok := w.OIDCAccess.Role(account, ac.Groups)
+		if !ok {
+			return AdminIdentity{}, gorm.ErrRecordNotFound
 		}
-		ac, err := cookie.ParseCookie[AdminCookie](raw.Value, w.Secret)
-		if err != nil || !ac.IsAdmin {
-			return c.Redirect(http.StatusFound, "/admin/login")
+		return AdminIdentity{Account: account, Role: role, External: true}, nil
+	}
+	if account == db.DefaultAdmin {
+		if len(w.AdminPasswordHash) == 0 {
+			return AdminIdentity{}, gorm.ErrRecordNotFound
 		}
-		return next(c)
+		return AdminIdentity{Account: db.DefaultAdmin, Role: db.RoleOwner}, nil
+	}
+	a, err := db.AdminByUsername(w.DB, account)
+	if err != nil {
+		return AdminIdentity{}, err
 	}
+	if !a.Active() {
+		return AdminIdentity{}, gorm.ErrRecordNotFound
+	}
+	return AdminIdentity{Account: a.Username, Role: a.Role}, nil
+}
+
+type loginData struct {
+	Error error
+	OIDC  bool // Offer signing in with the OpenID provider.
 }
 
-type loginData struct{ Error error }
+func (w *Web) loginData(err error) *loginData {
+	return &loginData{Error: err, OIDC: w.OIDC != nil}
+}
 
 func (w *Web) adminLogin(c *echo.Context) error {
-	return c.Render(http.StatusOK, "login.html", &loginData{})
+	return c.Render(http.StatusOK, "login.html", w.loginData(nil))
 }
 
 func (w *Web) adminLoginPost(c *echo.Context) error {
-	password := c.FormValue("password")
-	if err := bcrypt.CompareHashAndPassword(w.AdminPasswordHash, []byte(password)); err != nil {
-		return c.Render(http.StatusOK, "login.html", &loginData{
-			Error: fmt.Errorf("Wrong password."),
-		})
+	account := c.FormValue("username")
+	if account == "" {
+		account = db.DefaultAdmin
+	}
+	hash, ok := w.passwordHash(account)
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(c.FormValue("password"))); err != nil || !ok {
+		return c.Render(http.StatusOK, "login.html", w.loginData(fmt.Errorf("Wrong password.")))
+	}
+	return w.passwordVerified(c, account)
+}
+
+// dummyPasswordHash is compared against for accounts that can't log in with a
+// password, so that the response time doesn't reveal which accounts exist. It
+// has the cost of invited admins' password hashes.
+var dummyPasswordHash = []byte("$2a$10$IUY6dVXRFDeMF0r.G3Mp7.UUwaQwFon8QN9KMF7WlB4w.PXN.XG7.")
+
+// passwordHash returns the bcrypt hash of the account password. If the account
+// can't log in with a password, it returns dummyPasswordHash and false.
+func (w *Web) passwordHash(account string) ([]byte, bool) {
+	var hash []byte
+	if account == db.DefaultAdmin {
+		hash = w.AdminPasswordHash
+	} else if a, err := db.AdminByUsername(w.DB, account); err == nil {
+		hash = a.PasswordHash
 	}
-	value, err := cookie.SaveCookie(AdminCookie{IsAdmin: true}, w.Secret)
+	if len(hash) == 0 {
+		return dummyPasswordHash, false
+	}
+	return hash, true
+}
+
+// startSession logs the admin into the account and redirects to the dashboard.
+func (w *Web) startSession(c *echo.Context, account string) error {
+	if err := w.setSession(c, AdminCookie{Account: account}); err != nil {
+		return err
+	}
+	return c.Redirect(http.StatusFound, "/admin/")
+}
+
+// setSession sets the admin session cookie.
+func (w *Web) setSession(c *echo.Context, ac AdminCookie) error {
+	ac.IsAdmin = true
+	value, err := cookie.SaveCookie(ac, w.Secret)
 	if err != nil {
 		return fmt.Errorf("failed to create admin session cookie: %w", err)
 	}
@@ -55,11 +247,11 @@ func (w *Web) adminLoginPost(c *echo.Context) error {
 		Value:    value,
 		Path:     "/",
 		HttpOnly: true,
-		Secure:   c.Scheme() == "https",
+		Secure:   w.scheme(c) == "https",
 		SameSite: http.SameSiteStrictMode,
 		MaxAge:   30 * 24 * 60 * 60,
 	})
-	return c.Redirect(http.StatusFound, "/admin/")
+	return nil
 }
 
 func (w *Web) adminLogout(c *echo.Context) error {
@@ -85,12 +277,22 @@ func (w *Web) adminDashboard(c *echo.Context) error {
 		lastVisitTime = lastVisit.CreatedAt
 	}
 
+	now := w.clock().Now()
+	moodSince, err := db.MoodSince(w.DB, splotch, now)
+	if err != nil {
+		return fmt.Errorf("failed to load mood history: %w", err)
+	}
+
 	data := struct {
+		Admin     AdminIdentity
 		Mood      db.Mood
+		MoodSince time.Time // Zero if unknown.
 		LastVisit time.Time
 		LastPat   time.Time
 	}{
-		Mood:      splotch.Mood(),
+		Admin:     adminFromContext(c),
+		Mood:      splotch.MoodAt(now),
+		MoodSince: moodSince,
 		LastVisit: lastVisitTime,
 		LastPat:   splotch.LatestPat,
 	}
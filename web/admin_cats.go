@@ -0,0 +1,141 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/safehtml"
+	"github.com/labstack/echo/v5"
+	"github.com/nevkontakte/pat/db"
+)
+
+const (
+	// previewDays is how far into the future the personality preview looks.
+	previewDays = 7
+	// previewStep is how often the mood is sampled for the preview.
+	previewStep = 10 * time.Minute
+)
+
+// previewMoods are the moods shown in the personality preview, in order.
+var previewMoods = []db.Mood{db.MoodIdleHappy, db.MoodIdle, db.MoodIdleBlink, db.MoodImpatient}
+
+type catsData struct {
+	Admin AdminIdentity
+	Cats  []db.Cat
+}
+
+// adminCats lists all cats.
+func (w *Web) adminCats(c *echo.Context) error {
+	cats, err := db.Cats(w.DB)
+	if err != nil {
+		return fmt.Errorf("failed to load cats: %w", err)
+	}
+	return c.Render(http.StatusOK, "admin_cats.html", &catsData{Admin: adminFromContext(c), Cats: cats})
+}
+
+// traitFieldPrefix is prepended to trait names to get form field names.
+const traitFieldPrefix = "trait"
+
+// traitField is a personality trait in the edit form.
+type traitField struct {
+	Name  safehtml.Identifier
+	Label string
+	Value float64
+}
+
+// previewDay summarizes the cat's moods over a single day.
+type previewDay struct {
+	Day     int
+	Percent []int // Share of time spent in each of previewMoods.
+}
+
+type catData struct {
+	Error   error
+	Admin   AdminIdentity
+	Cat     db.Cat
+	Traits  []traitField
+	Moods   []db.Mood
+	Preview []previewDay
+	Saved   bool
+}
+
+func (w *Web) renderCat(c *echo.Context, data *catData) error {
+	data.Admin = adminFromContext(c)
+	for _, t := range data.Cat.Personality.Traits() {
+		data.Traits = append(data.Traits, traitField{Name: safehtml.IdentifierFromConstantPrefix(traitFieldPrefix, t.Name), Label: t.Label, Value: *t.Value})
+	}
+	data.Moods = previewMoods
+	data.Preview = personalityPreview(data.Cat, w.clock().Now())
+	return c.Render(http.StatusOK, "admin_cat.html", data)
+}
+
+// personalityPreview shows how the cat would behave over the next week if it
+// got a pat right now.
+func personalityPreview(cat db.Cat, now time.Time) []previewDay {
+	cat.LatestPat = now
+	counts := make([]map[db.Mood]int, previewDays)
+	for i := range counts {
+		counts[i] = map[db.Mood]int{}
+	}
+	until := now.Add(previewDays*24*time.Hour - previewStep)
+	for t, mood := range cat.Moods(now, until, previewStep) {
+		counts[int(t.Sub(now)/(24*time.Hour))][mood]++
+	}
+
+	samples := int(24 * time.Hour / previewStep)
+	days := make([]previewDay, previewDays)
+	for i, count := range counts {
+		days[i].Day = i + 1
+		for _, mood := range previewMoods {
+			days[i].Percent = append(days[i].Percent, (count[mood]*100+samples/2)/samples)
+		}
+	}
+	return days
+}
+
+// adminCat shows the cat's personality with a preview of its behavior.
+func (w *Web) adminCat(c *echo.Context) error {
+	cat, err := w.catFromParam(c)
+	if err != nil {
+		return err
+	}
+	return w.renderCat(c, &catData{Cat: cat})
+}
+
+// adminCatPost previews or saves changes to the cat's personality, depending
+// on the button pressed.
+func (w *Web) adminCatPost(c *echo.Context) error {
+	cat, err := w.catFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	var p db.Personality
+	for _, t := range p.Traits() {
+		v, err := strconv.ParseFloat(c.FormValue(traitFieldPrefix+"-"+t.Name), 64)
+		if err != nil {
+			return w.renderCat(c, &catData{Cat: cat, Error: fmt.Errorf("%s must be a number.", t.Label)})
+		}
+		*t.Value = v
+	}
+	if err := p.Validate(); err != nil {
+		return w.renderCat(c, &catData{Cat: cat, Error: err})
+	}
+	cat.Personality = p
+	if c.FormValue("action") != "save" {
+		return w.renderCat(c, &catData{Cat: cat})
+	}
+
+	if err := db.SetPersonality(w.DB, cat.ID, p); err != nil {
+		return fmt.Errorf("failed to update cat: %w", err)
+	}
+	if err := w.recordAdminAction(c, adminFromContext(c).Account, db.Event{
+		Type:        db.EventCatUpdated,
+		Description: fmt.Sprintf("Updated %s: changed personality to %s.", cat.ID, p),
+	}); err != nil {
+		return err
+	}
+	return w.renderCat(c, &catData{Cat: cat, Saved: true})
+}
@@ -0,0 +1,74 @@
+package web
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/nevkontakte/pat/db"
+)
+
+func TestAdminCats(t *testing.T) {
+	w, e := newTestServer(t)
+	addTestAdmin(t, w, "viewer", db.RoleViewer)
+	owner := sessionCookie(t, w, db.DefaultAdmin)
+
+	if rec := serve(e, http.MethodGet, "/admin/cats", nil, sessionCookie(t, w, "viewer")); rec.Code != http.StatusForbidden {
+		t.Errorf("viewer GET /admin/cats: status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+	rec := serve(e, http.MethodGet, "/admin/cats", nil, owner)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `href="/admin/cats/splotch"`) {
+		t.Errorf("GET /admin/cats: status = %d, want %d and a link to Splotch", rec.Code, http.StatusOK)
+	}
+	if rec := serve(e, http.MethodGet, "/admin/cats/stray", nil, owner); rec.Code != http.StatusNotFound {
+		t.Errorf("GET of a missing cat: status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	rec = serve(e, http.MethodGet, "/admin/cats/splotch", nil, owner)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /admin/cats/splotch: status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	for _, want := range []string{`name="trait-clinginess"`, `name="trait-sleepiness"`, "idle_happy"} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("cat page should contain %q", want)
+		}
+	}
+
+	form := url.Values{
+		"trait-clinginess": {"1"},
+		"trait-patience":   {"-0.5"},
+		"trait-moodiness":  {"0"},
+		"trait-sleepiness": {"0.25"},
+		"action":           {"preview"},
+	}
+	if rec := serve(e, http.MethodPost, "/admin/cats/splotch", form, owner); rec.Code != http.StatusOK {
+		t.Errorf("preview: status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if cat, _ := db.CatByID(w.DB, db.SplotchID); cat.Personality != (db.Personality{}) {
+		t.Errorf("preview changed personality to %+v, want it unchanged", cat.Personality)
+	}
+
+	form.Set("action", "save")
+	if rec := serve(e, http.MethodPost, "/admin/cats/splotch", form, owner); rec.Code != http.StatusOK {
+		t.Errorf("save: status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	want := db.Personality{Clinginess: 1, Patience: -0.5, Sleepiness: 0.25}
+	if cat, _ := db.CatByID(w.DB, db.SplotchID); cat.Personality != want {
+		t.Errorf("saved personality = %+v, want %+v", cat.Personality, want)
+	}
+	var count int64
+	w.DB.Model(&db.Journal{}).Where("admin = ?", db.DefaultAdmin).Count(&count)
+	if count != 1 {
+		t.Errorf("journal has %d admin records, want 1", count)
+	}
+
+	form.Set("trait-patience", "3")
+	rec = serve(e, http.MethodPost, "/admin/cats/splotch", form, owner)
+	if !strings.Contains(rec.Body.String(), `role="alert"`) {
+		t.Error("out of range trait should show an error")
+	}
+	if cat, _ := db.CatByID(w.DB, db.SplotchID); cat.Personality != want {
+		t.Errorf("invalid save changed personality to %+v, want %+v", cat.Personality, want)
+	}
+}
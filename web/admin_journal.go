@@ -0,0 +1,42 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v5"
+	"github.com/nevkontakte/pat/db"
+)
+
+// adminJournalPageSize is the number of journal records per admin page.
+const adminJournalPageSize = 50
+
+type journalData struct {
+	Admin   AdminIdentity
+	Records []db.Journal
+	Cat     db.CatID // Cat the records are filtered by, empty for all cats.
+	Older   uint64   // ID to continue from for older records, zero if there are none.
+}
+
+// adminJournal lists journal records, newest first.
+func (w *Web) adminJournal(c *echo.Context) error {
+	var before uint64
+	if s := c.QueryParam("before"); s != "" {
+		v, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return echo.ErrBadRequest
+		}
+		before = v
+	}
+	cat := db.CatID(c.QueryParam("cat"))
+	records, err := db.JournalBefore(w.DB, cat, before, adminJournalPageSize)
+	if err != nil {
+		return fmt.Errorf("failed to load journal: %w", err)
+	}
+	data := &journalData{Admin: adminFromContext(c), Records: records, Cat: cat}
+	if len(records) == adminJournalPageSize {
+		data.Older = records[len(records)-1].ID
+	}
+	return c.Render(http.StatusOK, "admin_journal.html", data)
+}
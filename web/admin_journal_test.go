@@ -0,0 +1,53 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/nevkontakte/pat/db"
+)
+
+func TestAdminJournal(t *testing.T) {
+	w, e := newTestServer(t)
+	admin := sessionCookie(t, w, db.DefaultAdmin)
+
+	if rec := serve(e, http.MethodGet, "/pat/", nil); rec.Code != http.StatusFound {
+		t.Fatalf("GET /pat/: status = %d, want %d", rec.Code, http.StatusFound)
+	}
+	var pat db.Journal
+	w.DB.Where("type = ?", db.EventPat).First(&pat)
+	if !strings.Contains(pat.Event.Description, "Splotch") {
+		t.Errorf("pat description = %q, want a description mentioning Splotch", pat.Event.Description)
+	}
+
+	rec := serve(e, http.MethodGet, "/admin/journal", nil, admin)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /admin/journal: status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if !strings.Contains(rec.Body.String(), "<code>pat</code>") {
+		t.Error("journal page should list the pat")
+	}
+	if strings.Contains(rec.Body.String(), "before=") {
+		t.Error("journal page shouldn't link to older records when there are none")
+	}
+
+	for range adminJournalPageSize {
+		serve(e, http.MethodGet, "/", nil)
+	}
+	rec = serve(e, http.MethodGet, "/admin/journal", nil, admin)
+	if !strings.Contains(rec.Body.String(), fmt.Sprintf("/admin/journal?before=%d", 2)) {
+		t.Error("journal page should link to older records")
+	}
+	rec = serve(e, http.MethodGet, "/admin/journal?before=2", nil, admin)
+	if !strings.Contains(rec.Body.String(), "<code>pat</code>") {
+		t.Error("older journal page should list the pat")
+	}
+
+	// The cat filter is kept across pages.
+	rec = serve(e, http.MethodGet, "/admin/journal?cat=splotch", nil, admin)
+	if !strings.Contains(rec.Body.String(), fmt.Sprintf("/admin/journal?cat=splotch&before=%d", 2)) {
+		t.Errorf("filtered journal page should link to older records of the same cat:\n%s", rec.Body.String())
+	}
+}
@@ -0,0 +1,152 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v5"
+	"github.com/nevkontakte/pat/db"
+	"github.com/nevkontakte/pat/web/cookie"
+	"github.com/nevkontakte/pat/web/oidc"
+)
+
+const (
+	oidcCookieName = "admin_oidc"
+	// oidcTimeout limits how long the admin may take to sign in with the
+	// OpenID provider.
+	oidcTimeout = 10 * time.Minute
+)
+
+// OIDCAccess is an allowlist of OpenID provider users granted admin access.
+type OIDCAccess struct {
+	Emails map[string]db.Role // Roles granted to verified email addresses.
+	Groups map[string]db.Role // Roles granted to members of groups.
+}
+
+// ParseOIDCAccess parses a comma-separated allowlist.
+//
+// Each entry is either "<email>=<role>" or "group:<name>=<role>". If the role
+// is omitted, it defaults to viewer.
+func ParseOIDCAccess(s string) (OIDCAccess, error) {
+	a := OIDCAccess{Emails: map[string]db.Role{}, Groups: map[string]db.Role{}}
+	for entry := range strings.SplitSeq(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		subject, role, found := strings.Cut(entry, "=")
+		if !found {
+			role = string(db.RoleViewer)
+		}
+		if !db.Role(role).Valid() {
+			return OIDCAccess{}, fmt.Errorf("unknown role %q in %q", role, entry)
+		}
+		if group, ok := strings.CutPrefix(subject, "group:"); ok {
+			a.Groups[group] = db.Role(role)
+		} else {
+			a.Emails[strings.ToLower(subject)] = db.Role(role)
+		}
+	}
+	return a, nil
+}
+
+// Role returns the most privileged role granted to the user.
+//
+// email must be verified by the provider. Returns false if the user is not on
+// the allowlist.
+func (a OIDCAccess) Role(email string, groups []string) (db.Role, bool) {
+	var best db.Role
+	grant := func(role db.Role, ok bool) {
+		if ok && !best.Includes(role) {
+			best = role
+		}
+	}
+	grant(a.Emails[strings.ToLower(email)], email != "")
+	for _, g := range groups {
+		role, ok := a.Groups[g]
+		grant(role, ok)
+	}
+	return best, best.Valid()
+}
+
+// OIDCCookie carries the authorization request state between the redirect to
+// the OpenID provider and the callback.
+type OIDCCookie struct {
+	State    string
+	Nonce    string
+	Verifier string // PKCE code verifier.
+	Expires  time.Time
+}
+
+// adminOIDCLogin redirects the admin to the OpenID provider to sign in.
+func (w *Web) adminOIDCLogin(c *echo.Context) error {
+	var oc OIDCCookie
+	for _, v := range []*string{&oc.State, &oc.Nonce, &oc.Verifier} {
+		s, err := oidc.RandomString()
+		if err != nil {
+			return err
+		}
+		*v = s
+	}
+	oc.Expires = w.clock().Now().Add(oidcTimeout)
+
+	value, err := cookie.SaveCookie(oc, w.Secret)
+	if err != nil {
+		return fmt.Errorf("failed to create OpenID cookie: %w", err)
+	}
+	c.SetCookie(&http.Cookie{
+		Name:     oidcCookieName,
+		Value:    value,
+		Path:     "/admin/login/oidc",
+		HttpOnly: true,
+		Secure:   w.scheme(c) == "https",
+		// The callback is a cross-site navigation from the provider, so the
+		// cookie can't be strict.
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   int(oidcTimeout / time.Second),
+	})
+	return c.Redirect(http.StatusFound, w.OIDC.AuthCodeURL(oc.State, oc.Nonce, oc.Verifier))
+}
+
+// adminOIDCCallback completes signing in with the OpenID provider.
+func (w *Web) adminOIDCCallback(c *echo.Context) error {
+	raw, err := c.Cookie(oidcCookieName)
+	if err != nil {
+		return c.Render(http.StatusOK, "login.html", w.loginData(fmt.Errorf("Sign-in session expired, please try again.")))
+	}
+	oc, err := cookie.ParseCookie[OIDCCookie](raw.Value, w.Secret)
+	if err != nil || !w.clock().Now().Before(oc.Expires) || c.QueryParam("state") != oc.State {
+		return c.Render(http.StatusOK, "login.html", w.loginData(fmt.Errorf("Sign-in session expired, please try again.")))
+	}
+	c.SetCookie(&http.Cookie{
+		Name:   oidcCookieName,
+		Value:  "",
+		Path:   "/admin/login/oidc",
+		MaxAge: -1,
+	})
+
+	if e := c.QueryParam("error"); e != "" {
+		return c.Render(http.StatusOK, "login.html", w.loginData(fmt.Errorf("Sign-in failed: %s.", e)))
+	}
+	claims, err := w.OIDC.Exchange(c.Request().Context(), c.QueryParam("code"), oc.Verifier, oc.Nonce, w.clock().Now())
+	if err != nil {
+		c.Logger().Error("OpenID sign-in failed", "err", err)
+		return c.Render(http.StatusOK, "login.html", w.loginData(fmt.Errorf("Sign-in failed.")))
+	}
+
+	ac := AdminCookie{OIDC: true, Account: "oidc:" + claims.Subject, Groups: claims.Groups}
+	if claims.EmailVerified && claims.Email != "" {
+		ac.Account = claims.Email
+	}
+	if _, ok := w.OIDCAccess.Role(ac.Account, ac.Groups); !ok {
+		return c.Render(http.StatusOK, "login.html", w.loginData(fmt.Errorf("Your account doesn't have admin access.")))
+	}
+	if err := w.setSession(c, ac); err != nil {
+		return err
+	}
+	// The session cookie is strict, so it wouldn't be sent if we redirected
+	// right away as part of the cross-site navigation from the provider.
+	return c.Render(http.StatusOK, "admin_redirect.html", nil)
+}
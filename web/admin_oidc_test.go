@@ -0,0 +1,183 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nevkontakte/pat/db"
+	"github.com/nevkontakte/pat/web/cookie"
+	"github.com/nevkontakte/pat/web/oidc"
+	"github.com/nevkontakte/pat/web/oidc/oidctest"
+)
+
+func TestParseOIDCAccess(t *testing.T) {
+	got, err := ParseOIDCAccess("Alice@Example.com=owner, bob@example.com, group:cats=moderator")
+	if err != nil {
+		t.Fatalf("ParseOIDCAccess() returned error: %v", err)
+	}
+	want := OIDCAccess{
+		Emails: map[string]db.Role{"alice@example.com": db.RoleOwner, "bob@example.com": db.RoleViewer},
+		Groups: map[string]db.Role{"cats": db.RoleModerator},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ParseOIDCAccess() returned diff (-want,+got):\n%s", diff)
+	}
+
+	if _, err := ParseOIDCAccess("alice@example.com=root"); err == nil {
+		t.Error("ParseOIDCAccess() with unknown role succeeded, want error")
+	}
+}
+
+func TestOIDCAccess_Role(t *testing.T) {
+	a := OIDCAccess{
+		Emails: map[string]db.Role{"alice@example.com": db.RoleViewer},
+		Groups: map[string]db.Role{"cats": db.RoleModerator, "owners": db.RoleOwner},
+	}
+
+	tests := []struct {
+		name     string
+		email    string
+		groups   []string
+		wantRole db.Role
+		wantOK   bool
+	}{
+		{name: "email", email: "ALICE@example.com", wantRole: db.RoleViewer, wantOK: true},
+		{name: "group", email: "bob@example.com", groups: []string{"cats"}, wantRole: db.RoleModerator, wantOK: true},
+		{name: "most privileged wins", email: "alice@example.com", groups: []string{"owners", "cats"}, wantRole: db.RoleOwner, wantOK: true},
+		{name: "not allowed", email: "mallory@example.com", groups: []string{"dogs"}},
+		{name: "no email", groups: []string{"dogs"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			role, ok := a.Role(tc.email, tc.groups)
+			if role != tc.wantRole || ok != tc.wantOK {
+				t.Errorf("Role(%q, %q) = %q, %v; want %q, %v", tc.email, tc.groups, role, ok, tc.wantRole, tc.wantOK)
+			}
+		})
+	}
+}
+
+func TestAdminOIDCLogin(t *testing.T) {
+	tests := []struct {
+		name        string
+		user        oidctest.User
+		wantAccount string
+	}{
+		{
+			name:        "allowed email",
+			user:        oidctest.User{Subject: "1", Email: "alice@example.com"},
+			wantAccount: "alice@example.com",
+		},
+		{
+			name:        "allowed group",
+			user:        oidctest.User{Subject: "2", Email: "bob@example.com", Groups: []string{"cats"}},
+			wantAccount: "bob@example.com",
+		},
+		{
+			name: "not allowed",
+			user: oidctest.User{Subject: "3", Email: "mallory@example.com", Groups: []string{"dogs"}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := oidctest.New(t)
+			fake.User = tc.user
+			w := newTestWeb(t)
+			p, err := oidc.Discover(context.Background(), fake.Config("http://example.com/admin/login/oidc/callback"))
+			if err != nil {
+				t.Fatalf("oidc.Discover: %v", err)
+			}
+			w.OIDC = p
+			w.OIDCAccess = OIDCAccess{
+				Emails: map[string]db.Role{"alice@example.com": db.RoleOwner},
+				Groups: map[string]db.Role{"cats": db.RoleViewer},
+			}
+			e := newTestEcho(t)
+			w.Bind(e)
+
+			// Start sign-in, which redirects to the provider.
+			rec := serve(e, http.MethodGet, "/admin/login/oidc", nil)
+			if rec.Code != http.StatusFound {
+				t.Fatalf("sign-in start status = %d, want %d", rec.Code, http.StatusFound)
+			}
+			state := findCookie(rec, oidcCookieName)
+			if state == nil {
+				t.Fatal("sign-in start should set the admin_oidc cookie")
+			}
+
+			// The fake provider signs the user in right away and redirects back.
+			client := fake.Server.Client()
+			client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+			resp, err := client.Get(rec.Header().Get("Location"))
+			if err != nil {
+				t.Fatalf("authorization request: %v", err)
+			}
+			resp.Body.Close()
+			callback, err := url.Parse(resp.Header.Get("Location"))
+			if err != nil {
+				t.Fatalf("url.Parse: %v", err)
+			}
+
+			rec = serve(e, http.MethodGet, callback.RequestURI(), nil, state)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("callback status = %d, want %d", rec.Code, http.StatusOK)
+			}
+			session := findCookie(rec, adminCookieName)
+			if tc.wantAccount == "" {
+				if session != nil {
+					t.Error("admin_session cookie should not be set for users not on the allowlist")
+				}
+				return
+			}
+			if session == nil {
+				t.Fatal("admin_session cookie should be set after signing in")
+			}
+			ac, err := cookie.ParseCookie[AdminCookie](session.Value, w.Secret)
+			if err != nil {
+				t.Fatalf("cookie.ParseCookie: %v", err)
+			}
+			if !ac.OIDC || ac.Account != tc.wantAccount {
+				t.Errorf("session = %+v, want OIDC session for %q", ac, tc.wantAccount)
+			}
+
+			rec = serve(e, http.MethodGet, "/admin/", nil, session)
+			if rec.Code != http.StatusOK {
+				t.Errorf("dashboard status = %d, want %d", rec.Code, http.StatusOK)
+			}
+
+			// Removing the user from the allowlist revokes access.
+			w.OIDCAccess = OIDCAccess{}
+			rec = serve(e, http.MethodGet, "/admin/", nil, session)
+			if rec.Code != http.StatusFound {
+				t.Errorf("dashboard status after revocation = %d, want %d", rec.Code, http.StatusFound)
+			}
+		})
+	}
+}
+
+func TestAdminOIDCCallback_StateMismatch(t *testing.T) {
+	fake := oidctest.New(t)
+	w := newTestWeb(t)
+	p, err := oidc.Discover(context.Background(), fake.Config("http://example.com/admin/login/oidc/callback"))
+	if err != nil {
+		t.Fatalf("oidc.Discover: %v", err)
+	}
+	w.OIDC = p
+	e := newTestEcho(t)
+	w.Bind(e)
+
+	value, err := cookie.SaveCookie(OIDCCookie{State: "expected"}, w.Secret)
+	if err != nil {
+		t.Fatalf("cookie.SaveCookie: %v", err)
+	}
+	rec := serve(e, http.MethodGet, "/admin/login/oidc/callback?code=x&state=forged", nil,
+		&http.Cookie{Name: oidcCookieName, Value: value})
+	if findCookie(rec, adminCookieName) != nil {
+		t.Error("admin_session cookie should not be set when state doesn't match")
+	}
+}
@@ -1,17 +1,21 @@
 package web
 
 import (
+	"bytes"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/labstack/echo/v5"
+	"github.com/nevkontakte/pat/chrono/chronotest"
 	"github.com/nevkontakte/pat/db"
 	"github.com/nevkontakte/pat/db/dbtest"
 	"github.com/nevkontakte/pat/tmpl"
 	"github.com/nevkontakte/pat/web/cookie"
+	"github.com/nevkontakte/pat/web/proxy"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -52,7 +56,7 @@ func validAdminCookieValue(t *testing.T, w *Web) string {
 	return string(value)
 }
 
-// requireAdmin middleware tests
+// requireRole middleware tests
 
 func TestRequireAdmin(t *testing.T) {
 	tests := []struct {
@@ -100,7 +104,7 @@ func TestRequireAdmin(t *testing.T) {
 			c := e.NewContext(req, rec)
 
 			called := false
-			err := w.requireAdmin(func(c *echo.Context) error {
+			err := w.requireRole(db.RoleViewer)(func(c *echo.Context) error {
 				called = true
 				return c.String(http.StatusOK, "ok")
 			})(c)
@@ -193,6 +197,45 @@ func TestAdminLoginPost_CorrectPassword(t *testing.T) {
 	}
 }
 
+func TestAdminLoginPost_SecureCookie(t *testing.T) {
+	w := newTestWeb(t)
+	trusted, err := proxy.ParseTrusted("10.0.0.1")
+	if err != nil {
+		t.Fatalf("proxy.ParseTrusted: %v", err)
+	}
+	w.Proxies = trusted
+	e := echo.New()
+
+	tests := []struct {
+		remote     string
+		wantSecure bool
+	}{
+		{remote: "10.0.0.1:1234", wantSecure: true},
+		{remote: "203.0.113.1:1234", wantSecure: false},
+	}
+
+	for _, tc := range tests {
+		form := url.Values{"password": {"testpass"}}
+		req := httptest.NewRequest(http.MethodPost, "/admin/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("X-Forwarded-Proto", "https")
+		req.RemoteAddr = tc.remote
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		if err := w.adminLoginPost(c); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		ck := findCookie(rec, adminCookieName)
+		if ck == nil {
+			t.Fatal("admin_session cookie should be set after successful login")
+		}
+		if ck.Secure != tc.wantSecure {
+			t.Errorf("request from %s: cookie Secure = %v, want %v", tc.remote, ck.Secure, tc.wantSecure)
+		}
+	}
+}
+
 func TestAdminLoginPost_WrongPassword(t *testing.T) {
 	w := newTestWeb(t)
 	e := newTestEcho(t)
@@ -219,6 +262,35 @@ func TestAdminLoginPost_WrongPassword(t *testing.T) {
 	}
 }
 
+func TestAdminLoginPost_UnknownAccount(t *testing.T) {
+	w := newTestWeb(t)
+	e := newTestEcho(t)
+
+	if err := db.InviteAdmin(w.DB, "invited", db.RoleViewer, db.DefaultAdmin, "token"); err != nil {
+		t.Fatalf("db.InviteAdmin: %v", err)
+	}
+	for _, account := range []string{"nobody", "invited"} {
+		if hash, ok := w.passwordHash(account); ok || !bytes.Equal(hash, dummyPasswordHash) {
+			t.Errorf("passwordHash(%q) = %q, %v, want the dummy hash and false", account, hash, ok)
+		}
+	}
+
+	// Even the password of the dummy hash doesn't log in.
+	form := url.Values{"username": {"nobody"}, "password": {"no account has this password"}}
+	req := httptest.NewRequest(http.MethodPost, "/admin/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	if err := w.adminLoginPost(e.NewContext(req, rec)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(rec.Body.String(), "Wrong password") {
+		t.Error("response should contain the error message")
+	}
+	if findCookie(rec, adminCookieName) != nil || findCookie(rec, secondFactorCookieName) != nil {
+		t.Error("no cookies should be set after a login to an unknown account")
+	}
+}
+
 // Logout handler tests
 
 func TestAdminLogout(t *testing.T) {
@@ -285,3 +357,20 @@ func TestAdminDashboard(t *testing.T) {
 		t.Error("dashboard should have a logout link")
 	}
 }
+
+func TestAdminDashboard_MoodSince(t *testing.T) {
+	w, e := newTestServer(t)
+	now := time.Now()
+	w.Clock = chronotest.NewClock(now)
+	w.DB.Model(&db.Cat{ID: db.SplotchID}).Update("latest_pat", now.Add(-30*24*time.Hour))
+	w.DB.Create(&db.Journal{
+		CreatedAt: now.Add(-3 * 24 * time.Hour),
+		CatID:     db.SplotchID,
+		Event:     db.Event{Type: db.EventMoodChanged, PrevMood: db.MoodIdle, Mood: db.MoodImpatient},
+	})
+
+	rec := serve(e, http.MethodGet, "/admin/", nil, sessionCookie(t, w, db.DefaultAdmin))
+	if body := rec.Body.String(); !strings.Contains(body, "impatient <span class=\"muted\">since 3 days ago</span>") {
+		t.Errorf("dashboard should show since when Splotch is impatient, got:\n%s", body)
+	}
+}
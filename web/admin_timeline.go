@@ -0,0 +1,182 @@
+package web
+
+import (
+	"fmt"
+	"iter"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v5"
+	"github.com/nevkontakte/pat/db"
+)
+
+const (
+	// timelineDefaultHours is the default length of the simulated range.
+	timelineDefaultHours = 24
+	// timelineMaxHours limits the length of the simulated range.
+	timelineMaxHours = 7 * 24
+	// timelineMaxChanges limits the number of listed mood changes.
+	timelineMaxChanges = 500
+	// timelineInputLayout is the format of datetime-local form inputs.
+	timelineInputLayout = "2006-01-02T15:04"
+)
+
+// timelineMoods are the moods in the timeline legend, in order.
+var timelineMoods = []db.Mood{db.MoodPat, db.MoodIdleHappy, db.MoodIdle, db.MoodIdleBlink, db.MoodImpatient}
+
+// sparks are the levels of the noise sparklines, from 0 to 1.
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// timelineHour is a row of the timeline with the cat's mood and the noise at
+// every minute of the hour.
+type timelineHour struct {
+	Start time.Time
+	Moods []db.Mood
+	Noise []string // Sparkline for each of the noise curves.
+}
+
+// timelineChange is a moment when the cat's mood changed.
+type timelineChange struct {
+	At       time.Time
+	From, To db.Mood
+}
+
+type timelineData struct {
+	Error     error
+	Admin     AdminIdentity
+	Cat       db.Cat
+	From      string // Start of the range, in timelineInputLayout.
+	Hours     int    // Length of the range.
+	PatAt     string // Hypothetical latest pat, in timelineInputLayout.
+	Legend    []db.Mood
+	Unknown   bool     // Whether some moods precede the latest interaction.
+	Curves    []string // Noise curve names.
+	Rows      []timelineHour
+	Changes   []timelineChange
+	Truncated bool // Whether there were more than timelineMaxChanges changes.
+}
+
+// adminTimeline simulates the cat's mood minute by minute over a time range,
+// optionally pretending that the latest pat happened at a different time.
+//
+// All times are in UTC.
+func (w *Web) adminTimeline(c *echo.Context) error {
+	cat, err := w.catFromParam(c)
+	if err != nil {
+		return err
+	}
+	data := &timelineData{
+		Admin:  adminFromContext(c),
+		Legend: timelineMoods,
+		Hours:  timelineDefaultHours,
+	}
+
+	now := w.clock().Now().UTC()
+	from := now.Add(-timelineDefaultHours / 2 * time.Hour).Truncate(time.Hour)
+	if s := c.QueryParam("from"); s != "" {
+		if from, err = time.Parse(timelineInputLayout, s); err != nil {
+			data.Error = fmt.Errorf("Invalid start time %q.", s)
+		}
+	}
+	if s := c.QueryParam("hours"); s != "" {
+		hours, err := strconv.Atoi(s)
+		if err != nil || hours < 1 || hours > timelineMaxHours {
+			data.Error = fmt.Errorf("Range must be between 1 and %d hours.", timelineMaxHours)
+		} else {
+			data.Hours = hours
+		}
+	}
+	var patAt time.Time
+	if s := c.QueryParam("pat"); s != "" {
+		if patAt, err = time.Parse(timelineInputLayout, s); err != nil {
+			data.Error = fmt.Errorf("Invalid pat time %q.", s)
+		} else {
+			data.PatAt = s
+		}
+	}
+	data.Cat = cat
+	data.From = from.Format(timelineInputLayout)
+	if data.Error != nil {
+		return c.Render(http.StatusOK, "admin_timeline.html", data)
+	}
+
+	curves := cat.MoodNoise()
+	for _, curve := range curves {
+		data.Curves = append(data.Curves, curve.Cue)
+	}
+	until := from.Add(time.Duration(data.Hours)*time.Hour - time.Minute)
+	var row *timelineHour
+	var noise [][]rune
+	prev := db.Mood("")
+	for t, mood := range whatIfMoods(cat, patAt, from, until) {
+		if row == nil || t.Sub(row.Start) >= time.Hour {
+			if row != nil {
+				row.Noise = sparklines(noise)
+			}
+			data.Rows = append(data.Rows, timelineHour{Start: t})
+			row = &data.Rows[len(data.Rows)-1]
+			noise = make([][]rune, len(curves))
+		}
+		row.Moods = append(row.Moods, mood)
+		data.Unknown = data.Unknown || mood == ""
+		for i, curve := range curves {
+			noise[i] = append(noise[i], spark(curve.Noise.At(t)))
+		}
+
+		if prev != "" && mood != "" && mood != prev {
+			if len(data.Changes) < timelineMaxChanges {
+				data.Changes = append(data.Changes, timelineChange{At: t, From: prev, To: mood})
+			} else {
+				data.Truncated = true
+			}
+		}
+		prev = mood
+	}
+	if row != nil {
+		row.Noise = sparklines(noise)
+	}
+	return c.Render(http.StatusOK, "admin_timeline.html", data)
+}
+
+// whatIfMoods iterates over the cat's moods every minute of the range, as if
+// it was patted at the given time. The pat is ignored if the time is zero.
+//
+// The mood is empty for minutes before the latest interaction with the cat,
+// real or hypothetical, since the cat's state at that time is unknown.
+func whatIfMoods(cat db.Cat, patAt, from, until time.Time) iter.Seq2[time.Time, db.Mood] {
+	whatIf := cat
+	if !patAt.IsZero() {
+		whatIf.LatestPat = patAt
+	}
+	return func(yield func(time.Time, db.Mood) bool) {
+		for t := from; !t.After(until); t = t.Add(time.Minute) {
+			// The cat's real history until the hypothetical pat.
+			c := cat
+			if !patAt.IsZero() && !t.Before(patAt) {
+				c = whatIf
+			}
+			mood := db.Mood("")
+			if !t.Before(c.ChangedAt()) {
+				mood = c.MoodAt(t)
+			}
+			if !yield(t, mood) {
+				return
+			}
+		}
+	}
+}
+
+// spark returns the sparkline character for a noise value in [0, 1).
+func spark(v float64) rune {
+	i := int(v * float64(len(sparks)))
+	return sparks[max(0, min(i, len(sparks)-1))]
+}
+
+func sparklines(noise [][]rune) []string {
+	lines := make([]string, len(noise))
+	for i, n := range noise {
+		lines[i] = string(n)
+	}
+	return lines
+}
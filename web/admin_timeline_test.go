@@ -0,0 +1,73 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nevkontakte/pat/chrono/chronotest"
+	"github.com/nevkontakte/pat/db"
+)
+
+func TestAdminTimeline(t *testing.T) {
+	w, e := newTestServer(t)
+	w.Clock = chronotest.NewClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	// Splotch was last patted long ago and is impatient.
+	w.DB.Model(&db.Cat{ID: db.SplotchID}).Update("latest_pat", time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC))
+	addTestAdmin(t, w, "viewer", db.RoleViewer)
+	viewer := sessionCookie(t, w, "viewer")
+
+	if rec := serve(e, http.MethodGet, "/admin/cats/splotch/timeline", nil); rec.Code == http.StatusOK {
+		t.Errorf("anonymous GET: status = %d, want an error", rec.Code)
+	}
+	if rec := serve(e, http.MethodGet, "/admin/cats/stray/timeline", nil, viewer); rec.Code != http.StatusNotFound {
+		t.Errorf("GET of a missing cat: status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	rec := serve(e, http.MethodGet, "/admin/cats/splotch/timeline", nil, viewer)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET: status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	body := rec.Body.String()
+	if got := strings.Count(body, `class="timeline-cell mood-`); got < 24*60 {
+		t.Errorf("default timeline has %d mood cells, want at least one per minute of a day", got)
+	}
+	if !strings.Contains(body, `value="2024-01-01T00:00"`) {
+		t.Error("default timeline should start 12 hours ago")
+	}
+
+	// A pat in the middle of the range shows up as a mood change.
+	rec = serve(e, http.MethodGet, "/admin/cats/splotch/timeline?from=2024-01-01T10:00&hours=2&pat=2024-01-01T11:00", nil, viewer)
+	body = rec.Body.String()
+	if got := strings.Count(body, `class="timeline-cell mood-`); got != 2*60+len(timelineMoods) {
+		t.Errorf("two hour timeline has %d mood cells, want %d", got, 2*60+len(timelineMoods))
+	}
+	if !strings.Contains(body, "Jan 1 11:00</td>\n            <td>impatient → pat") {
+		t.Error("timeline should show the hypothetical pat as a mood change")
+	}
+	if !strings.Contains(body, `class="sparkline"`) {
+		t.Error("timeline should show noise sparklines")
+	}
+
+	// Before the real latest pat, the mood is unknown rather than computed from
+	// the current state.
+	patted := time.Date(2024, 1, 1, 11, 30, 0, 0, time.UTC)
+	w.DB.Model(&db.Cat{ID: db.SplotchID}).Update("latest_pat", patted)
+	rec = serve(e, http.MethodGet, "/admin/cats/splotch/timeline", nil, viewer)
+	body = rec.Body.String()
+	if got, want := strings.Count(body, `title="unknown"`), 11*60+30; got != want {
+		t.Errorf("timeline with a pat at 11:30 has %d unknown minutes, want %d", got, want)
+	}
+	if got := strings.Count(body, `title="pat"`); got != 1 {
+		t.Errorf("timeline with a pat at 11:30 has %d pat minutes, want 1", got)
+	}
+	if strings.Contains(body, "→ pat") {
+		t.Error("timeline shouldn't show the real pat as a change from an unknown mood")
+	}
+
+	rec = serve(e, http.MethodGet, "/admin/cats/splotch/timeline?hours=1000", nil, viewer)
+	if !strings.Contains(rec.Body.String(), `role="alert"`) {
+		t.Error("too long range should show an error")
+	}
+}
@@ -0,0 +1,119 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v5"
+	"github.com/nevkontakte/pat/db"
+	"gorm.io/gorm"
+)
+
+// apiTokenPrefix makes API tokens recognizable, e.g. by secret scanners.
+const apiTokenPrefix = "pat_"
+
+type tokensData struct {
+	Error    error
+	Admin    AdminIdentity
+	Tokens   []db.APIToken
+	Scopes   []db.Scope // Scopes the admin is allowed to grant.
+	NewToken string     // Freshly created token, shown only once.
+}
+
+func (w *Web) renderTokens(c *echo.Context, data *tokensData) error {
+	data.Admin = adminFromContext(c)
+	if !data.Admin.External {
+		tokens, err := db.APITokens(w.DB, data.Admin.Account)
+		if err != nil {
+			return fmt.Errorf("failed to load API tokens: %w", err)
+		}
+		data.Tokens = tokens
+		data.Scopes = grantableScopes(data.Admin.Role)
+	}
+	return c.Render(http.StatusOK, "admin_tokens.html", data)
+}
+
+// grantableScopes returns scopes that an admin with the role may grant.
+func grantableScopes(role db.Role) []db.Scope {
+	var scopes []db.Scope
+	for _, s := range db.AllScopes {
+		if role.Includes(s.Role()) {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
+
+// adminTokens lists API tokens of the current admin.
+func (w *Web) adminTokens(c *echo.Context) error {
+	return w.renderTokens(c, &tokensData{})
+}
+
+// adminTokenPost creates a new API token for the current admin.
+func (w *Web) adminTokenPost(c *echo.Context) error {
+	admin := adminFromContext(c)
+	if admin.External {
+		// Accounts managed by the OpenID provider may lose access at any time
+		// without us knowing, so they can't have long-lived tokens.
+		return echo.ErrForbidden
+	}
+
+	name := strings.TrimSpace(c.FormValue("name"))
+	form, err := c.FormValues()
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+	var scopes db.Scopes
+	for _, s := range form["scope"] {
+		scope := db.Scope(s)
+		if !scope.Role().Valid() || !admin.Role.Includes(scope.Role()) {
+			return w.renderTokens(c, &tokensData{Error: fmt.Errorf("You can't grant the %q scope.", s)})
+		}
+		scopes = append(scopes, scope)
+	}
+	if name == "" || len(scopes) == 0 {
+		return w.renderTokens(c, &tokensData{Error: fmt.Errorf("Token name and at least one scope are required.")})
+	}
+
+	secret, err := randomToken()
+	if err != nil {
+		return err
+	}
+	token := apiTokenPrefix + secret
+	t, err := db.CreateAPIToken(w.DB, admin.Account, name, scopes, token)
+	if err != nil {
+		return fmt.Errorf("failed to create API token: %w", err)
+	}
+	if err := w.recordAdminAction(c, admin.Account, db.Event{
+		Type:        db.EventTokenCreated,
+		Description: fmt.Sprintf("Created API token #%d %q.", t.ID, name),
+	}); err != nil {
+		return err
+	}
+	return w.renderTokens(c, &tokensData{NewToken: token})
+}
+
+// adminTokenRevokePost revokes an API token of the current admin.
+func (w *Web) adminTokenRevokePost(c *echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.ErrNotFound
+	}
+	account := adminFromContext(c).Account
+	err = db.RevokeAPIToken(w.DB, account, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return echo.ErrNotFound
+	} else if err != nil {
+		return fmt.Errorf("failed to revoke API token: %w", err)
+	}
+	if err := w.recordAdminAction(c, account, db.Event{
+		Type:        db.EventTokenRevoked,
+		Description: fmt.Sprintf("Revoked API token #%d.", id),
+	}); err != nil {
+		return err
+	}
+	return c.Redirect(http.StatusFound, "/admin/tokens")
+}
@@ -0,0 +1,57 @@
+package web
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/nevkontakte/pat/db"
+)
+
+func TestAdminTokens(t *testing.T) {
+	w, e := newTestServer(t)
+	addTestAdmin(t, w, "viewer", db.RoleViewer)
+	session := sessionCookie(t, w, "viewer")
+
+	rec := serve(e, http.MethodPost, "/admin/tokens", url.Values{"name": {"export"}, "scope": {string(db.ScopeModerate)}}, session)
+	if !strings.Contains(rec.Body.String(), "You can&#39;t grant") {
+		t.Errorf("viewer should not be able to grant the moderate scope, got:\n%s", rec.Body.String())
+	}
+
+	rec = serve(e, http.MethodPost, "/admin/tokens", url.Values{"name": {"export"}, "scope": {string(db.ScopeReadJournal)}}, session)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("create status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	token := regexp.MustCompile(`pat_[A-Za-z0-9_-]+`).FindString(rec.Body.String())
+	if token == "" {
+		t.Fatalf("create response should contain the new token, got:\n%s", rec.Body.String())
+	}
+
+	rec = serveToken(e, http.MethodGet, "/admin/", token, "")
+	if rec.Code != http.StatusOK {
+		t.Errorf("dashboard with token status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	rec = serveToken(e, http.MethodGet, "/admin/tokens", token, "")
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("token management with token status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+
+	tokens, err := db.APITokens(w.DB, "viewer")
+	if err != nil || len(tokens) != 1 {
+		t.Fatalf("db.APITokens() = %+v, %v; want a single token", tokens, err)
+	}
+	rec = serve(e, http.MethodPost, "/admin/tokens/1/revoke", nil, sessionCookie(t, w, db.DefaultAdmin))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("revoking another admin's token status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	rec = serve(e, http.MethodPost, "/admin/tokens/1/revoke", nil, session)
+	if rec.Code != http.StatusFound {
+		t.Errorf("revoke status = %d, want %d", rec.Code, http.StatusFound)
+	}
+	rec = serveToken(e, http.MethodGet, "/admin/", token, "")
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("dashboard with revoked token status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,299 @@
+package web
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v5"
+	"github.com/nevkontakte/pat/db"
+	"github.com/nevkontakte/pat/web/cookie"
+	"github.com/nevkontakte/pat/web/totp"
+	"gorm.io/gorm"
+	"rsc.io/qr"
+)
+
+const (
+	secondFactorCookieName = "admin_2fa"
+	// secondFactorTimeout limits how long the admin may take to enter the code
+	// after entering the password.
+	secondFactorTimeout = 5 * time.Minute
+	// totpIssuer is the service name shown in authenticator apps.
+	totpIssuer = "Splotch"
+	// recoveryCodeCount is the number of recovery codes issued on enrollment.
+	recoveryCodeCount = 10
+	// enrollmentTimeout limits how long the admin may take to confirm a new
+	// second factor.
+	enrollmentTimeout = 30 * time.Minute
+)
+
+// SecondFactorCookie is issued after a successful password check and allows the
+// admin to proceed to the second factor check.
+type SecondFactorCookie struct {
+	Account string
+	Expires time.Time
+}
+
+// PendingEnrollment carries a second factor secret from the enrollment page to
+// its confirmation, so that the secret is only stored once confirmed. It is
+// signed and embedded into the enrollment form.
+type PendingEnrollment struct {
+	Account string
+	Secret  []byte
+	Expires time.Time
+}
+
+// passwordVerified continues the login flow after the account password has
+// been verified.
+//
+// If the account has second factor enrolled, the admin is redirected to the
+// second factor check, otherwise the session starts right away.
+func (w *Web) passwordVerified(c *echo.Context, account string) error {
+	sf, err := db.SecondFactorFor(w.DB, account)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && !sf.Confirmed) {
+		return w.startSession(c, account)
+	} else if err != nil {
+		return fmt.Errorf("failed to load second factor for %q: %w", account, err)
+	}
+
+	value, err := cookie.SaveCookie(SecondFactorCookie{
+		Account: account,
+		Expires: w.clock().Now().Add(secondFactorTimeout),
+	}, w.Secret)
+	if err != nil {
+		return fmt.Errorf("failed to create second factor cookie: %w", err)
+	}
+	c.SetCookie(&http.Cookie{
+		Name:     secondFactorCookieName,
+		Value:    value,
+		Path:     "/admin/login",
+		HttpOnly: true,
+		Secure:   w.scheme(c) == "https",
+		SameSite: http.SameSiteStrictMode,
+		MaxAge:   int(secondFactorTimeout / time.Second),
+	})
+	return c.Redirect(http.StatusFound, "/admin/login/2fa")
+}
+
+// pendingSecondFactor returns the account awaiting the second factor check.
+func (w *Web) pendingSecondFactor(c *echo.Context) (string, bool) {
+	raw, err := c.Cookie(secondFactorCookieName)
+	if err != nil {
+		return "", false
+	}
+	sfc, err := cookie.ParseCookie[SecondFactorCookie](raw.Value, w.Secret)
+	if err != nil || sfc.Account == "" || !w.clock().Now().Before(sfc.Expires) {
+		return "", false
+	}
+	return sfc.Account, true
+}
+
+func (w *Web) adminSecondFactor(c *echo.Context) error {
+	if _, ok := w.pendingSecondFactor(c); !ok {
+		return c.Redirect(http.StatusFound, "/admin/login")
+	}
+	return c.Render(http.StatusOK, "login_totp.html", w.loginData(nil))
+}
+
+func (w *Web) adminSecondFactorPost(c *echo.Context) error {
+	account, ok := w.pendingSecondFactor(c)
+	if !ok {
+		return c.Redirect(http.StatusFound, "/admin/login")
+	}
+
+	err := w.checkSecondFactor(account, c.FormValue("code"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Render(http.StatusOK, "login_totp.html", w.loginData(fmt.Errorf("Wrong code.")))
+	} else if errors.Is(err, db.ErrSecondFactorLocked) {
+		return c.Render(http.StatusOK, "login_totp.html", w.loginData(fmt.Errorf("Too many wrong codes. Try again later.")))
+	} else if err != nil {
+		return err
+	}
+
+	c.SetCookie(&http.Cookie{
+		Name:   secondFactorCookieName,
+		Value:  "",
+		Path:   "/admin/login",
+		MaxAge: -1,
+	})
+	return w.startSession(c, account)
+}
+
+// checkSecondFactor verifies a TOTP or a recovery code for the account.
+//
+// Wrong codes are counted, and after db.SecondFactorAttempts of them the
+// account is locked for a while: all codes, correct or not, are refused with
+// db.ErrSecondFactorLocked.
+//
+// Returns gorm.ErrRecordNotFound if the code is not valid.
+func (w *Web) checkSecondFactor(account string, code string) error {
+	sf, err := db.SecondFactorFor(w.DB, account)
+	if err != nil {
+		return fmt.Errorf("failed to load second factor for %q: %w", account, err)
+	}
+	now := w.clock().Now()
+	if sf.Locked(now) {
+		return db.ErrSecondFactorLocked
+	}
+
+	code = strings.TrimSpace(code)
+	if counter, ok := totp.Validate(sf.Secret, code, now, sf.LastCounter); ok {
+		err = db.AcceptSecondFactorCode(w.DB, account, counter)
+	} else {
+		err = db.UseRecoveryCode(w.DB, account, normalizeRecoveryCode(code))
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if lockErr := db.SecondFactorFailed(w.DB, account, now); lockErr != nil {
+			return lockErr
+		}
+		return err
+	} else if err != nil {
+		return err
+	}
+	return db.SecondFactorSucceeded(w.DB, account)
+}
+
+type secondFactorData struct {
+	Error         error
+	External      bool     // The account's authentication is managed by the OpenID provider.
+	Enrolled      bool     // Second factor is already confirmed.
+	Secret        string   // Base32-encoded secret for manual entry.
+	URI           string   // Provisioning URI for authenticator apps.
+	QR            string   // Data URL of the provisioning URI QR code image.
+	Enrollment    string   // Signed PendingEnrollment for the confirmation form.
+	RecoveryCodes []string // Freshly issued recovery codes, shown only once.
+}
+
+// adminEnrollment shows second factor status, or starts a new enrollment.
+//
+// The new secret isn't stored until it's confirmed, so that reloading the page
+// doesn't invalidate a secret the admin has already added to their app.
+func (w *Web) adminEnrollment(c *echo.Context) error {
+	if adminFromContext(c).External {
+		return c.Render(http.StatusOK, "admin_totp.html", &secondFactorData{External: true})
+	}
+	account := adminFromContext(c).Account
+	sf, err := db.SecondFactorFor(w.DB, account)
+	if err == nil && sf.Confirmed {
+		return c.Render(http.StatusOK, "admin_totp.html", &secondFactorData{Enrolled: true})
+	} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("failed to load second factor: %w", err)
+	}
+
+	key, err := totp.NewKey(totpIssuer, account)
+	if err != nil {
+		return err
+	}
+	enrollment, err := cookie.SaveCookie(PendingEnrollment{
+		Account: account,
+		Secret:  key.Secret,
+		Expires: w.clock().Now().Add(enrollmentTimeout),
+	}, w.Secret)
+	if err != nil {
+		return fmt.Errorf("failed to start second factor enrollment: %w", err)
+	}
+	data, err := enrollmentData(key, enrollment, nil)
+	if err != nil {
+		return err
+	}
+	return c.Render(http.StatusOK, "admin_totp.html", data)
+}
+
+// adminEnrollmentPost stores and confirms the pending enrollment, given a TOTP
+// code generated with its secret.
+func (w *Web) adminEnrollmentPost(c *echo.Context) error {
+	if adminFromContext(c).External {
+		return c.Render(http.StatusOK, "admin_totp.html", &secondFactorData{External: true})
+	}
+	account := adminFromContext(c).Account
+	sf, err := db.SecondFactorFor(w.DB, account)
+	if err == nil && sf.Confirmed {
+		return c.Render(http.StatusOK, "admin_totp.html", &secondFactorData{Enrolled: true})
+	} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("failed to load second factor: %w", err)
+	}
+	enrollment := c.FormValue("enrollment")
+	pending, err := cookie.ParseCookie[PendingEnrollment](enrollment, w.Secret)
+	if err != nil || pending.Account != account || !w.clock().Now().Before(pending.Expires) {
+		return c.Redirect(http.StatusFound, "/admin/2fa")
+	}
+
+	key := totp.Key{Secret: pending.Secret, Issuer: totpIssuer, Account: account}
+	counter, ok := totp.Validate(pending.Secret, strings.TrimSpace(c.FormValue("code")), w.clock().Now(), 0)
+	if !ok {
+		data, err := enrollmentData(key, enrollment, fmt.Errorf("Wrong code."))
+		if err != nil {
+			return err
+		}
+		return c.Render(http.StatusOK, "admin_totp.html", data)
+	}
+
+	codes, err := newRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return err
+	}
+	normalized := make([]string, len(codes))
+	for i, code := range codes {
+		normalized[i] = normalizeRecoveryCode(code)
+	}
+	err = w.DB.Transaction(func(tx *gorm.DB) error {
+		if err := db.EnrollSecondFactor(tx, account, pending.Secret); err != nil {
+			return err
+		}
+		return db.ConfirmSecondFactor(tx, account, counter, normalized)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to confirm second factor: %w", err)
+	}
+	return c.Render(http.StatusOK, "admin_totp.html", &secondFactorData{
+		Enrolled:      true,
+		RecoveryCodes: codes,
+	})
+}
+
+func enrollmentData(key totp.Key, enrollment string, err error) (*secondFactorData, error) {
+	code, qrErr := qr.Encode(key.URI(), qr.M)
+	if qrErr != nil {
+		return nil, fmt.Errorf("failed to encode the provisioning URI: %w", qrErr)
+	}
+	return &secondFactorData{
+		Error:      err,
+		Secret:     key.EncodedSecret(),
+		URI:        key.URI(),
+		QR:         "data:image/png;base64," + base64.StdEncoding.EncodeToString(code.PNG()),
+		Enrollment: enrollment,
+	}, nil
+}
+
+// newRecoveryCodes generates n random recovery codes in a human-friendly
+// format, e.g. "abcde-fghij".
+func newRecoveryCodes(n int) ([]string, error) {
+	enc := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, n)
+	for i := range codes {
+		raw := make([]byte, 7)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		s := strings.ToLower(enc.EncodeToString(raw))[:10]
+		codes[i] = s[:5] + "-" + s[5:]
+	}
+	return codes, nil
+}
+
+// normalizeRecoveryCode brings a user-entered recovery code into the canonical
+// form, in which it is hashed.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, code)
+}
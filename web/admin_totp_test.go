@@ -0,0 +1,305 @@
+package web
+
+import (
+	"errors"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v5"
+	"github.com/nevkontakte/pat/chrono/chronotest"
+	"github.com/nevkontakte/pat/db"
+	"github.com/nevkontakte/pat/web/cookie"
+	"github.com/nevkontakte/pat/web/totp"
+	"gorm.io/gorm"
+)
+
+var testTOTPSecret = []byte("12345678901234567890")
+
+// enrollTestAdmin sets up a confirmed second factor with the given recovery
+// codes for the default admin.
+func enrollTestAdmin(t *testing.T, w *Web, recoveryCodes ...string) {
+	t.Helper()
+	if err := db.EnrollSecondFactor(w.DB, db.DefaultAdmin, testTOTPSecret); err != nil {
+		t.Fatalf("db.EnrollSecondFactor: %v", err)
+	}
+	if err := db.ConfirmSecondFactor(w.DB, db.DefaultAdmin, 0, recoveryCodes); err != nil {
+		t.Fatalf("db.ConfirmSecondFactor: %v", err)
+	}
+}
+
+func secondFactorCookieValue(t *testing.T, w *Web, expires time.Time) string {
+	t.Helper()
+	value, err := cookie.SaveCookie(SecondFactorCookie{Account: db.DefaultAdmin, Expires: expires}, w.Secret)
+	if err != nil {
+		t.Fatalf("cookie.SaveCookie: %v", err)
+	}
+	return value
+}
+
+func findCookie(rec *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, ck := range rec.Result().Cookies() {
+		if ck.Name == name {
+			return ck
+		}
+	}
+	return nil
+}
+
+func postForm(e *echo.Echo, target string, form url.Values, cookies ...*http.Cookie) (*echo.Context, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for _, ck := range cookies {
+		req.AddCookie(ck)
+	}
+	rec := httptest.NewRecorder()
+	return e.NewContext(req, rec), rec
+}
+
+func TestAdminLoginPost_SecondFactorRequired(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	w := newTestWeb(t)
+	w.Clock = chronotest.NewClock(now)
+	enrollTestAdmin(t, w)
+	e := echo.New()
+
+	c, rec := postForm(e, "/admin/login", url.Values{"password": {"testpass"}})
+	if err := w.adminLoginPost(c); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if loc := rec.Header().Get("Location"); loc != "/admin/login/2fa" {
+		t.Errorf("expected redirect to /admin/login/2fa, got %q", loc)
+	}
+	if findCookie(rec, adminCookieName) != nil {
+		t.Error("admin_session cookie should not be set before the second factor check")
+	}
+	ck := findCookie(rec, secondFactorCookieName)
+	if ck == nil {
+		t.Fatal("admin_2fa cookie should be set after the password check")
+	}
+	sfc, err := cookie.ParseCookie[SecondFactorCookie](ck.Value, w.Secret)
+	if err != nil {
+		t.Fatalf("cookie.ParseCookie failed: %v", err)
+	}
+	if want := now.Add(secondFactorTimeout); !sfc.Expires.Equal(want) {
+		t.Errorf("second factor cookie expires at %v, want %v", sfc.Expires, want)
+	}
+}
+
+func TestAdminSecondFactorPost(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		code        string
+		expires     time.Time
+		wantSession bool
+		wantCode    int
+	}{
+		{
+			name:        "valid code",
+			code:        totp.Code(testTOTPSecret, now),
+			expires:     now.Add(time.Minute),
+			wantSession: true,
+			wantCode:    http.StatusFound,
+		},
+		{
+			name:        "code from previous step",
+			code:        totp.Code(testTOTPSecret, now.Add(-totp.Step)),
+			expires:     now.Add(time.Minute),
+			wantSession: true,
+			wantCode:    http.StatusFound,
+		},
+		{
+			name:        "recovery code",
+			code:        "ABCDE-fghij",
+			expires:     now.Add(time.Minute),
+			wantSession: true,
+			wantCode:    http.StatusFound,
+		},
+		{
+			name:     "wrong code",
+			code:     "123456",
+			expires:  now.Add(time.Minute),
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "stale code",
+			code:     totp.Code(testTOTPSecret, now.Add(-5*totp.Step)),
+			expires:  now.Add(time.Minute),
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "expired password step",
+			code:     totp.Code(testTOTPSecret, now),
+			expires:  now,
+			wantCode: http.StatusFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := newTestWeb(t)
+			w.Clock = chronotest.NewClock(now)
+			enrollTestAdmin(t, w, "abcdefghij")
+			e := newTestEcho(t)
+
+			c, rec := postForm(e, "/admin/login/2fa", url.Values{"code": {tc.code}}, &http.Cookie{
+				Name:  secondFactorCookieName,
+				Value: secondFactorCookieValue(t, w, tc.expires),
+			})
+			if err := w.adminSecondFactorPost(c); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if rec.Code != tc.wantCode {
+				t.Errorf("status = %d, want %d", rec.Code, tc.wantCode)
+			}
+			if got := findCookie(rec, adminCookieName) != nil; got != tc.wantSession {
+				t.Errorf("admin_session cookie set = %v, want %v", got, tc.wantSession)
+			}
+		})
+	}
+}
+
+func TestAdminSecondFactorPost_CodeReuse(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	w := newTestWeb(t)
+	w.Clock = chronotest.NewClock(now)
+	enrollTestAdmin(t, w)
+	e := newTestEcho(t)
+
+	for i, wantSession := range []bool{true, false} {
+		c, rec := postForm(e, "/admin/login/2fa", url.Values{"code": {totp.Code(testTOTPSecret, now)}}, &http.Cookie{
+			Name:  secondFactorCookieName,
+			Value: secondFactorCookieValue(t, w, now.Add(time.Minute)),
+		})
+		if err := w.adminSecondFactorPost(c); err != nil {
+			t.Fatalf("attempt %d: unexpected error: %v", i, err)
+		}
+		if got := findCookie(rec, adminCookieName) != nil; got != wantSession {
+			t.Errorf("attempt %d: admin_session cookie set = %v, want %v", i, got, wantSession)
+		}
+	}
+}
+
+func TestAdminSecondFactorPost_Lockout(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := chronotest.NewClock(now)
+	w := newTestWeb(t)
+	w.Clock = clock
+	enrollTestAdmin(t, w, "abcdefghij")
+	e := newTestEcho(t)
+
+	post := func(code string) *httptest.ResponseRecorder {
+		t.Helper()
+		c, rec := postForm(e, "/admin/login/2fa", url.Values{"code": {code}}, &http.Cookie{
+			Name:  secondFactorCookieName,
+			Value: secondFactorCookieValue(t, w, clock.Now().Add(time.Minute)),
+		})
+		if err := w.adminSecondFactorPost(c); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return rec
+	}
+
+	for i := range db.SecondFactorAttempts {
+		if rec := post("123456"); findCookie(rec, adminCookieName) != nil {
+			t.Fatalf("wrong code #%d started a session", i+1)
+		}
+	}
+	for _, code := range []string{totp.Code(testTOTPSecret, now), "abcde-fghij"} {
+		rec := post(code)
+		if findCookie(rec, adminCookieName) != nil {
+			t.Errorf("correct code %q started a session while the account is locked", code)
+		}
+		if !strings.Contains(rec.Body.String(), "Too many wrong codes") {
+			t.Errorf("correct code %q while locked: page doesn't explain the lockout:\n%s", code, rec.Body.String())
+		}
+	}
+
+	clock.Advance(db.SecondFactorLockout)
+	if rec := post(totp.Code(testTOTPSecret, clock.Now())); findCookie(rec, adminCookieName) == nil {
+		t.Error("correct code after the lockout didn't start a session")
+	}
+}
+
+func TestAdminEnrollment(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	w := newTestWeb(t)
+	w.Clock = chronotest.NewClock(now)
+	e := newTestEcho(t)
+
+	admin := AdminIdentity{Account: db.DefaultAdmin, Role: db.RoleOwner}
+
+	// enroll opens the enrollment page and returns the secret and the
+	// enrollment form value it offers.
+	enroll := func() ([]byte, string) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/admin/2fa", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.Set(adminKey, admin)
+		if err := w.adminEnrollment(c); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		body := rec.Body.String()
+		if !strings.Contains(body, "otpauth://totp/") {
+			t.Error("enrollment page should contain the provisioning URI")
+		}
+		if !strings.Contains(body, `src="data:image/png;base64,`) {
+			t.Error("enrollment page should contain the provisioning URI QR code")
+		}
+		m := regexp.MustCompile(`name="enrollment" value="([^"]*)"`).FindStringSubmatch(body)
+		if m == nil {
+			t.Fatalf("enrollment page should contain the enrollment form:\n%s", body)
+		}
+		pending, err := cookie.ParseCookie[PendingEnrollment](html.UnescapeString(m[1]), w.Secret)
+		if err != nil {
+			t.Fatalf("failed to parse the pending enrollment: %v", err)
+		}
+		return pending.Secret, html.UnescapeString(m[1])
+	}
+
+	secret, enrollment := enroll()
+	// Reloading the page doesn't invalidate the secret shown earlier.
+	enroll()
+	if _, err := db.SecondFactorFor(w.DB, db.DefaultAdmin); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("enrollment shouldn't be stored before a code is entered, got error %v", err)
+	}
+
+	c, rec := postForm(e, "/admin/2fa", url.Values{"code": {totp.Code(secret, now)}, "enrollment": {"forged"}})
+	c.Set(adminKey, admin)
+	if err := w.adminEnrollmentPost(c); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rec.Code != http.StatusFound {
+		t.Errorf("confirmation of a forged enrollment: status = %d, want %d", rec.Code, http.StatusFound)
+	}
+
+	c, rec = postForm(e, "/admin/2fa", url.Values{"code": {totp.Code(secret, now)}, "enrollment": {enrollment}})
+	c.Set(adminKey, admin)
+	if err := w.adminEnrollmentPost(c); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(rec.Body.String(), "recovery codes") {
+		t.Error("confirmation page should show recovery codes")
+	}
+	if sf, _ := db.SecondFactorFor(w.DB, db.DefaultAdmin); !sf.Confirmed {
+		t.Error("enrollment should be confirmed after a valid code")
+	}
+	var count int64
+	w.DB.Model(&db.RecoveryCode{}).Where("account = ?", db.DefaultAdmin).Count(&count)
+	if count != recoveryCodeCount {
+		t.Errorf("stored %d recovery codes, want %d", count, recoveryCodeCount)
+	}
+}
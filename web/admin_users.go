@@ -0,0 +1,157 @@
+package web
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v5"
+	"github.com/nevkontakte/pat/db"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+type adminsData struct {
+	Error      error
+	Admin      AdminIdentity
+	Admins     []db.Admin
+	Roles      []db.Role
+	InviteLink string // Link for the freshly created invite, shown only once.
+}
+
+func (w *Web) renderAdmins(c *echo.Context, data *adminsData) error {
+	admins, err := db.Admins(w.DB)
+	if err != nil {
+		return fmt.Errorf("failed to load admin accounts: %w", err)
+	}
+	data.Admin = adminFromContext(c)
+	data.Admins = admins
+	data.Roles = db.Roles
+	return c.Render(http.StatusOK, "admin_users.html", data)
+}
+
+// adminUsers lists admin accounts and pending invites.
+func (w *Web) adminUsers(c *echo.Context) error {
+	return w.renderAdmins(c, &adminsData{})
+}
+
+// adminInvitePost creates an invite for a new admin account.
+func (w *Web) adminInvitePost(c *echo.Context) error {
+	username := strings.TrimSpace(c.FormValue("username"))
+	role := db.Role(c.FormValue("role"))
+	if username == "" || username == db.DefaultAdmin || !role.Valid() {
+		return w.renderAdmins(c, &adminsData{Error: fmt.Errorf("Invalid username or role.")})
+	}
+	if _, err := db.AdminByUsername(w.DB, username); err == nil {
+		return w.renderAdmins(c, &adminsData{Error: fmt.Errorf("Account %q already exists.", username)})
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		return err
+	}
+	inviter := adminFromContext(c).Account
+	if err := db.InviteAdmin(w.DB, username, role, inviter, token); err != nil {
+		return fmt.Errorf("failed to invite %q: %w", username, err)
+	}
+	if err := w.recordAdminAction(c, inviter, db.Event{
+		Type:        db.EventAdminInvited,
+		Description: fmt.Sprintf("Invited %s as %s.", username, role),
+	}); err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s://%s/admin/invite/%s", w.scheme(c), c.Request().Host, token)
+	return w.renderAdmins(c, &adminsData{InviteLink: link})
+}
+
+// adminRemovePost removes an admin account or revokes a pending invite.
+func (w *Web) adminRemovePost(c *echo.Context) error {
+	username := c.Param("username")
+	remover := adminFromContext(c).Account
+	if username == remover {
+		return w.renderAdmins(c, &adminsData{Error: fmt.Errorf("You can't remove your own account.")})
+	}
+
+	err := db.RemoveAdmin(w.DB, username)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return echo.ErrNotFound
+	} else if err != nil {
+		return fmt.Errorf("failed to remove %q: %w", username, err)
+	}
+	if err := w.recordAdminAction(c, remover, db.Event{
+		Type:        db.EventAdminRemoved,
+		Description: fmt.Sprintf("Removed %s.", username),
+	}); err != nil {
+		return err
+	}
+	return c.Redirect(http.StatusFound, "/admin/admins")
+}
+
+type inviteData struct {
+	Error error
+	Token string
+}
+
+// adminInvite shows the form to accept an invite.
+func (w *Web) adminInvite(c *echo.Context) error {
+	return c.Render(http.StatusOK, "admin_invite.html", &inviteData{Token: c.Param("token")})
+}
+
+// adminAcceptInvitePost accepts an invite by setting the account password.
+func (w *Web) adminAcceptInvitePost(c *echo.Context) error {
+	token := c.Param("token")
+	password := c.FormValue("password")
+	if len(password) < 8 {
+		return c.Render(http.StatusOK, "admin_invite.html", &inviteData{
+			Token: token,
+			Error: fmt.Errorf("Password must be at least 8 characters long."),
+		})
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	a, err := db.AcceptInvite(w.DB, token, hash)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Render(http.StatusOK, "admin_invite.html", &inviteData{
+			Error: fmt.Errorf("This invite is invalid or has already been used."),
+		})
+	} else if err != nil {
+		return fmt.Errorf("failed to accept invite: %w", err)
+	}
+	if err := w.recordAdminAction(c, a.Username, db.Event{
+		Type:        db.EventAdminJoined,
+		Description: fmt.Sprintf("Joined as %s, invited by %s.", a.Role, a.InvitedBy),
+	}); err != nil {
+		return err
+	}
+	return w.startSession(c, a.Username)
+}
+
+// recordAdminAction adds a journal entry attributed to the admin account.
+func (w *Web) recordAdminAction(c *echo.Context, account string, e db.Event) error {
+	result := w.DB.Save(&db.Journal{
+		CreatedAt: w.clock().Now(),
+		Visitor:   VisitorFromContext(c),
+		Admin:     account,
+		Event:     e,
+	})
+	if result.Error != nil {
+		return fmt.Errorf("failed to add a journal entry: %s", result.Error)
+	}
+	return nil
+}
+
+// randomToken generates a random URL-safe secret token.
+func randomToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
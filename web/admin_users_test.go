@@ -0,0 +1,206 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v5"
+	"github.com/nevkontakte/pat/chrono/chronotest"
+	"github.com/nevkontakte/pat/db"
+	"github.com/nevkontakte/pat/static"
+	"github.com/nevkontakte/pat/web/cookie"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// newTestServer returns a fully routed test server.
+func newTestServer(t *testing.T) (*Web, *echo.Echo) {
+	t.Helper()
+	w := newTestWeb(t)
+	w.StaticFS = static.StaticFS
+	e := newTestEcho(t)
+	w.Bind(e)
+	return w, e
+}
+
+func sessionCookie(t *testing.T, w *Web, account string) *http.Cookie {
+	t.Helper()
+	value, err := cookie.SaveCookie(AdminCookie{IsAdmin: true, Account: account}, w.Secret)
+	if err != nil {
+		t.Fatalf("cookie.SaveCookie: %v", err)
+	}
+	return &http.Cookie{Name: adminCookieName, Value: value}
+}
+
+// addTestAdmin creates an active admin account with the password "testpass".
+func addTestAdmin(t *testing.T, w *Web, username string, role db.Role) {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte("testpass"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("bcrypt.GenerateFromPassword: %v", err)
+	}
+	if err := db.InviteAdmin(w.DB, username, role, db.DefaultAdmin, "token-"+username); err != nil {
+		t.Fatalf("db.InviteAdmin: %v", err)
+	}
+	if _, err := db.AcceptInvite(w.DB, "token-"+username, hash); err != nil {
+		t.Fatalf("db.AcceptInvite: %v", err)
+	}
+}
+
+func serve(e *echo.Echo, method, target string, form url.Values, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	var req *http.Request
+	if form != nil {
+		req = httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		req = httptest.NewRequest(method, target, nil)
+	}
+	for _, ck := range cookies {
+		req.AddCookie(ck)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRequireRole_Accounts(t *testing.T) {
+	w, e := newTestServer(t)
+	addTestAdmin(t, w, "viewer", db.RoleViewer)
+	addTestAdmin(t, w, "owner", db.RoleOwner)
+	if err := db.InviteAdmin(w.DB, "pending", db.RoleOwner, db.DefaultAdmin, "pending-token"); err != nil {
+		t.Fatalf("db.InviteAdmin: %v", err)
+	}
+
+	tests := []struct {
+		account  string
+		target   string
+		wantCode int
+	}{
+		{account: "viewer", target: "/admin/", wantCode: http.StatusOK},
+		{account: "viewer", target: "/admin/admins", wantCode: http.StatusForbidden},
+		{account: "owner", target: "/admin/admins", wantCode: http.StatusOK},
+		{account: db.DefaultAdmin, target: "/admin/admins", wantCode: http.StatusOK},
+		{account: "pending", target: "/admin/", wantCode: http.StatusFound},
+		{account: "removed", target: "/admin/", wantCode: http.StatusFound},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.account+" "+tc.target, func(t *testing.T) {
+			rec := serve(e, http.MethodGet, tc.target, nil, sessionCookie(t, w, tc.account))
+			if rec.Code != tc.wantCode {
+				t.Errorf("status = %d, want %d", rec.Code, tc.wantCode)
+			}
+		})
+	}
+}
+
+func TestAdminLoginPost_Account(t *testing.T) {
+	w, e := newTestServer(t)
+	addTestAdmin(t, w, "alice", db.RoleModerator)
+
+	tests := []struct {
+		name        string
+		form        url.Values
+		wantAccount string
+	}{
+		{name: "bootstrap owner by default", form: url.Values{"password": {"testpass"}}, wantAccount: db.DefaultAdmin},
+		{name: "invited account", form: url.Values{"username": {"alice"}, "password": {"testpass"}}, wantAccount: "alice"},
+		{name: "unknown account", form: url.Values{"username": {"mallory"}, "password": {"testpass"}}},
+		{name: "wrong password", form: url.Values{"username": {"alice"}, "password": {"wrong"}}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := serve(e, http.MethodPost, "/admin/login", tc.form)
+			ck := findCookie(rec, adminCookieName)
+			if tc.wantAccount == "" {
+				if ck != nil {
+					t.Error("admin_session cookie should not be set after failed login")
+				}
+				return
+			}
+			if ck == nil {
+				t.Fatal("admin_session cookie should be set after successful login")
+			}
+			ac, err := cookie.ParseCookie[AdminCookie](ck.Value, w.Secret)
+			if err != nil {
+				t.Fatalf("cookie.ParseCookie failed: %v", err)
+			}
+			if ac.Account != tc.wantAccount {
+				t.Errorf("session account = %q, want %q", ac.Account, tc.wantAccount)
+			}
+		})
+	}
+}
+
+func TestAdminInviteFlow(t *testing.T) {
+	w, e := newTestServer(t)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	w.Clock = chronotest.NewClock(now)
+	owner := sessionCookie(t, w, db.DefaultAdmin)
+
+	rec := serve(e, http.MethodPost, "/admin/admins", url.Values{"username": {"bob"}, "role": {"viewer"}}, owner)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("invite status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	m := regexp.MustCompile(`/admin/invite/([A-Za-z0-9_-]+)`).FindStringSubmatch(rec.Body.String())
+	if m == nil {
+		t.Fatalf("invite response should contain the invite link, got:\n%s", rec.Body.String())
+	}
+	link := m[0]
+
+	rec = serve(e, http.MethodPost, link, url.Values{"password": {"short"}})
+	if findCookie(rec, adminCookieName) != nil {
+		t.Error("invite should not be accepted with a too short password")
+	}
+
+	rec = serve(e, http.MethodPost, link, url.Values{"password": {"bobspassword"}})
+	if rec.Code != http.StatusFound || findCookie(rec, adminCookieName) == nil {
+		t.Fatalf("accepting invite should start a session, got status %d", rec.Code)
+	}
+	rec = serve(e, http.MethodPost, link, url.Values{"password": {"bobspassword"}})
+	if findCookie(rec, adminCookieName) != nil {
+		t.Error("invite should not be accepted twice")
+	}
+
+	rec = serve(e, http.MethodPost, "/admin/login", url.Values{"username": {"bob"}, "password": {"bobspassword"}})
+	if findCookie(rec, adminCookieName) == nil {
+		t.Error("invited admin should be able to log in")
+	}
+
+	rec = serve(e, http.MethodPost, "/admin/admins/bob/remove", nil, owner)
+	if rec.Code != http.StatusFound {
+		t.Errorf("remove status = %d, want %d", rec.Code, http.StatusFound)
+	}
+	rec = serve(e, http.MethodGet, "/admin/", nil, sessionCookie(t, w, "bob"))
+	if rec.Code != http.StatusFound {
+		t.Errorf("removed admin's session status = %d, want %d", rec.Code, http.StatusFound)
+	}
+
+	var journal []db.Journal
+	w.DB.Where("admin <> ''").Order("id").Find(&journal)
+	want := []struct {
+		admin string
+		event db.EventType
+	}{
+		{db.DefaultAdmin, db.EventAdminInvited},
+		{"bob", db.EventAdminJoined},
+		{db.DefaultAdmin, db.EventAdminRemoved},
+	}
+	if len(journal) != len(want) {
+		t.Fatalf("got %d admin journal entries, want %d: %+v", len(journal), len(want), journal)
+	}
+	for i, j := range journal {
+		if j.Admin != want[i].admin || j.Event.Type != want[i].event {
+			t.Errorf("journal[%d] = {Admin: %q, Type: %v}, want {Admin: %q, Type: %v}",
+				i, j.Admin, j.Event.Type, want[i].admin, want[i].event)
+		}
+		if !j.CreatedAt.Equal(now) {
+			t.Errorf("journal[%d] recorded at %v, want %v", i, j.CreatedAt, now)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v5"
+	"github.com/nevkontakte/pat/db"
+	"gorm.io/gorm"
+)
+
+// adminDeliveriesPageSize is the number of deliveries per delivery log page.
+const adminDeliveriesPageSize = 50
+
+type webhooksData struct {
+	Error     error
+	Admin     AdminIdentity
+	Webhooks  []db.Webhook
+	Cats      []db.Cat
+	Events    []db.EventType // Event types webhooks may subscribe to.
+	Moods     []db.Mood
+	NewSecret string // Secret of a freshly created webhook, shown only once.
+}
+
+func (w *Web) renderWebhooks(c *echo.Context, data *webhooksData) error {
+	data.Admin = adminFromContext(c)
+	webhooks, err := db.Webhooks(w.DB)
+	if err != nil {
+		return fmt.Errorf("failed to load webhooks: %w", err)
+	}
+	cats, err := db.Cats(w.DB)
+	if err != nil {
+		return fmt.Errorf("failed to load cats: %w", err)
+	}
+	data.Webhooks = webhooks
+	data.Cats = cats
+	data.Events = db.WebhookEvents
+	data.Moods = db.AllMoods
+	return c.Render(http.StatusOK, "admin_webhooks.html", data)
+}
+
+// adminWebhooks lists webhook subscriptions.
+func (w *Web) adminWebhooks(c *echo.Context) error {
+	return w.renderWebhooks(c, &webhooksData{})
+}
+
+// adminWebhookPost creates a webhook subscription with a random secret.
+func (w *Web) adminWebhookPost(c *echo.Context) error {
+	form, err := c.FormValues()
+	if err != nil {
+		return echo.ErrBadRequest
+	}
+	account := adminFromContext(c).Account
+	hook := db.Webhook{
+		Admin:    account,
+		URL:      strings.TrimSpace(c.FormValue("url")),
+		CatID:    db.CatID(c.FormValue("cat")),
+		FromMood: db.Mood(c.FormValue("from_mood")),
+		ToMood:   db.Mood(c.FormValue("to_mood")),
+	}
+	for _, name := range form["event"] {
+		t, ok := db.ParseEventType(name)
+		if !ok {
+			return w.renderWebhooks(c, &webhooksData{Error: fmt.Errorf("Unknown event %q.", name)})
+		}
+		hook.Events = append(hook.Events, t)
+	}
+	if hook.CatID != "" {
+		if _, err := db.CatByID(w.DB, hook.CatID); errors.Is(err, gorm.ErrRecordNotFound) {
+			return w.renderWebhooks(c, &webhooksData{Error: fmt.Errorf("Unknown cat %q.", hook.CatID)})
+		} else if err != nil {
+			return fmt.Errorf("failed to load cat: %w", err)
+		}
+	}
+	if err := hook.Validate(); err != nil {
+		return w.renderWebhooks(c, &webhooksData{Error: fmt.Errorf("Invalid webhook: %s.", err)})
+	}
+
+	hook.Secret, err = randomToken()
+	if err != nil {
+		return err
+	}
+	if err := db.CreateWebhook(w.DB, &hook); err != nil {
+		return fmt.Errorf("failed to create webhook: %w", err)
+	}
+	if err := w.recordAdminAction(c, account, db.Event{
+		Type:        db.EventWebhookCreated,
+		Description: fmt.Sprintf("Created webhook #%d for %s.", hook.ID, hook.URL),
+	}); err != nil {
+		return err
+	}
+	return w.renderWebhooks(c, &webhooksData{NewSecret: hook.Secret})
+}
+
+// adminWebhookDeletePost removes a webhook subscription and its deliveries.
+func (w *Web) adminWebhookDeletePost(c *echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.ErrNotFound
+	}
+	err = db.DeleteWebhook(w.DB, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return echo.ErrNotFound
+	} else if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	if err := w.recordAdminAction(c, adminFromContext(c).Account, db.Event{
+		Type:        db.EventWebhookDeleted,
+		Description: fmt.Sprintf("Deleted webhook #%d.", id),
+	}); err != nil {
+		return err
+	}
+	return c.Redirect(http.StatusFound, "/admin/webhooks")
+}
+
+type deliveriesData struct {
+	Admin      AdminIdentity
+	Webhook    uint64 // Webhook the log is limited to, zero for all webhooks.
+	Deliveries []db.Delivery
+	Older      uint64 // ID to continue from for older deliveries, zero if there are none.
+}
+
+// adminDeliveries shows the webhook delivery log, newest first. The "webhook"
+// query parameter limits it to a single subscription.
+func (w *Web) adminDeliveries(c *echo.Context) error {
+	data := &deliveriesData{Admin: adminFromContext(c)}
+	var before uint64
+	for param, dest := range map[string]*uint64{"before": &before, "webhook": &data.Webhook} {
+		if s := c.QueryParam(param); s != "" {
+			v, err := strconv.ParseUint(s, 10, 64)
+			if err != nil {
+				return echo.ErrBadRequest
+			}
+			*dest = v
+		}
+	}
+	deliveries, err := db.Deliveries(w.DB, data.Webhook, before, adminDeliveriesPageSize)
+	if err != nil {
+		return fmt.Errorf("failed to load deliveries: %w", err)
+	}
+	data.Deliveries = deliveries
+	if len(deliveries) == adminDeliveriesPageSize {
+		data.Older = deliveries[len(deliveries)-1].ID
+	}
+	return c.Render(http.StatusOK, "admin_deliveries.html", data)
+}
@@ -0,0 +1,80 @@
+package web
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nevkontakte/pat/chrono/chronotest"
+	"github.com/nevkontakte/pat/db"
+	"github.com/nevkontakte/pat/webhook"
+)
+
+func TestAdminWebhooks(t *testing.T) {
+	w, e := newTestServer(t)
+	clock := chronotest.NewClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	w.Clock = clock
+	owner := sessionCookie(t, w, db.DefaultAdmin)
+	addTestAdmin(t, w, "viewer", db.RoleViewer)
+
+	var secret string
+	received := make(chan bool, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		received <- webhook.Verify(secret, body, req.Header.Get(webhook.HeaderSignature))
+	}))
+	defer receiver.Close()
+
+	form := url.Values{"url": {receiver.URL}, "cat": {"splotch"}, "event": {"pat"}}
+	if rec := serve(e, http.MethodPost, "/admin/webhooks", form, sessionCookie(t, w, "viewer")); rec.Code != http.StatusForbidden {
+		t.Errorf("viewer creating a webhook: status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+	invalid := url.Values{"url": {"ftp://example.com/"}}
+	if rec := serve(e, http.MethodPost, "/admin/webhooks", invalid, owner); !strings.Contains(rec.Body.String(), "Invalid webhook") {
+		t.Errorf("creating a webhook with an invalid URL should fail, got:\n%s", rec.Body.String())
+	}
+
+	rec := serve(e, http.MethodPost, "/admin/webhooks", form, owner)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("create status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	match := regexp.MustCompile(`class="secret"><code>([A-Za-z0-9_-]+)</code>`).FindStringSubmatch(rec.Body.String())
+	if match == nil {
+		t.Fatalf("create response should contain the secret, got:\n%s", rec.Body.String())
+	}
+	secret = match[1]
+
+	serve(e, http.MethodPost, "/pat/", nil)
+	worker := &webhook.Worker{DB: w.DB, Clock: clock}
+	if n, err := worker.Step(context.Background()); n != 1 || err != nil {
+		t.Fatalf("worker.Step() = %d, %v; want 1 delivery", n, err)
+	}
+	if valid := <-received; !valid {
+		t.Errorf("the receiver got an invalid signature")
+	}
+
+	rec = serve(e, http.MethodGet, "/admin/webhooks/deliveries", nil, owner)
+	if body := rec.Body.String(); !strings.Contains(body, receiver.URL) || !strings.Contains(body, "delivered") {
+		t.Errorf("delivery log should show the delivery, got:\n%s", body)
+	}
+
+	hooks, err := db.Webhooks(w.DB)
+	if err != nil || len(hooks) != 1 {
+		t.Fatalf("db.Webhooks() = %+v, %v; want a single webhook", hooks, err)
+	}
+	if rec := serve(e, http.MethodPost, "/admin/webhooks/99/delete", nil, owner); rec.Code != http.StatusNotFound {
+		t.Errorf("deleting a missing webhook: status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	if rec := serve(e, http.MethodPost, "/admin/webhooks/1/delete", nil, owner); rec.Code != http.StatusFound {
+		t.Errorf("delete status = %d, want %d", rec.Code, http.StatusFound)
+	}
+	if hooks, _ := db.Webhooks(w.DB); len(hooks) != 0 {
+		t.Errorf("db.Webhooks() = %+v after deletion, want none", hooks)
+	}
+}
@@ -0,0 +1,261 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v5"
+	"github.com/nevkontakte/pat/db"
+	"gorm.io/gorm"
+)
+
+const (
+	// journalPageSize is the default number of journal records per request.
+	journalPageSize = 100
+	// journalMaxPageSize limits the number of journal records per request.
+	journalMaxPageSize = 1000
+)
+
+// apiJournal is the JSON representation of a journal record.
+type apiJournal struct {
+	ID          uint64    `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	Cat         db.CatID  `json:"cat,omitempty"`
+	Type        string    `json:"type"`
+	Description string    `json:"description,omitempty"`
+	Admin       string    `json:"admin,omitempty"`
+	Addr        string    `json:"addr,omitempty"`
+	Agent       string    `json:"agent,omitempty"`
+	Referrer    string    `json:"referrer,omitempty"`
+	Identity    uint64    `json:"identity,omitempty"`
+}
+
+func newAPIJournal(j db.Journal) apiJournal {
+	r := apiJournal{
+		ID:          j.ID,
+		CreatedAt:   j.CreatedAt,
+		Cat:         j.CatID,
+		Type:        j.Event.Type.String(),
+		Description: j.Event.Description,
+		Admin:       j.Admin,
+	}
+	if v := j.Visitor; v != nil {
+		if addr := v.Addr.Unwrap(); addr.IsValid() {
+			r.Addr = addr.String()
+		}
+		r.Agent = v.Agent
+		r.Referrer = v.Referrer
+		r.Identity = v.IdentityID
+	}
+	return r
+}
+
+// apiCat is the JSON representation of a cat.
+type apiCat struct {
+	ID        db.CatID     `json:"id"`
+	Name      string       `json:"name"`
+	Pats      uint64       `json:"pats"`
+	LatestPat time.Time    `json:"latest_pat"`
+	Noise     db.NoiseKind `json:"noise"`
+	Model     db.MoodModel `json:"model"`
+
+	Personality apiPersonality `json:"personality"`
+}
+
+// apiPersonality is the JSON representation of a cat's personality.
+type apiPersonality struct {
+	Clinginess float64 `json:"clinginess"`
+	Patience   float64 `json:"patience"`
+	Moodiness  float64 `json:"moodiness"`
+	Sleepiness float64 `json:"sleepiness"`
+}
+
+func newAPICat(c db.Cat) apiCat {
+	noise := c.Noise
+	if noise == "" {
+		noise = db.NoiseMd5
+	}
+	model := c.Model
+	if model == "" {
+		model = db.MoodModelClassic
+	}
+	return apiCat{
+		ID: c.ID, Name: c.Name, Pats: c.Pats, LatestPat: c.LatestPat, Noise: noise, Model: model,
+		Personality: apiPersonality(c.Personality),
+	}
+}
+
+// apiJournalList exports journal records in the order they were recorded.
+//
+// Query parameters: "cat" limits records to a single cat, "after" skips records
+// up to and including the given ID, "limit" sets the page size. To export the
+// whole journal, repeat the request with "after" set to the last returned ID
+// until the response is empty.
+func (w *Web) apiJournalList(c *echo.Context) error {
+	var after uint64
+	if s := c.QueryParam("after"); s != "" {
+		v, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid after parameter")
+		}
+		after = v
+	}
+	limit := journalPageSize
+	if s := c.QueryParam("limit"); s != "" {
+		v, err := strconv.Atoi(s)
+		if err != nil || v < 1 || v > journalMaxPageSize {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", journalMaxPageSize))
+		}
+		limit = v
+	}
+
+	records, err := db.JournalAfter(w.DB, db.CatID(c.QueryParam("cat")), after, limit)
+	if err != nil {
+		return fmt.Errorf("failed to load journal: %w", err)
+	}
+	result := make([]apiJournal, len(records))
+	for i, j := range records {
+		result[i] = newAPIJournal(j)
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+// apiJournalDelete removes a journal record.
+func (w *Web) apiJournalDelete(c *echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.ErrNotFound
+	}
+	err = db.DeleteJournal(w.DB, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return echo.ErrNotFound
+	} else if err != nil {
+		return fmt.Errorf("failed to remove journal record: %w", err)
+	}
+	if err := w.recordAdminAction(c, adminFromContext(c).Account, db.Event{
+		Type:        db.EventJournalRemoved,
+		Description: fmt.Sprintf("Removed journal record #%d.", id),
+	}); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// apiCatList lists all cats.
+func (w *Web) apiCatList(c *echo.Context) error {
+	cats, err := db.Cats(w.DB)
+	if err != nil {
+		return fmt.Errorf("failed to load cats: %w", err)
+	}
+	result := make([]apiCat, len(cats))
+	for i, cat := range cats {
+		result[i] = newAPICat(cat)
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+// apiCatRequest is the JSON body of cat create and update requests.
+type apiCatRequest struct {
+	ID    db.CatID     `json:"id"`
+	Name  string       `json:"name"`
+	Noise db.NoiseKind `json:"noise"` // Only used when creating a cat.
+	Model db.MoodModel `json:"model"`
+
+	Personality *apiPersonality `json:"personality"`
+}
+
+// apiCatCreate adds a new cat.
+func (w *Web) apiCatCreate(c *echo.Context) error {
+	var req apiCatRequest
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+	if _, err := db.CatByID(w.DB, req.ID); err == nil {
+		return echo.NewHTTPError(http.StatusConflict, fmt.Sprintf("cat %q already exists", req.ID))
+	}
+	cat := db.Cat{ID: req.ID, Name: req.Name, Noise: req.Noise, Model: req.Model}
+	if req.Personality != nil {
+		cat.Personality = db.Personality(*req.Personality)
+	}
+	if err := db.CreateCat(w.DB, cat); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	cat, err := db.CatByID(w.DB, req.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load cat: %w", err)
+	}
+	if err := w.recordAdminAction(c, adminFromContext(c).Account, db.Event{
+		Type:        db.EventCatCreated,
+		Description: fmt.Sprintf("Added %s (%s).", cat.Name, cat.ID),
+	}); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusCreated, newAPICat(cat))
+}
+
+// apiCatUpdate changes cat details. Fields missing from the request are left
+// unchanged.
+func (w *Web) apiCatUpdate(c *echo.Context) error {
+	var req apiCatRequest
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+	id := db.CatID(c.Param("id"))
+	if req.Name == "" && req.Model == "" && req.Personality == nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "name, model or personality is required")
+	}
+	if !req.Model.Valid() {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("unknown mood model %q", req.Model))
+	}
+	if req.Personality != nil {
+		if err := db.Personality(*req.Personality).Validate(); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+	}
+
+	var changes []string
+	if req.Name != "" {
+		err := db.RenameCat(w.DB, id, req.Name)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.ErrNotFound
+		} else if err != nil {
+			return fmt.Errorf("failed to update cat: %w", err)
+		}
+		changes = append(changes, fmt.Sprintf("renamed to %s", req.Name))
+	}
+	if req.Model != "" {
+		err := db.SetMoodModel(w.DB, id, req.Model)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.ErrNotFound
+		} else if err != nil {
+			return fmt.Errorf("failed to update cat: %w", err)
+		}
+		changes = append(changes, fmt.Sprintf("switched to the %s mood model", req.Model))
+	}
+	if req.Personality != nil {
+		p := db.Personality(*req.Personality)
+		err := db.SetPersonality(w.DB, id, p)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.ErrNotFound
+		} else if err != nil {
+			return fmt.Errorf("failed to update cat: %w", err)
+		}
+		changes = append(changes, fmt.Sprintf("changed personality to %s", p))
+	}
+
+	cat, err := db.CatByID(w.DB, id)
+	if err != nil {
+		return fmt.Errorf("failed to load cat: %w", err)
+	}
+	if err := w.recordAdminAction(c, adminFromContext(c).Account, db.Event{
+		Type:        db.EventCatUpdated,
+		Description: fmt.Sprintf("Updated %s: %s.", cat.ID, strings.Join(changes, ", ")),
+	}); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, newAPICat(cat))
+}
@@ -0,0 +1,198 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/labstack/echo/v5"
+	"github.com/nevkontakte/pat/db"
+)
+
+// serveToken performs a request authenticated with the API token and an
+// optional JSON body.
+func serveToken(e *echo.Echo, method, target, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	}
+	if token != "" {
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+// addTestToken creates an API token for the account.
+func addTestToken(t *testing.T, w *Web, account, token string, scopes ...db.Scope) {
+	t.Helper()
+	if _, err := db.CreateAPIToken(w.DB, account, token, scopes, token); err != nil {
+		t.Fatalf("db.CreateAPIToken: %v", err)
+	}
+}
+
+func TestRequireToken(t *testing.T) {
+	w, e := newTestServer(t)
+	addTestAdmin(t, w, "viewer", db.RoleViewer)
+	addTestAdmin(t, w, "moderator", db.RoleModerator)
+	addTestToken(t, w, "viewer", "viewer-read", db.ScopeReadJournal)
+	addTestToken(t, w, "moderator", "moderator-read", db.ScopeReadJournal)
+	addTestToken(t, w, "moderator", "moderator-moderate", db.ScopeModerate)
+	addTestToken(t, w, db.DefaultAdmin, "owner-cats", db.ScopeManageCats)
+	// The account was demoted after the token was created.
+	addTestToken(t, w, "viewer", "viewer-cats", db.ScopeManageCats)
+
+	tests := []struct {
+		method   string
+		target   string
+		token    string
+		wantCode int
+	}{
+		{http.MethodGet, "/api/journal", "", http.StatusUnauthorized},
+		{http.MethodGet, "/api/journal", "wrong", http.StatusUnauthorized},
+		{http.MethodGet, "/api/journal", "viewer-read", http.StatusOK},
+		{http.MethodGet, "/api/journal", "moderator-moderate", http.StatusForbidden},
+		{http.MethodGet, "/api/cats", "owner-cats", http.StatusOK},
+		{http.MethodGet, "/api/cats", "viewer-read", http.StatusOK},
+		{http.MethodDelete, "/api/journal/1", "moderator-read", http.StatusForbidden},
+		{http.MethodDelete, "/api/journal/1", "moderator-moderate", http.StatusNoContent},
+		{http.MethodPatch, "/api/cats/splotch", "viewer-cats", http.StatusForbidden},
+	}
+
+	// Give the moderator something to remove.
+	serve(e, http.MethodGet, "/", nil)
+
+	for _, tc := range tests {
+		rec := serveToken(e, tc.method, tc.target, tc.token, `{"name":"Spot"}`)
+		if rec.Code != tc.wantCode {
+			t.Errorf("%s %s with token %q: status = %d, want %d", tc.method, tc.target, tc.token, rec.Code, tc.wantCode)
+		}
+	}
+
+	// API routes don't accept session cookies.
+	rec := serve(e, http.MethodGet, "/api/journal", nil, sessionCookie(t, w, db.DefaultAdmin))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("GET /api/journal with session cookie: status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestAPIJournalList(t *testing.T) {
+	w, e := newTestServer(t)
+	addTestToken(t, w, db.DefaultAdmin, "token", db.ScopeReadJournal)
+	for range 3 {
+		serve(e, http.MethodGet, "/", nil)
+	}
+
+	list := func(target string) []uint64 {
+		t.Helper()
+		rec := serveToken(e, http.MethodGet, target, "token", "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s: status = %d, want %d", target, rec.Code, http.StatusOK)
+		}
+		var records []apiJournal
+		if err := json.Unmarshal(rec.Body.Bytes(), &records); err != nil {
+			t.Fatalf("GET %s: failed to parse response: %v", target, err)
+		}
+		var ids []uint64
+		for _, r := range records {
+			if r.Type != "visit" || r.Cat != db.SplotchID {
+				t.Errorf("GET %s: got record %+v, want a visit to Splotch", target, r)
+			}
+			ids = append(ids, r.ID)
+		}
+		return ids
+	}
+
+	if diff := cmp.Diff([]uint64{1, 2}, list("/api/journal?limit=2")); diff != "" {
+		t.Errorf("first page diff (-want,+got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]uint64{3}, list("/api/journal?limit=2&after=2")); diff != "" {
+		t.Errorf("second page diff (-want,+got):\n%s", diff)
+	}
+	if got := list("/api/journal?cat=stray"); len(got) != 0 {
+		t.Errorf("journal for a missing cat = %v, want empty", got)
+	}
+
+	rec := serveToken(e, http.MethodGet, "/api/journal?limit=100000", "token", "")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("GET with too large limit: status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestAPICats(t *testing.T) {
+	w, e := newTestServer(t)
+	addTestToken(t, w, db.DefaultAdmin, "token", db.ScopeManageCats)
+
+	rec := serveToken(e, http.MethodPost, "/api/cats", "token", `{"id":"red","noise":"hash"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+	if cat, err := db.CatByID(w.DB, "red"); err != nil || cat.Noise != db.NoiseHash {
+		t.Errorf("db.CatByID() = %+v, %v; want a cat with %q noise", cat, err, db.NoiseHash)
+	}
+	rec = serveToken(e, http.MethodPost, "/api/cats", "token", `{"id":"red"}`)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("duplicate create status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+	rec = serveToken(e, http.MethodPost, "/api/cats", "token", `{"id":"Not Valid"}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid create status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	rec = serveToken(e, http.MethodPatch, "/api/cats/red", "token", `{"name":"Loaf"}`)
+	if rec.Code != http.StatusOK {
+		t.Errorf("update status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	rec = serveToken(e, http.MethodPatch, "/api/cats/red", "token", `{"model":"markov"}`)
+	if rec.Code != http.StatusOK {
+		t.Errorf("model update status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if cat, err := db.CatByID(w.DB, "red"); err != nil || cat.Model != db.MoodModelMarkov || cat.Name != "Loaf" {
+		t.Errorf("db.CatByID() = %+v, %v; want Loaf with the %q mood model", cat, err, db.MoodModelMarkov)
+	}
+	rec = serveToken(e, http.MethodPatch, "/api/cats/red", "token", `{"personality":{"clinginess":0.5,"patience":-1}}`)
+	if rec.Code != http.StatusOK {
+		t.Errorf("personality update status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if cat, err := db.CatByID(w.DB, "red"); err != nil || cat.Personality != (db.Personality{Clinginess: 0.5, Patience: -1}) {
+		t.Errorf("db.CatByID() = %+v, %v; want the updated personality", cat, err)
+	}
+	rec = serveToken(e, http.MethodPatch, "/api/cats/red", "token", `{"personality":{"moodiness":2}}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid personality update status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	rec = serveToken(e, http.MethodPatch, "/api/cats/red", "token", `{"model":"neural"}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown model update status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	rec = serveToken(e, http.MethodPatch, "/api/cats/stray", "token", `{"name":"Stray"}`)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("update of a missing cat status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	rec = serveToken(e, http.MethodGet, "/api/cats", "token", "")
+	var cats []apiCat
+	if err := json.Unmarshal(rec.Body.Bytes(), &cats); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	var names []string
+	for _, c := range cats {
+		names = append(names, c.Name)
+	}
+	if diff := cmp.Diff([]string{"Loaf", "Splotch"}, names); diff != "" {
+		t.Errorf("cat names diff (-want,+got):\n%s", diff)
+	}
+
+	var journal []db.Journal
+	w.DB.Where("admin = ?", db.DefaultAdmin).Order("id").Find(&journal)
+	var events []db.EventType
+	for _, j := range journal {
+		events = append(events, j.Event.Type)
+	}
+	if diff := cmp.Diff([]db.EventType{db.EventCatCreated, db.EventCatUpdated, db.EventCatUpdated, db.EventCatUpdated}, events); diff != "" {
+		t.Errorf("admin journal events diff (-want,+got):\n%s", diff)
+	}
+}
@@ -0,0 +1,137 @@
+// Package feed renders syndication feeds in Atom (RFC 4287) and RSS 2.0
+// formats from a single format-independent description.
+package feed
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// Feed is a format-independent syndication feed.
+type Feed struct {
+	ID          string // Stable, unique IRI identifying the feed.
+	Title       string
+	Description string
+	Link        string    // URL of the web page the feed corresponds to.
+	Self        string    // URL of the feed itself.
+	Updated     time.Time // When the feed last changed.
+	Entries     []Entry   // Newest first.
+}
+
+// Entry is a single feed item.
+type Entry struct {
+	ID      string // Stable, unique IRI identifying the entry.
+	Title   string
+	Link    string
+	Updated time.Time
+}
+
+const (
+	AtomType = "application/atom+xml" // MIME type of Atom feeds.
+	RSSType  = "application/rss+xml"  // MIME type of RSS feeds.
+)
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	ID      string   `xml:"id"`
+	Title   string   `xml:"title"`
+	Link    atomLink `xml:"link"`
+	Updated string   `xml:"updated"`
+}
+
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID       string      `xml:"id"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Author   string      `xml:"author>name"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+// Atom renders the feed in Atom format.
+func (f Feed) Atom() ([]byte, error) {
+	a := atomFeed{
+		ID:       f.ID,
+		Title:    f.Title,
+		Subtitle: f.Description,
+		Updated:  f.Updated.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Rel: "alternate", Type: "text/html", Href: f.Link},
+			{Rel: "self", Type: AtomType, Href: f.Self},
+		},
+		// Atom requires an author for entries, the cat writes its own.
+		Author: f.Title,
+	}
+	for _, e := range f.Entries {
+		a.Entries = append(a.Entries, atomEntry{
+			ID:      e.ID,
+			Title:   e.Title,
+			Link:    atomLink{Rel: "alternate", Type: "text/html", Href: e.Link},
+			Updated: e.Updated.UTC().Format(time.RFC3339),
+		})
+	}
+	return marshal(a)
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssItem struct {
+	Title   string  `xml:"title"`
+	Link    string  `xml:"link"`
+	GUID    rssGUID `xml:"guid"`
+	PubDate string  `xml:"pubDate"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+// RSS renders the feed in RSS 2.0 format.
+func (f Feed) RSS() ([]byte, error) {
+	r := rss{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:         f.Title,
+			Link:          f.Link,
+			Description:   f.Description,
+			LastBuildDate: f.Updated.UTC().Format(time.RFC1123Z),
+		},
+	}
+	for _, e := range f.Entries {
+		r.Channel.Items = append(r.Channel.Items, rssItem{
+			Title:   e.Title,
+			Link:    e.Link,
+			GUID:    rssGUID{Value: e.ID},
+			PubDate: e.Updated.UTC().Format(time.RFC1123Z),
+		})
+	}
+	return marshal(r)
+}
+
+func marshal(v any) ([]byte, error) {
+	body, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
@@ -0,0 +1,73 @@
+package feed
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testFeed() Feed {
+	at := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	return Feed{
+		ID:          "https://example.com/cats/splotch/activity",
+		Title:       "Splotch",
+		Description: "What Splotch has been up to.",
+		Link:        "https://example.com/cats/splotch/activity",
+		Self:        "https://example.com/cats/splotch/feed.atom",
+		Updated:     at,
+		Entries: []Entry{{
+			ID:      "https://example.com/cats/splotch/activity#journal-1",
+			Title:   "Splotch chased a fly & <won>.",
+			Link:    "https://example.com/cats/splotch/activity",
+			Updated: at,
+		}},
+	}
+}
+
+func TestAtom(t *testing.T) {
+	body, err := testFeed().Atom()
+	if err != nil {
+		t.Fatalf("Atom() returned error: %v", err)
+	}
+	var got atomFeed
+	if err := xml.Unmarshal(body, &got); err != nil {
+		t.Fatalf("Atom() produced invalid XML: %v\n%s", err, body)
+	}
+	if got.Updated != "2024-01-01T12:00:00Z" {
+		t.Errorf("Got: feed updated %q. Want: %q.", got.Updated, "2024-01-01T12:00:00Z")
+	}
+	if len(got.Entries) != 1 {
+		t.Fatalf("Got: %d entries. Want: 1.", len(got.Entries))
+	}
+	if e := got.Entries[0]; e.ID != testFeed().Entries[0].ID || e.Title != testFeed().Entries[0].Title {
+		t.Errorf("Got: entry %+v. Want: ID and title of %+v.", e, testFeed().Entries[0])
+	}
+	if !strings.Contains(string(body), `rel="self"`) {
+		t.Errorf("Atom feed should link to itself:\n%s", body)
+	}
+}
+
+func TestRSS(t *testing.T) {
+	body, err := testFeed().RSS()
+	if err != nil {
+		t.Fatalf("RSS() returned error: %v", err)
+	}
+	var got rss
+	if err := xml.Unmarshal(body, &got); err != nil {
+		t.Fatalf("RSS() produced invalid XML: %v\n%s", err, body)
+	}
+	if got.Version != "2.0" {
+		t.Errorf("Got: version %q. Want: %q.", got.Version, "2.0")
+	}
+	if len(got.Channel.Items) != 1 {
+		t.Fatalf("Got: %d items. Want: 1.", len(got.Channel.Items))
+	}
+	item := got.Channel.Items[0]
+	if item.GUID.Value != testFeed().Entries[0].ID || item.GUID.IsPermaLink {
+		t.Errorf("Got: GUID %+v. Want: non-permalink %q.", item.GUID, testFeed().Entries[0].ID)
+	}
+	if want := "Mon, 01 Jan 2024 12:00:00 +0000"; item.PubDate != want {
+		t.Errorf("Got: pubDate %q. Want: %q.", item.PubDate, want)
+	}
+}
@@ -0,0 +1,134 @@
+package web
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/labstack/echo/v5"
+	"github.com/nevkontakte/pat/db"
+	"github.com/nevkontakte/pat/web/feed"
+)
+
+const (
+	// feedEntries is the maximum number of entries in a feed.
+	feedEntries = 50
+	// feedTag is the prefix of feed and entry IDs. IDs are tag URIs (RFC 4151),
+	// so that they don't depend on the host name the feed is requested with.
+	feedTag = "tag:nevkontakte.com,2023:pat"
+)
+
+// catFeed builds the feed of notable events that happened to the cat.
+//
+// Visitor interactions aren't included, since there are too many of them,
+// except for pats aggregated per hour if pats is true.
+func (w *Web) catFeed(c *echo.Context, cat db.Cat, pats bool) (feed.Feed, error) {
+	base := fmt.Sprintf("%s://%s", w.scheme(c), c.Request().Host)
+	page := fmt.Sprintf("%s/cats/%s/activity", base, cat.ID)
+	id := fmt.Sprintf("%s/cats/%s", feedTag, cat.ID)
+	f := feed.Feed{
+		ID:          id,
+		Title:       cat.Name,
+		Description: fmt.Sprintf("What %s has been up to.", cat.Name),
+		Link:        page,
+		Self:        base + c.Request().URL.RequestURI(),
+	}
+
+	records, err := db.NotableJournal(w.DB, cat.ID, feedEntries)
+	if err != nil {
+		return feed.Feed{}, fmt.Errorf("failed to load journal: %w", err)
+	}
+	for _, r := range records {
+		title := r.Event.Description
+		if title == "" {
+			title = cat.Describe(r.Event.Type, r.CreatedAt, nil)
+		}
+		f.Entries = append(f.Entries, feed.Entry{
+			ID:      fmt.Sprintf("%s/journal/%d", id, r.ID),
+			Title:   title,
+			Link:    page,
+			Updated: r.CreatedAt,
+		})
+	}
+	if pats {
+		// Hours with pats older than all the other entries wouldn't make it
+		// into a full feed anyway.
+		var since time.Time
+		if len(records) == feedEntries {
+			since = records[len(records)-1].CreatedAt
+		}
+		hours, err := db.HourlyPats(w.DB, cat.ID, since, feedEntries)
+		if err != nil {
+			return feed.Feed{}, fmt.Errorf("failed to load pats: %w", err)
+		}
+		for _, h := range hours {
+			f.Entries = append(f.Entries, feed.Entry{
+				ID:      fmt.Sprintf("%s/pats/%d", id, h.Start.Unix()),
+				Title:   fmt.Sprintf("%s was patted %s.", cat.Name, times(h.Pats)),
+				Link:    page,
+				Updated: h.Latest,
+			})
+		}
+		slices.SortStableFunc(f.Entries, func(a, b feed.Entry) int {
+			return b.Updated.Compare(a.Updated)
+		})
+		f.Entries = f.Entries[:min(len(f.Entries), feedEntries)]
+	}
+	for _, e := range f.Entries {
+		if e.Updated.After(f.Updated) {
+			f.Updated = e.Updated
+		}
+	}
+	return f, nil
+}
+
+// times formats the number of repetitions.
+func times(n int64) string {
+	switch n {
+	case 1:
+		return "once"
+	case 2:
+		return "twice"
+	default:
+		return fmt.Sprintf("%d times", n)
+	}
+}
+
+// catFeedHandler returns the handler serving the cat's feed in the given
+// format. The "pats" query parameter set to "hourly" adds pats aggregated per
+// hour.
+//
+// Conditional requests are supported with both ETag and Last-Modified.
+func (w *Web) catFeedHandler(contentType string, render func(feed.Feed) ([]byte, error)) echo.HandlerFunc {
+	return func(c *echo.Context) error {
+		cat, err := w.catFromParam(c)
+		if err != nil {
+			return err
+		}
+		f, err := w.catFeed(c, cat, c.QueryParam("pats") == "hourly")
+		if err != nil {
+			return err
+		}
+		body, err := render(f)
+		if err != nil {
+			return fmt.Errorf("failed to render feed: %w", err)
+		}
+
+		h := c.Response().Header()
+		h.Set(echo.HeaderContentType, contentType+"; charset=utf-8")
+		h.Set("ETag", etag(body))
+		// ServeContent takes care of conditional requests.
+		http.ServeContent(c.Response(), c.Request(), "", f.Updated, bytes.NewReader(body))
+		return nil
+	}
+}
+
+// etag returns a strong entity tag for the response body.
+func etag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
@@ -0,0 +1,151 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nevkontakte/pat/chrono/chronotest"
+	"github.com/nevkontakte/pat/db"
+)
+
+func TestCatFeed(t *testing.T) {
+	w, e := newTestServer(t)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := chronotest.NewClock(start)
+	w.Clock = clock
+
+	pat, _ := db.InteractionByID("pat")
+	for range 3 {
+		clock.Advance(time.Minute)
+		if _, err := db.Interact(w.DB, clock, db.SplotchID, pat, &db.Visitor{}); err != nil {
+			t.Fatalf("db.Interact: %v", err)
+		}
+	}
+	nap := db.Journal{CatID: db.SplotchID, CreatedAt: start.Add(time.Hour), Event: db.Event{Type: db.EventNapped, Description: "Splotch took a nap."}}
+	w.DB.Create(&nap)
+
+	rec := serve(e, http.MethodGet, "/cats/splotch/feed.atom", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /cats/splotch/feed.atom: status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "application/atom+xml") {
+		t.Errorf("Got: Content-Type %q. Want: Atom.", got)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, "Splotch took a nap.") {
+		t.Errorf("feed should include the nap:\n%s", body)
+	}
+	if strings.Contains(body, "patted") {
+		t.Errorf("feed shouldn't include pats by default:\n%s", body)
+	}
+	if want := fmt.Sprintf("<id>tag:nevkontakte.com,2023:pat/cats/splotch/journal/%d</id>", nap.ID); !strings.Contains(body, want) {
+		t.Errorf("feed entries should have stable IDs like %q:\n%s", want, body)
+	}
+
+	rec = serve(e, http.MethodGet, "/cats/splotch/feed.rss?pats=hourly", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /cats/splotch/feed.rss: status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "Splotch was patted 3 times.") {
+		t.Errorf("feed should aggregate pats per hour:\n%s", body)
+	}
+
+	// Conditional requests.
+	etag := rec.Header().Get("ETag")
+	modified := rec.Header().Get("Last-Modified")
+	if etag == "" || modified != "Mon, 01 Jan 2024 13:00:00 GMT" {
+		t.Fatalf("Got: ETag %q, Last-Modified %q. Want: an ETag and the latest entry time.", etag, modified)
+	}
+	for header, value := range map[string]string{"If-None-Match": etag, "If-Modified-Since": modified} {
+		req := httptest.NewRequest(http.MethodGet, "/cats/splotch/feed.rss?pats=hourly", nil)
+		req.Header.Set(header, value)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		if rec.Code != http.StatusNotModified {
+			t.Errorf("GET with %s: status = %d, want %d", header, rec.Code, http.StatusNotModified)
+		}
+	}
+
+	if rec := serve(e, http.MethodGet, "/cats/stray/feed.atom", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("GET of a missing cat: status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	if body := serve(e, http.MethodGet, "/", nil).Body.String(); !strings.Contains(body, `href="/cats/splotch/feed.atom"`) {
+		t.Errorf("index should link to the feed for autodiscovery")
+	}
+}
+
+func TestCatFeed_HourlyPats(t *testing.T) {
+	w, e := newTestServer(t)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := chronotest.NewClock(start)
+	w.Clock = clock
+
+	// Pat, nap and pat again within the same hour.
+	pat, _ := db.InteractionByID("pat")
+	clock.Advance(time.Minute)
+	if _, err := db.Interact(w.DB, clock, db.SplotchID, pat, &db.Visitor{}); err != nil {
+		t.Fatalf("db.Interact: %v", err)
+	}
+	w.DB.Create(&db.Journal{CatID: db.SplotchID, CreatedAt: start.Add(2 * time.Minute), Event: db.Event{Type: db.EventNapped, Description: "Splotch took a nap."}})
+	clock.Advance(2 * time.Minute)
+	if _, err := db.Interact(w.DB, clock, db.SplotchID, pat, &db.Visitor{}); err != nil {
+		t.Fatalf("db.Interact: %v", err)
+	}
+
+	get := func(host string) string {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/cats/splotch/feed.atom?pats=hourly", nil)
+		req.Host = host
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Body.String()
+	}
+	body := get("example.com")
+	id := fmt.Sprintf("<id>tag:nevkontakte.com,2023:pat/cats/splotch/pats/%d</id>", start.Unix())
+	if n := strings.Count(body, id); n != 1 {
+		t.Errorf("feed should have a single entry with ID %q for the hour, got %d:\n%s", id, n, body)
+	}
+	if !strings.Contains(body, "Splotch was patted twice.") || !strings.Contains(body, "Splotch took a nap.") {
+		t.Errorf("feed should count both pats in the hour and include the nap:\n%s", body)
+	}
+
+	// IDs don't depend on the host the feed is requested with.
+	other := get("evil.example")
+	if !strings.Contains(other, "evil.example/cats/splotch/activity") {
+		t.Errorf("feed should link to the requested host:\n%s", other)
+	}
+	for _, body := range []string{body, other} {
+		if strings.Contains(body, "<id>http") {
+			t.Errorf("feed IDs shouldn't depend on the host:\n%s", body)
+		}
+	}
+}
+
+func TestCatFeed_ManyPats(t *testing.T) {
+	w, e := newTestServer(t)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	w.Clock = chronotest.NewClock(start)
+
+	// A nap, buried under more pats than the journal can load at once.
+	w.DB.Create(&db.Journal{CatID: db.SplotchID, CreatedAt: start, Event: db.Event{Type: db.EventNapped, Description: "Splotch took a nap."}})
+	var pats []db.Journal
+	for i := range 1500 {
+		pats = append(pats, db.Journal{CatID: db.SplotchID, CreatedAt: start.Add(time.Duration(i+1) * time.Second), Event: db.Event{Type: db.EventPat}})
+	}
+	if result := w.DB.CreateInBatches(pats, 100); result.Error != nil {
+		t.Fatalf("failed to add pats: %v", result.Error)
+	}
+
+	for _, target := range []string{"/cats/splotch/feed.atom", "/cats/splotch/feed.atom?pats=hourly"} {
+		if body := serve(e, http.MethodGet, target, nil).Body.String(); !strings.Contains(body, "Splotch took a nap.") {
+			t.Errorf("GET %s: feed should include the nap:\n%s", target, body)
+		}
+	}
+	if body := serve(e, http.MethodGet, "/cats/splotch/feed.atom?pats=hourly", nil).Body.String(); !strings.Contains(body, "Splotch was patted 1500 times.") {
+		t.Errorf("feed should count all pats in the hour:\n%s", body)
+	}
+}
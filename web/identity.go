@@ -0,0 +1,141 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/labstack/echo/v5"
+	"github.com/nevkontakte/pat/db"
+	"github.com/nevkontakte/pat/web/cookie"
+	"gorm.io/gorm"
+)
+
+const visitorCookieName = "visitor"
+
+// VisitorCookie remembers the identity of a visitor who introduced themselves.
+type VisitorCookie struct {
+	Identity uint64
+}
+
+// identity returns the identity the visitor introduced themselves with, if
+// any.
+func (w *Web) identity(c *echo.Context) (db.Identity, bool) {
+	if len(w.Secret) == 0 {
+		return db.Identity{}, false
+	}
+	raw, err := c.Cookie(visitorCookieName)
+	if err != nil {
+		return db.Identity{}, false
+	}
+	vc, err := cookie.ParseCookie[VisitorCookie](raw.Value, w.Secret)
+	if err != nil {
+		return db.Identity{}, false
+	}
+	identity, err := db.IdentityByID(w.DB, vc.Identity)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.Error("failed to load visitor identity", "identity", vc.Identity, "err", err)
+		}
+		return db.Identity{}, false
+	}
+	return identity, true
+}
+
+// visitor returns the current visitor, linked to their identity if they
+// introduced themselves.
+func (w *Web) visitor(c *echo.Context) *db.Visitor {
+	v := VisitorFromContext(c)
+	if identity, ok := w.identity(c); ok {
+		v.IdentityID = identity.ID
+		v.Nickname = identity.Nickname
+	}
+	return v
+}
+
+// introduce sets the visitor's nickname, creating an identity for them on the
+// first introduction.
+func (w *Web) introduce(c *echo.Context) error {
+	nickname := c.FormValue("nickname")
+	if _, err := db.NormalizeNickname(nickname); err != nil {
+		return c.Redirect(http.StatusFound, "/?nickname=invalid")
+	}
+
+	if identity, ok := w.identity(c); ok {
+		if err := db.RenameIdentity(w.DB, identity.ID, nickname); err != nil {
+			return fmt.Errorf("failed to rename visitor identity: %w", err)
+		}
+		return c.Redirect(http.StatusFound, "/")
+	}
+
+	identity, err := db.CreateIdentity(w.DB, nickname)
+	if err != nil {
+		return fmt.Errorf("failed to create visitor identity: %w", err)
+	}
+	value, err := cookie.SaveCookie(VisitorCookie{Identity: identity.ID}, w.Secret)
+	if err != nil {
+		return fmt.Errorf("failed to create visitor cookie: %w", err)
+	}
+	c.SetCookie(&http.Cookie{
+		Name:     visitorCookieName,
+		Value:    value,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   w.scheme(c) == "https",
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   365 * 24 * 60 * 60,
+	})
+	return c.Redirect(http.StatusFound, "/")
+}
+
+// leaderboardOptOut hides the visitor from the leaderboard, or shows them on
+// it again. Anonymous visitors are hidden by their address.
+func (w *Web) leaderboardOptOut(c *echo.Context) error {
+	hidden := c.FormValue("hidden") == "true"
+	if identity, ok := w.identity(c); ok {
+		if err := db.SetIdentityHidden(w.DB, identity.ID, hidden); err != nil {
+			return fmt.Errorf("failed to update visitor identity: %w", err)
+		}
+		return c.Redirect(http.StatusFound, "/")
+	}
+	addr := VisitorFromContext(c).Addr
+	if !addr.Unwrap().IsValid() {
+		return echo.ErrForbidden
+	}
+	if err := db.SetAddrHidden(w.DB, addr, hidden); err != nil {
+		return fmt.Errorf("failed to update hidden addresses: %w", err)
+	}
+	return c.Redirect(http.StatusFound, "/")
+}
+
+// hiddenFromLeaderboard returns true if the visitor opted out of the
+// leaderboard, either with their identity or, if anonymous, by their address.
+func (w *Web) hiddenFromLeaderboard(c *echo.Context) (bool, error) {
+	if identity, ok := w.identity(c); ok {
+		return identity.Hidden, nil
+	}
+	addr := VisitorFromContext(c).Addr
+	if !addr.Unwrap().IsValid() {
+		return false, nil
+	}
+	return db.AddrHidden(w.DB, addr)
+}
+
+// forget makes the visitor anonymous again. Their past journal records stay
+// linked to the identity, which is hidden from the leaderboard, since nobody
+// can opt out on its behalf any more.
+func (w *Web) forget(c *echo.Context) error {
+	if identity, ok := w.identity(c); ok {
+		if err := db.SetIdentityHidden(w.DB, identity.ID, true); err != nil {
+			return fmt.Errorf("failed to hide visitor identity: %w", err)
+		}
+	}
+	c.SetCookie(&http.Cookie{
+		Name:   visitorCookieName,
+		Value:  "",
+		Path:   "/",
+		MaxAge: -1,
+	})
+	return c.Redirect(http.StatusFound, "/")
+}
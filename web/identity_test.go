@@ -0,0 +1,170 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nevkontakte/pat/chrono/chronotest"
+	"github.com/nevkontakte/pat/db"
+	"github.com/nevkontakte/pat/web/cookie"
+)
+
+func TestIntroduce(t *testing.T) {
+	w, e := newTestServer(t)
+
+	if body := serve(e, http.MethodGet, "/", nil).Body.String(); !strings.Contains(body, "Introduce yourself") {
+		t.Errorf("index page should invite visitors to introduce themselves:\n%s", body)
+	}
+	rec := serve(e, http.MethodPost, "/me/", url.Values{"nickname": {"   "}})
+	if loc := rec.Header().Get("Location"); loc != "/?nickname=invalid" {
+		t.Errorf("POST /me/ with an empty nickname: location = %q, want /?nickname=invalid", loc)
+	}
+
+	rec = serve(e, http.MethodPost, "/me/", url.Values{"nickname": {"Alice"}})
+	var visitor *http.Cookie
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == visitorCookieName {
+			visitor = c
+		}
+	}
+	if visitor == nil || !visitor.HttpOnly {
+		t.Fatalf("POST /me/ should set an HTTP-only visitor cookie, got %v", rec.Result().Cookies())
+	}
+	if body := serve(e, http.MethodGet, "/", nil, visitor).Body.String(); !strings.Contains(body, "knows you as <strong>Alice</strong>") {
+		t.Errorf("index page should greet Alice:\n%s", body)
+	}
+
+	serve(e, http.MethodPost, "/pat/", nil, visitor)
+	var pat db.Journal
+	w.DB.Where("type = ?", db.EventPat).First(&pat)
+	if pat.Visitor == nil || pat.Visitor.IdentityID == 0 || !strings.Contains(pat.Event.Description, "Alice") {
+		t.Errorf("Alice's pat should be linked to her identity and mention her, got %+v", pat)
+	}
+	id := pat.Visitor.IdentityID
+
+	// Renaming keeps the identity.
+	if rec := serve(e, http.MethodPost, "/me/", url.Values{"nickname": {"Alice the Great"}}, visitor); len(rec.Result().Cookies()) != 0 {
+		t.Errorf("renaming shouldn't replace the cookie, got %v", rec.Result().Cookies())
+	}
+	if identity, err := db.IdentityByID(w.DB, id); err != nil || identity.Nickname != "Alice the Great" {
+		t.Errorf("db.IdentityByID() = %+v, %v; want the renamed identity", identity, err)
+	}
+
+	// Cookies can't be forged.
+	forged, err := cookie.SaveCookie(VisitorCookie{Identity: id}, []byte("not the secret"))
+	if err != nil {
+		t.Fatalf("cookie.SaveCookie: %v", err)
+	}
+	body := serve(e, http.MethodGet, "/", nil, &http.Cookie{Name: visitorCookieName, Value: forged}).Body.String()
+	if strings.Contains(body, "Alice") {
+		t.Errorf("forged visitor cookie shouldn't be accepted:\n%s", body)
+	}
+
+	rec = serve(e, http.MethodPost, "/me/forget/", nil, visitor)
+	if cookies := rec.Result().Cookies(); len(cookies) != 1 || cookies[0].MaxAge >= 0 {
+		t.Errorf("POST /me/forget/ should clear the visitor cookie, got %v", cookies)
+	}
+}
+
+func TestLeaderboardOptOut(t *testing.T) {
+	w, e := newTestServer(t)
+
+	rec := serve(e, http.MethodPost, "/me/", url.Values{"nickname": {"Alice"}})
+	visitor := rec.Result().Cookies()[0]
+	serve(e, http.MethodPost, "/pat/", nil, visitor)
+	if body := serve(e, http.MethodGet, "/cats/splotch/leaderboard", nil).Body.String(); !strings.Contains(body, "Alice") {
+		t.Errorf("leaderboard should list Alice:\n%s", body)
+	}
+
+	serve(e, http.MethodPost, "/me/leaderboard/", url.Values{"hidden": {"true"}}, visitor)
+	if body := serve(e, http.MethodGet, "/cats/splotch/leaderboard", nil, visitor).Body.String(); strings.Contains(body, "Alice") || !strings.Contains(body, "You're hidden") {
+		t.Errorf("leaderboard shouldn't list Alice after she opted out:\n%s", body)
+	}
+	if body := serve(e, http.MethodGet, "/", nil, visitor).Body.String(); !strings.Contains(body, "Show me on the leaderboard") {
+		t.Errorf("index page should offer to opt back in:\n%s", body)
+	}
+
+	serve(e, http.MethodPost, "/me/leaderboard/", url.Values{"hidden": {"false"}}, visitor)
+	if identity, err := db.IdentityByID(w.DB, 1); err != nil || identity.Hidden {
+		t.Errorf("db.IdentityByID() = %+v, %v; want Alice back on the leaderboard", identity, err)
+	}
+
+	// Forgotten visitors can't opt out any more, so they're hidden.
+	serve(e, http.MethodPost, "/me/forget/", nil, visitor)
+	if body := serve(e, http.MethodGet, "/cats/splotch/leaderboard", nil).Body.String(); strings.Contains(body, "Alice") {
+		t.Errorf("leaderboard shouldn't list Alice after she asked to be forgotten:\n%s", body)
+	}
+}
+
+func TestLeaderboardOptOut_Anonymous(t *testing.T) {
+	_, e := newTestServer(t)
+	e.Use(VisitorMiddleware)
+
+	serve(e, http.MethodPost, "/pat/", nil)
+	if body := serve(e, http.MethodGet, "/cats/splotch/leaderboard", nil).Body.String(); !strings.Contains(body, "Anonymous visitor") {
+		t.Errorf("leaderboard should list the anonymous visitor:\n%s", body)
+	}
+	if body := serve(e, http.MethodGet, "/", nil).Body.String(); !strings.Contains(body, "Hide me from the leaderboard") {
+		t.Errorf("index page should offer anonymous visitors to opt out:\n%s", body)
+	}
+
+	if rec := serve(e, http.MethodPost, "/me/leaderboard/", url.Values{"hidden": {"true"}}); rec.Code != http.StatusFound {
+		t.Fatalf("POST /me/leaderboard/: status = %d, want %d", rec.Code, http.StatusFound)
+	}
+	if body := serve(e, http.MethodGet, "/cats/splotch/leaderboard", nil).Body.String(); strings.Contains(body, "Anonymous visitor") || !strings.Contains(body, "You're hidden") {
+		t.Errorf("leaderboard shouldn't list the anonymous visitor after they opted out:\n%s", body)
+	}
+	if body := serve(e, http.MethodGet, "/", nil).Body.String(); !strings.Contains(body, "Show me on the leaderboard") {
+		t.Errorf("index page should offer to opt back in:\n%s", body)
+	}
+
+	serve(e, http.MethodPost, "/me/leaderboard/", url.Values{"hidden": {"false"}})
+	if body := serve(e, http.MethodGet, "/cats/splotch/leaderboard", nil).Body.String(); !strings.Contains(body, "Anonymous visitor") {
+		t.Errorf("leaderboard should list the anonymous visitor after they opted back in:\n%s", body)
+	}
+
+	// Visitors without an address can't be told apart, so they can't opt out.
+	req := httptest.NewRequest(http.MethodPost, "/me/leaderboard/", strings.NewReader("hidden=true"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.RemoteAddr = ""
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("POST /me/leaderboard/ without an address: status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
+
+func TestFamiliarity(t *testing.T) {
+	w, e := newTestServer(t)
+	clock := chronotest.NewClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	w.Clock = clock
+
+	visitor := serve(e, http.MethodPost, "/me/", url.Values{"nickname": {"Alice"}}).Result().Cookies()[0]
+	recognized := func(cookies ...*http.Cookie) bool {
+		t.Helper()
+		serve(e, http.MethodPost, "/pat/", nil, cookies...)
+		return strings.Contains(serve(e, http.MethodGet, "/?reaction=pat", nil, cookies...).Body.String(), "Splotch recognizes you and purrs.")
+	}
+
+	for day := 1; day <= 2; day++ {
+		if recognized(visitor) {
+			t.Errorf("Splotch shouldn't recognize Alice after %d days of pats", day)
+		}
+		clock.Advance(24 * time.Hour)
+	}
+	if !recognized(visitor) {
+		t.Errorf("Splotch should recognize Alice after 3 days of pats")
+	}
+	if recognized() {
+		t.Errorf("Splotch shouldn't recognize an anonymous visitor")
+	}
+
+	clock.Advance(90 * 24 * time.Hour)
+	if body := serve(e, http.MethodGet, "/?reaction=pat", nil, visitor).Body.String(); strings.Contains(body, "recognizes you") {
+		t.Errorf("Splotch should forget Alice after 3 months of absence")
+	}
+}
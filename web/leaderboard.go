@@ -0,0 +1,98 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v5"
+	"github.com/nevkontakte/pat/db"
+)
+
+// leaderboardSize is the number of visitors ranked for each period.
+const leaderboardSize = 10
+
+// leaderboardEntry is the JSON representation of a visitor's place on the
+// leaderboard. Visitors who didn't introduce themselves are anonymous.
+type leaderboardEntry struct {
+	Rank      int    `json:"rank"`
+	Nickname  string `json:"nickname,omitempty"`
+	Anonymous bool   `json:"anonymous,omitempty"`
+	Pats      int64  `json:"pats"`
+}
+
+// Name returns how the visitor is shown on the leaderboard page.
+func (e leaderboardEntry) Name() string {
+	if e.Anonymous {
+		return "Anonymous visitor"
+	}
+	return e.Nickname
+}
+
+// leaderboardBoard is the leaderboard for a single period.
+type leaderboardBoard struct {
+	Period  db.LeaderboardPeriod `json:"period"`
+	Title   string               `json:"-"`
+	Since   *time.Time           `json:"since,omitempty"`
+	Entries []leaderboardEntry   `json:"entries"`
+}
+
+type leaderboardData struct {
+	Cat    db.Cat             `json:"-"`
+	CatID  db.CatID           `json:"cat"`
+	Boards []leaderboardBoard `json:"leaderboards"`
+	// Hidden is true if the current visitor opted out of the leaderboard.
+	Hidden bool `json:"-"`
+}
+
+// leaderboardData ranks visitors who patted the cat the most over each period.
+func (w *Web) leaderboardData(c *echo.Context) (*leaderboardData, error) {
+	cat, err := w.catFromParam(c)
+	if err != nil {
+		return nil, err
+	}
+	now := w.clock().Now()
+	data := &leaderboardData{Cat: cat, CatID: cat.ID}
+	for _, period := range db.LeaderboardPeriods {
+		board := leaderboardBoard{Period: period, Title: period.Title(), Entries: []leaderboardEntry{}}
+		since := period.Since(now)
+		if !since.IsZero() {
+			board.Since = &since
+		}
+		entries, err := db.Leaderboard(w.DB, cat.ID, since, leaderboardSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the %s leaderboard: %w", period, err)
+		}
+		for _, e := range entries {
+			board.Entries = append(board.Entries, leaderboardEntry{
+				Rank:      e.Rank,
+				Nickname:  e.Nickname,
+				Anonymous: e.Nickname == "",
+				Pats:      e.Pats,
+			})
+		}
+		data.Boards = append(data.Boards, board)
+	}
+	return data, nil
+}
+
+// leaderboard shows a public page with the most dedicated patters of the cat.
+func (w *Web) leaderboard(c *echo.Context) error {
+	data, err := w.leaderboardData(c)
+	if err != nil {
+		return err
+	}
+	if data.Hidden, err = w.hiddenFromLeaderboard(c); err != nil {
+		return fmt.Errorf("failed to check the leaderboard opt-out: %w", err)
+	}
+	return c.Render(http.StatusOK, "leaderboard.html", data)
+}
+
+// leaderboardJSON returns the leaderboards of the cat as JSON.
+func (w *Web) leaderboardJSON(c *echo.Context) error {
+	data, err := w.leaderboardData(c)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, data)
+}
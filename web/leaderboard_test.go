@@ -0,0 +1,89 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nevkontakte/pat/chrono/chronotest"
+	"github.com/nevkontakte/pat/db"
+)
+
+func TestLeaderboard(t *testing.T) {
+	w, e := newTestServer(t)
+	// Wednesday.
+	now := time.Date(2024, 1, 3, 12, 0, 0, 0, time.UTC)
+	w.Clock = chronotest.NewClock(now)
+
+	alice, err := db.CreateIdentity(w.DB, "Alice")
+	if err != nil {
+		t.Fatalf("db.CreateIdentity: %v", err)
+	}
+	pats := func(at time.Time, n int, v *db.Visitor) {
+		t.Helper()
+		for range n {
+			if err := w.DB.Create(&db.Journal{CreatedAt: at, Visitor: v, CatID: db.SplotchID, Event: db.Event{Type: db.EventPat}}).Error; err != nil {
+				t.Fatalf("failed to create a journal record: %v", err)
+			}
+		}
+	}
+	stranger := &db.Visitor{Addr: db.Addr(netip.MustParseAddr("192.0.2.1")), Agent: "SecretBrowser/1.0"}
+	pats(now.AddDate(0, 0, -7), 5, &db.Visitor{IdentityID: alice.ID})
+	pats(now.Add(-time.Hour), 2, stranger)
+	pats(now.AddDate(0, 0, -1), 1, &db.Visitor{IdentityID: alice.ID})
+
+	rec := serve(e, http.MethodGet, "/cats/splotch/leaderboard.json", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /cats/splotch/leaderboard.json: status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	var got leaderboardData
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("failed to parse the leaderboard: %v", err)
+	}
+	today := time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)
+	monday := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	want := leaderboardData{
+		CatID: db.SplotchID,
+		Boards: []leaderboardBoard{{
+			Period:  db.PeriodToday,
+			Since:   &today,
+			Entries: []leaderboardEntry{{Rank: 1, Anonymous: true, Pats: 2}},
+		}, {
+			Period: db.PeriodWeek,
+			Since:  &monday,
+			Entries: []leaderboardEntry{
+				{Rank: 1, Anonymous: true, Pats: 2},
+				{Rank: 2, Nickname: "Alice", Pats: 1},
+			},
+		}, {
+			Period: db.PeriodAllTime,
+			Entries: []leaderboardEntry{
+				{Rank: 1, Nickname: "Alice", Pats: 6},
+				{Rank: 2, Anonymous: true, Pats: 2},
+			},
+		}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("leaderboard mismatch (-want +got):\n%s", diff)
+	}
+
+	body := serve(e, http.MethodGet, "/cats/splotch/leaderboard", nil).Body.String()
+	for _, s := range []string{"This week", "Alice", "6 pats", "Anonymous visitor"} {
+		if !strings.Contains(body, s) {
+			t.Errorf("leaderboard page should mention %q:\n%s", s, body)
+		}
+	}
+	for _, secret := range []string{"192.0.2.1", "SecretBrowser"} {
+		if strings.Contains(body, secret) || strings.Contains(rec.Body.String(), secret) {
+			t.Errorf("leaderboard shouldn't reveal %q", secret)
+		}
+	}
+
+	if rec := serve(e, http.MethodGet, "/cats/stray/leaderboard.json", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("GET of a missing cat: status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
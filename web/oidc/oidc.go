@@ -0,0 +1,308 @@
+// Package oidc implements a minimal OpenID Connect relying party.
+//
+// Only the authorization code flow with PKCE is supported, and ID tokens must
+// be signed with RS256, which all OpenID providers are required to support.
+// This is sufficient to let admins sign in with a company identity provider
+// without pulling in a full-featured OAuth2 library.
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config describes the relying party registration with an OpenID provider.
+type Config struct {
+	Issuer       string // Issuer URL, used for discovery and token validation.
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string // Callback URL registered with the provider.
+	// Client used to talk to the provider. Defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// Claims are the ID token claims relevant for authorization.
+type Claims struct {
+	Subject       string   `json:"sub"`
+	Email         string   `json:"email"`
+	EmailVerified bool     `json:"email_verified"`
+	Groups        []string `json:"groups"`
+}
+
+// metadata is a subset of the OpenID provider metadata.
+//
+// See https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderMetadata.
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider is a client for a single OpenID provider. It is safe for concurrent
+// use.
+type Provider struct {
+	config   Config
+	metadata metadata
+
+	mu   sync.Mutex
+	keys map[string]*rsa.PublicKey // Provider signing keys by key ID.
+}
+
+// Discover fetches the provider metadata and returns a ready to use provider.
+func Discover(ctx context.Context, config Config) (*Provider, error) {
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+	p := &Provider{config: config}
+	wellKnown := strings.TrimSuffix(config.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := p.getJSON(ctx, wellKnown, &p.metadata); err != nil {
+		return nil, fmt.Errorf("failed to discover OpenID provider: %w", err)
+	}
+	if p.metadata.Issuer != config.Issuer {
+		return nil, fmt.Errorf("provider issuer %q doesn't match configured issuer %q", p.metadata.Issuer, config.Issuer)
+	}
+	return p, nil
+}
+
+// AuthCodeURL returns the URL of the provider's authorization endpoint to which
+// the user should be redirected to sign in.
+//
+// state and nonce must be random values bound to the user's browser, and
+// verifier is the PKCE code verifier, which will be needed for Exchange.
+func (p *Provider) AuthCodeURL(state, nonce, verifier string) string {
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.config.ClientID)
+	q.Set("redirect_uri", p.config.RedirectURL)
+	q.Set("scope", "openid email profile")
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", Challenge(verifier))
+	q.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(p.metadata.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return p.metadata.AuthorizationEndpoint + sep + q.Encode()
+}
+
+// Exchange redeems the authorization code at the token endpoint and returns the
+// verified ID token claims.
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string, now time.Time) (Claims, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+	form.Set("client_id", p.config.ClientID)
+	form.Set("code_verifier", verifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Claims{}, fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if p.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	var token struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := p.do(req, &token); err != nil {
+		return Claims{}, fmt.Errorf("failed to exchange authorization code: %w", err)
+	}
+	if token.IDToken == "" {
+		return Claims{}, fmt.Errorf("token response has no ID token")
+	}
+	return p.Verify(ctx, token.IDToken, nonce, now)
+}
+
+// idToken is the full set of ID token claims that we validate.
+type idToken struct {
+	Claims
+	Issuer   string   `json:"iss"`
+	Audience audience `json:"aud"`
+	Expiry   int64    `json:"exp"`
+	Nonce    string   `json:"nonce"`
+}
+
+// audience is the "aud" claim, which may be either a string or an array.
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = audience{s}
+		return nil
+	}
+	return json.Unmarshal(b, (*[]string)(a))
+}
+
+// Verify validates the ID token signature and claims, and returns the claims.
+//
+// See https://openid.net/specs/openid-connect-core-1_0.html#IDTokenValidation.
+func (p *Provider) Verify(ctx context.Context, raw string, nonce string, now time.Time) (Claims, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return Claims{}, fmt.Errorf("malformed ID token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return Claims{}, fmt.Errorf("failed to decode ID token header: %w", err)
+	}
+	if header.Alg != "RS256" {
+		return Claims{}, fmt.Errorf("unsupported ID token algorithm %q", header.Alg)
+	}
+	key, err := p.key(ctx, header.Kid)
+	if err != nil {
+		return Claims{}, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Claims{}, fmt.Errorf("failed to decode ID token signature: %w", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+		return Claims{}, fmt.Errorf("invalid ID token signature: %w", err)
+	}
+
+	var tok idToken
+	if err := decodeSegment(parts[1], &tok); err != nil {
+		return Claims{}, fmt.Errorf("failed to decode ID token claims: %w", err)
+	}
+	switch {
+	case tok.Issuer != p.config.Issuer:
+		return Claims{}, fmt.Errorf("ID token issued by %q, want %q", tok.Issuer, p.config.Issuer)
+	case !slices.Contains(tok.Audience, p.config.ClientID):
+		return Claims{}, fmt.Errorf("ID token audience %q doesn't include %q", tok.Audience, p.config.ClientID)
+	case !now.Before(time.Unix(tok.Expiry, 0)):
+		return Claims{}, fmt.Errorf("ID token expired at %v", time.Unix(tok.Expiry, 0))
+	case tok.Nonce != nonce:
+		return Claims{}, fmt.Errorf("ID token nonce mismatch")
+	}
+	return tok.Claims, nil
+}
+
+// key returns the provider's signing key with the given ID.
+//
+// Keys are cached, and refreshed if the provider starts using an unknown key.
+func (p *Provider) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	keys, err := p.fetchKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.keys = keys
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown ID token signing key %q", kid)
+}
+
+func (p *Provider) fetchKeys(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := p.getJSON(ctx, p.metadata.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("failed to fetch provider keys: %w", err)
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode modulus of key %q: %w", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode exponent of key %q: %w", k.Kid, err)
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return keys, nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	return p.do(req, v)
+}
+
+func (p *Provider) do(req *http.Request, v any) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.config.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: %s: %s", req.Method, req.URL, resp.Status, body)
+	}
+	return json.Unmarshal(body, v)
+}
+
+func decodeSegment(seg string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// RandomString returns a random URL-safe string, suitable for state, nonce and
+// PKCE verifier values.
+func RandomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random string: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Challenge returns the S256 PKCE code challenge for the verifier.
+func Challenge(verifier string) string {
+	h := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(h[:])
+}
@@ -0,0 +1,160 @@
+package oidc_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nevkontakte/pat/web/oidc"
+	"github.com/nevkontakte/pat/web/oidc/oidctest"
+)
+
+const redirectURL = "https://pat.example.com/admin/login/oidc/callback"
+
+// authorize performs the authorization request against the fake provider and
+// returns the authorization code.
+func authorize(t *testing.T, fake *oidctest.Provider, p *oidc.Provider, state, nonce, verifier string) string {
+	t.Helper()
+	client := fake.Server.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	resp, err := client.Get(p.AuthCodeURL(state, nonce, verifier))
+	if err != nil {
+		t.Fatalf("Authorization request failed: %s", err)
+	}
+	resp.Body.Close()
+	loc, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("Failed to parse redirect location: %s", err)
+	}
+	if got := loc.Query().Get("state"); got != state {
+		t.Fatalf("Got: state %q in the callback. Want: %q.", got, state)
+	}
+	return loc.Query().Get("code")
+}
+
+func TestProvider_Exchange(t *testing.T) {
+	ctx := context.Background()
+	fake := oidctest.New(t)
+	fake.User = oidctest.User{Subject: "42", Email: "alice@example.com", Groups: []string{"cats"}}
+
+	p, err := oidc.Discover(ctx, fake.Config(redirectURL))
+	if err != nil {
+		t.Fatalf("Got: Discover() returned error: %s. Want: no error.", err)
+	}
+
+	t.Run("success", func(t *testing.T) {
+		code := authorize(t, fake, p, "state", "nonce", "verifier")
+		claims, err := p.Exchange(ctx, code, "verifier", "nonce", time.Now())
+		if err != nil {
+			t.Fatalf("Got: Exchange() returned error: %s. Want: no error.", err)
+		}
+		want := oidc.Claims{Subject: "42", Email: "alice@example.com", EmailVerified: true, Groups: []string{"cats"}}
+		if diff := cmp.Diff(want, claims); diff != "" {
+			t.Errorf("Exchange() returned diff (-want,+got):\n%s", diff)
+		}
+	})
+
+	t.Run("wrong verifier", func(t *testing.T) {
+		code := authorize(t, fake, p, "state", "nonce", "verifier")
+		if _, err := p.Exchange(ctx, code, "other-verifier", "nonce", time.Now()); err == nil {
+			t.Error("Got: Exchange() with a wrong PKCE verifier succeeded. Want: error.")
+		}
+	})
+
+	t.Run("wrong nonce", func(t *testing.T) {
+		code := authorize(t, fake, p, "state", "nonce", "verifier")
+		if _, err := p.Exchange(ctx, code, "verifier", "other-nonce", time.Now()); err == nil {
+			t.Error("Got: Exchange() with a wrong nonce succeeded. Want: error.")
+		}
+	})
+
+	t.Run("code reuse", func(t *testing.T) {
+		code := authorize(t, fake, p, "state", "nonce", "verifier")
+		if _, err := p.Exchange(ctx, code, "verifier", "nonce", time.Now()); err != nil {
+			t.Fatalf("Got: Exchange() returned error: %s. Want: no error.", err)
+		}
+		if _, err := p.Exchange(ctx, code, "verifier", "nonce", time.Now()); err == nil {
+			t.Error("Got: second Exchange() with the same code succeeded. Want: error.")
+		}
+	})
+}
+
+func TestProvider_Verify(t *testing.T) {
+	ctx := context.Background()
+	fake := oidctest.New(t)
+	p, err := oidc.Discover(ctx, fake.Config(redirectURL))
+	if err != nil {
+		t.Fatalf("Got: Discover() returned error: %s. Want: no error.", err)
+	}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	valid := func() map[string]any {
+		return map[string]any{
+			"iss":   fake.Server.URL,
+			"sub":   "42",
+			"aud":   []string{"other-client", oidctest.ClientID},
+			"exp":   now.Add(time.Minute).Unix(),
+			"nonce": "nonce",
+		}
+	}
+
+	tests := []struct {
+		name    string
+		token   func() string
+		wantErr bool
+	}{
+		{
+			name:  "valid",
+			token: func() string { return fake.Sign(valid()) },
+		},
+		{
+			name: "wrong issuer",
+			token: func() string {
+				c := valid()
+				c["iss"] = "https://evil.example.com"
+				return fake.Sign(c)
+			},
+			wantErr: true,
+		},
+		{
+			name: "wrong audience",
+			token: func() string {
+				c := valid()
+				c["aud"] = "other-client"
+				return fake.Sign(c)
+			},
+			wantErr: true,
+		},
+		{
+			name: "expired",
+			token: func() string {
+				c := valid()
+				c["exp"] = now.Unix()
+				return fake.Sign(c)
+			},
+			wantErr: true,
+		},
+		{
+			name:    "tampered",
+			token:   func() string { return fake.Sign(valid()) + "x" },
+			wantErr: true,
+		},
+		{
+			name:    "malformed",
+			token:   func() string { return "not-a-jwt" },
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := p.Verify(ctx, tc.token(), "nonce", now)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("Got: Verify() returned error: %v. Want error: %v.", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+// Package oidctest provides an in-process fake OpenID provider for use in tests.
+package oidctest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nevkontakte/pat/web/oidc"
+)
+
+const (
+	// ClientID is the only client registered with the fake provider.
+	ClientID = "test-client"
+	// ClientSecret is the secret of the registered client.
+	ClientSecret = "test-client-secret"
+	keyID        = "test-key"
+)
+
+// User describes the identity the fake provider signs in.
+type User struct {
+	Subject string
+	Email   string
+	Groups  []string
+}
+
+// Provider is a fake OpenID provider, which signs in the configured user
+// without any interaction.
+type Provider struct {
+	Server *httptest.Server
+	// User that will be signed in by the next authorization request.
+	User User
+	// Now is the time used for the ID token expiration. Defaults to time.Now.
+	Now func() time.Time
+
+	key   *rsa.PrivateKey
+	mu    sync.Mutex
+	codes map[string]grant
+}
+
+// grant is the state of an issued authorization code.
+type grant struct {
+	user        User
+	nonce       string
+	challenge   string
+	redirectURI string
+}
+
+// New starts a fake OpenID provider, which is shut down at the end of the test.
+func New(t *testing.T) *Provider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate provider key: %s", err)
+	}
+	p := &Provider{
+		Now:   time.Now,
+		key:   key,
+		codes: map[string]grant{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("GET /authorize", p.authorize)
+	mux.HandleFunc("POST /token", p.token)
+	mux.HandleFunc("GET /jwks", p.jwks)
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Server.Close)
+	return p
+}
+
+// Config returns the relying party configuration for the fake provider.
+func (p *Provider) Config(redirectURL string) oidc.Config {
+	return oidc.Config{
+		Issuer:       p.Server.URL,
+		ClientID:     ClientID,
+		ClientSecret: ClientSecret,
+		RedirectURL:  redirectURL,
+		Client:       p.Server.Client(),
+	}
+}
+
+func (p *Provider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]string{
+		"issuer":                 p.Server.URL,
+		"authorization_endpoint": p.Server.URL + "/authorize",
+		"token_endpoint":         p.Server.URL + "/token",
+		"jwks_uri":               p.Server.URL + "/jwks",
+	})
+}
+
+// authorize immediately redirects back to the client with an authorization code
+// for the current user.
+func (p *Provider) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != ClientID || q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+
+	code := rand.Text()
+	p.mu.Lock()
+	p.codes[code] = grant{
+		user:        p.User,
+		nonce:       q.Get("nonce"),
+		challenge:   q.Get("code_challenge"),
+		redirectURI: q.Get("redirect_uri"),
+	}
+	p.mu.Unlock()
+
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	rq := redirect.Query()
+	rq.Set("code", code)
+	rq.Set("state", q.Get("state"))
+	redirect.RawQuery = rq.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+	id, secret, ok := r.BasicAuth()
+	if !ok || id != ClientID || secret != ClientSecret {
+		http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+		return
+	}
+
+	code := r.FormValue("code")
+	p.mu.Lock()
+	g, ok := p.codes[code]
+	delete(p.codes, code)
+	p.mu.Unlock()
+	if !ok || r.FormValue("redirect_uri") != g.redirectURI || oidc.Challenge(r.FormValue("code_verifier")) != g.challenge {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, map[string]string{
+		"access_token": rand.Text(),
+		"token_type":   "Bearer",
+		"id_token": p.Sign(map[string]any{
+			"iss":            p.Server.URL,
+			"sub":            g.user.Subject,
+			"aud":            ClientID,
+			"exp":            p.Now().Add(time.Hour).Unix(),
+			"iat":            p.Now().Unix(),
+			"nonce":          g.nonce,
+			"email":          g.user.Email,
+			"email_verified": true,
+			"groups":         g.user.Groups,
+		}),
+	})
+}
+
+func (p *Provider) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": keyID,
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+		}},
+	})
+}
+
+// Sign returns a JWT with the given claims, signed with the provider key.
+func (p *Provider) Sign(claims map[string]any) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": keyID, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, digest[:])
+	if err != nil {
+		panic(err) // Should never happen.
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,193 @@
+// Package proxy determines the original client address and protocol of
+// requests that passed through trusted reverse proxies.
+//
+// Forwarding headers can be set by anyone, so they are only taken into account
+// when the request came from a trusted proxy. When there is a chain of proxies,
+// the client is the rightmost address that doesn't belong to a trusted proxy:
+// everything to the left of it could have been made up by the client.
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// Trusted is a set of networks that reverse proxies in front of the server
+// connect from. The zero value trusts no one.
+type Trusted []netip.Prefix
+
+// ParseTrusted parses a comma-separated list of CIDRs or IP addresses.
+func ParseTrusted(s string) (Trusted, error) {
+	var t Trusted
+	for entry := range strings.SplitSeq(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if strings.Contains(entry, "/") {
+			p, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy network %q: %w", entry, err)
+			}
+			t = append(t, p.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy address %q: %w", entry, err)
+		}
+		addr = addr.Unmap()
+		t = append(t, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return t, nil
+}
+
+// Contains returns true if the address belongs to a trusted proxy.
+func (t Trusted) Contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range t {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExtractIP returns the client IP address of the request.
+//
+// It implements echo.IPExtractor. The RFC 7239 Forwarded header takes
+// precedence over X-Forwarded-For, which takes precedence over X-Real-IP.
+func (t Trusted) ExtractIP(r *http.Request) string {
+	remote := remoteAddr(r)
+	if !t.Contains(remote) {
+		return remote.String()
+	}
+
+	var chain []netip.Addr
+	if fwd := r.Header.Values("Forwarded"); len(fwd) > 0 {
+		for _, e := range parseForwarded(fwd) {
+			chain = append(chain, parseNode(e["for"]))
+		}
+	} else if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		for _, v := range xff {
+			for hop := range strings.SplitSeq(v, ",") {
+				chain = append(chain, parseNode(strings.TrimSpace(hop)))
+			}
+		}
+	} else if xrip := r.Header.Get("X-Real-IP"); xrip != "" {
+		chain = append(chain, parseNode(xrip))
+	}
+
+	client := remote
+	for i := len(chain) - 1; i >= 0; i-- {
+		if !chain[i].IsValid() {
+			// Obfuscated or malformed: the closest hop we can tell anything about.
+			break
+		}
+		client = chain[i]
+		if !t.Contains(client) {
+			break
+		}
+	}
+	return client.String()
+}
+
+// Scheme returns the protocol, "http" or "https", the client used to connect.
+//
+// Unlike echo.Context.Scheme, it only honors the Forwarded and
+// X-Forwarded-Proto headers set by a trusted proxy.
+func (t Trusted) Scheme(r *http.Request) string {
+	if r.TLS != nil {
+		return "https"
+	}
+	if !t.Contains(remoteAddr(r)) {
+		return "http"
+	}
+	var proto string
+	if fwd := r.Header.Values("Forwarded"); len(fwd) > 0 {
+		// The last element was added by the proxy closest to us.
+		elements := parseForwarded(fwd)
+		proto = elements[len(elements)-1]["proto"]
+	} else {
+		proto = r.Header.Get("X-Forwarded-Proto")
+	}
+	if strings.EqualFold(proto, "https") {
+		return "https"
+	}
+	return "http"
+}
+
+// remoteAddr returns the address of the other end of the connection.
+func remoteAddr(r *http.Request) netip.Addr {
+	if ap, err := netip.ParseAddrPort(r.RemoteAddr); err == nil {
+		return ap.Addr().Unmap()
+	}
+	addr, _ := netip.ParseAddr(r.RemoteAddr)
+	return addr.Unmap()
+}
+
+// parseNode parses a node identifier from a forwarding header: an IPv4
+// address or a bracketed IPv6 address, optionally with a port. Returns an
+// invalid address for obfuscated and unknown nodes.
+func parseNode(s string) netip.Addr {
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addr.Unmap()
+}
+
+// parseForwarded parses the values of RFC 7239 Forwarded headers into a list
+// of elements, each mapping lowercase parameter names to values. Always
+// returns at least one, possibly empty, element.
+func parseForwarded(values []string) []map[string]string {
+	elements := []map[string]string{{}}
+	for i, v := range values {
+		if i > 0 {
+			elements = append(elements, map[string]string{})
+		}
+		var key, value strings.Builder
+		inValue, quoted, escaped := false, false, false
+		flush := func() {
+			if k := strings.ToLower(strings.TrimSpace(key.String())); k != "" {
+				elements[len(elements)-1][k] = strings.TrimSpace(value.String())
+			}
+			key.Reset()
+			value.Reset()
+			inValue = false
+		}
+		for _, r := range v {
+			switch {
+			case escaped:
+				value.WriteRune(r)
+				escaped = false
+			case quoted && r == '\\':
+				escaped = true
+			case r == '"' && inValue:
+				quoted = !quoted
+			case quoted:
+				value.WriteRune(r)
+			case r == '=' && !inValue:
+				inValue = true
+			case r == ';':
+				flush()
+			case r == ',':
+				flush()
+				elements = append(elements, map[string]string{})
+			case inValue:
+				value.WriteRune(r)
+			default:
+				key.WriteRune(r)
+			}
+		}
+		flush()
+	}
+	return elements
+}
@@ -0,0 +1,174 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func newRequest(remote string, headers map[string][]string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = remote
+	for k, vs := range headers {
+		for _, v := range vs {
+			r.Header.Add(k, v)
+		}
+	}
+	return r
+}
+
+func TestParseTrusted(t *testing.T) {
+	got, err := ParseTrusted("10.0.0.0/8, 192.168.1.1,fd00::/8 ,")
+	if err != nil {
+		t.Fatalf("Got: ParseTrusted() returned error: %s. Want: no error.", err)
+	}
+	for _, addr := range []string{"10.1.2.3", "192.168.1.1", "::ffff:10.0.0.1", "fd00::1"} {
+		if !got.Contains(mustAddr(t, addr)) {
+			t.Errorf("Got: %v doesn't contain %s. Want: contains.", got, addr)
+		}
+	}
+	for _, addr := range []string{"11.0.0.1", "192.168.1.2", "2001:db8::1"} {
+		if got.Contains(mustAddr(t, addr)) {
+			t.Errorf("Got: %v contains %s. Want: doesn't contain.", got, addr)
+		}
+	}
+
+	for _, s := range []string{"10.0.0.0/33", "proxy.example.com"} {
+		if _, err := ParseTrusted(s); err == nil {
+			t.Errorf("Got: ParseTrusted(%q) succeeded. Want: error.", s)
+		}
+	}
+}
+
+func TestTrusted_ExtractIP(t *testing.T) {
+	trusted, err := ParseTrusted("10.0.0.0/8")
+	if err != nil {
+		t.Fatalf("ParseTrusted: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		remote  string
+		headers map[string][]string
+		want    string
+	}{
+		{
+			name:   "direct",
+			remote: "203.0.113.1:1234",
+			want:   "203.0.113.1",
+		},
+		{
+			name:    "untrusted peer can't spoof",
+			remote:  "203.0.113.1:1234",
+			headers: map[string][]string{"X-Forwarded-For": {"198.51.100.7"}, "X-Real-Ip": {"198.51.100.7"}},
+			want:    "203.0.113.1",
+		},
+		{
+			name:    "x-forwarded-for",
+			remote:  "10.0.0.1:1234",
+			headers: map[string][]string{"X-Forwarded-For": {"198.51.100.7"}},
+			want:    "198.51.100.7",
+		},
+		{
+			name:    "x-forwarded-for chain",
+			remote:  "10.0.0.1:1234",
+			headers: map[string][]string{"X-Forwarded-For": {"192.0.2.66, 198.51.100.7", "10.0.0.2"}},
+			want:    "198.51.100.7",
+		},
+		{
+			name:    "x-real-ip",
+			remote:  "10.0.0.1:1234",
+			headers: map[string][]string{"X-Real-Ip": {"198.51.100.7"}},
+			want:    "198.51.100.7",
+		},
+		{
+			name:   "forwarded",
+			remote: "10.0.0.1:1234",
+			headers: map[string][]string{
+				"Forwarded":       {`for=192.0.2.66, for="[2001:db8:cafe::17]:4711";proto=https`},
+				"X-Forwarded-For": {"198.51.100.7"},
+			},
+			want: "2001:db8:cafe::17",
+		},
+		{
+			name:    "forwarded obfuscated",
+			remote:  "10.0.0.1:1234",
+			headers: map[string][]string{"Forwarded": {"for=_hidden, for=10.0.0.2"}},
+			want:    "10.0.0.2",
+		},
+		{
+			name:   "only proxies",
+			remote: "10.0.0.1:1234",
+			want:   "10.0.0.1",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := trusted.ExtractIP(newRequest(tc.remote, tc.headers)); got != tc.want {
+				t.Errorf("Got: ExtractIP() = %q. Want: %q.", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTrusted_Scheme(t *testing.T) {
+	trusted, err := ParseTrusted("10.0.0.1")
+	if err != nil {
+		t.Fatalf("ParseTrusted: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		remote  string
+		headers map[string][]string
+		want    string
+	}{
+		{name: "plain", remote: "10.0.0.1:1234", want: "http"},
+		{name: "x-forwarded-proto", remote: "10.0.0.1:1234", headers: map[string][]string{"X-Forwarded-Proto": {"https"}}, want: "https"},
+		{name: "untrusted", remote: "203.0.113.1:1234", headers: map[string][]string{"X-Forwarded-Proto": {"https"}}, want: "http"},
+		{name: "forwarded", remote: "10.0.0.1:1234", headers: map[string][]string{"Forwarded": {"for=192.0.2.66;proto=http, for=192.0.2.1;Proto=HTTPS"}}, want: "https"},
+		{name: "forwarded without proto", remote: "10.0.0.1:1234", headers: map[string][]string{"Forwarded": {"for=192.0.2.1"}, "X-Forwarded-Proto": {"https"}}, want: "http"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := trusted.Scheme(newRequest(tc.remote, tc.headers)); got != tc.want {
+				t.Errorf("Got: Scheme() = %q. Want: %q.", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("tls", func(t *testing.T) {
+		r := newRequest("203.0.113.1:1234", nil)
+		r.TLS = &tls.ConnectionState{}
+		if got := Trusted(nil).Scheme(r); got != "https" {
+			t.Errorf("Got: Scheme() = %q. Want: %q.", got, "https")
+		}
+	})
+}
+
+func TestParseForwarded(t *testing.T) {
+	got := parseForwarded([]string{`for="_gazonk";by=203.0.113.43, For="[2001:db8::1]:80"`, `proto="h\"t";host=example.com`})
+	want := []map[string]string{
+		{"for": "_gazonk", "by": "203.0.113.43"},
+		{"for": "[2001:db8::1]:80"},
+		{"proto": `h"t`, "host": "example.com"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("parseForwarded() returned diff (-want,+got):\n%s", diff)
+	}
+}
+
+func mustAddr(t *testing.T, s string) netip.Addr {
+	t.Helper()
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		t.Fatalf("netip.ParseAddr(%q): %v", s, err)
+	}
+	return addr
+}
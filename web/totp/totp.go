@@ -0,0 +1,120 @@
+// Package totp implements time-based one-time passwords as defined in RFC 6238.
+//
+// Only the parameters supported by virtually every authenticator app are
+// implemented: HMAC-SHA1, 6 digits and a 30 second time step. All functions
+// accept the current time explicitly, which makes them easy to test with a fake
+// clock.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+const (
+	// Step is the duration of a single TOTP time step.
+	Step = 30 * time.Second
+	// Digits is the number of digits in a generated code.
+	Digits = 6
+	// Skew is the number of time steps before and after the current one, during
+	// which a code is still accepted. It compensates for clock drift and user
+	// typing speed.
+	Skew = 1
+	// secretSize is the size of generated secrets in bytes, as recommended by
+	// RFC 4226.
+	secretSize = 20
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Key is a shared TOTP secret along with the information required to provision
+// it into an authenticator app.
+type Key struct {
+	Secret  []byte
+	Issuer  string // Service name shown by the authenticator app.
+	Account string // Account name shown by the authenticator app.
+}
+
+// NewKey generates a new random secret for the given account.
+func NewKey(issuer, account string) (Key, error) {
+	secret := make([]byte, secretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return Key{}, fmt.Errorf("failed to generate TOTP secret: %w", err)
+	}
+	return Key{Secret: secret, Issuer: issuer, Account: account}, nil
+}
+
+// EncodedSecret returns the base32 representation of the secret, suitable for
+// manual entry into an authenticator app.
+func (k Key) EncodedSecret() string {
+	return encoding.EncodeToString(k.Secret)
+}
+
+// URI returns the otpauth:// provisioning URI for the key.
+//
+// See https://github.com/google/google-authenticator/wiki/Key-Uri-Format.
+func (k Key) URI() string {
+	q := url.Values{}
+	q.Set("secret", k.EncodedSecret())
+	q.Set("issuer", k.Issuer)
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + k.Issuer + ":" + k.Account,
+		RawQuery: q.Encode(),
+	}
+	return u.String()
+}
+
+// Counter returns the time step counter corresponding to the time t.
+func Counter(t time.Time) int64 {
+	return t.Unix() / int64(Step/time.Second)
+}
+
+// Code returns the TOTP code for the secret at time t.
+func Code(secret []byte, t time.Time) string {
+	return hotp(secret, Counter(t))
+}
+
+// Validate checks the code against the secret at time t.
+//
+// Codes from up to Skew adjacent time steps are accepted. Codes belonging to
+// the time steps at or before `after` are rejected, which allows the caller to
+// prevent reuse of a code by remembering the returned counter.
+//
+// Returns the time step counter the code matched, and whether the code is
+// valid.
+func Validate(secret []byte, code string, t time.Time, after int64) (int64, bool) {
+	if len(code) != Digits {
+		return 0, false
+	}
+	now := Counter(t)
+	for counter := now - Skew; counter <= now+Skew; counter++ {
+		if counter <= after {
+			continue
+		}
+		if hmac.Equal([]byte(hotp(secret, counter)), []byte(code)) {
+			return counter, true
+		}
+	}
+	return 0, false
+}
+
+// hotp computes an HOTP value as defined in RFC 4226, section 5.3.
+func hotp(secret []byte, counter int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", Digits, value%1_000_000)
+}
@@ -0,0 +1,83 @@
+package totp
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 test secret from RFC 6238, Appendix B.
+var rfcSecret = []byte("12345678901234567890")
+
+func TestCode(t *testing.T) {
+	// Test vectors from RFC 6238, Appendix B, truncated to 6 digits.
+	testCases := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tc := range testCases {
+		if got := Code(rfcSecret, time.Unix(tc.unix, 0)); got != tc.want {
+			t.Errorf("Code(%d) = %q, want %q", tc.unix, got, tc.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	code := Code(rfcSecret, now)
+
+	testCases := []struct {
+		name   string
+		code   string
+		at     time.Time
+		after  int64
+		wantOK bool
+	}{
+		{name: "current step", code: code, at: now, wantOK: true},
+		{name: "previous step", code: code, at: now.Add(Step), wantOK: true},
+		{name: "next step", code: code, at: now.Add(-Step), wantOK: true},
+		{name: "too late", code: code, at: now.Add(2 * Step), wantOK: false},
+		{name: "too early", code: code, at: now.Add(-2 * Step), wantOK: false},
+		{name: "wrong code", code: "000000", at: now, wantOK: false},
+		{name: "wrong length", code: code[:5], at: now, wantOK: false},
+		{name: "already used", code: code, at: now, after: Counter(now), wantOK: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			counter, ok := Validate(rfcSecret, tc.code, tc.at, tc.after)
+			if ok != tc.wantOK {
+				t.Fatalf("Validate(%q) = %v, want %v", tc.code, ok, tc.wantOK)
+			}
+			if ok && counter != Counter(now) {
+				t.Errorf("Validate(%q) returned counter %d, want %d", tc.code, counter, Counter(now))
+			}
+		})
+	}
+}
+
+func TestKey_URI(t *testing.T) {
+	k := Key{Secret: rfcSecret, Issuer: "Splotch", Account: "admin"}
+
+	u, err := url.Parse(k.URI())
+	if err != nil {
+		t.Fatalf("url.Parse(%q) returned error: %v", k.URI(), err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/Splotch:admin" {
+		t.Errorf("Got URI %q. Want otpauth://totp/Splotch:admin.", k.URI())
+	}
+	if got, want := u.Query().Get("secret"), "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"; got != want {
+		t.Errorf("Got secret %q. Want %q.", got, want)
+	}
+	if got := u.Query().Get("issuer"); got != "Splotch" {
+		t.Errorf("Got issuer %q. Want %q.", got, "Splotch")
+	}
+}
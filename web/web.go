@@ -1,12 +1,17 @@
 package web
 
 import (
+	"errors"
 	"fmt"
 	"io/fs"
 	"net/http"
 
 	"github.com/labstack/echo/v5"
+	"github.com/nevkontakte/pat/chrono"
 	"github.com/nevkontakte/pat/db"
+	"github.com/nevkontakte/pat/web/feed"
+	"github.com/nevkontakte/pat/web/oidc"
+	"github.com/nevkontakte/pat/web/proxy"
 	"gorm.io/gorm"
 )
 
@@ -14,24 +19,83 @@ import (
 type Web struct {
 	StaticFS          fs.FS
 	DB                *gorm.DB
-	AdminPasswordHash []byte // Bcrypt hash of the admin password. Admin routes are disabled if empty.
-	Secret            []byte // Server-side signing secret for session cookies. Admin routes are disabled if empty.
+	AdminPasswordHash []byte         // Bcrypt hash of the bootstrap owner password. Password login for it is disabled if empty.
+	Secret            []byte         // Server-side signing secret for session cookies. Admin routes are disabled if empty.
+	OIDC              *oidc.Provider // OpenID provider for admin sign-in. Disabled if nil.
+	OIDCAccess        OIDCAccess     // Allowlist of OpenID provider users granted admin access.
+	Proxies           proxy.Trusted  // Reverse proxies trusted to report the client protocol.
+	Clock             chrono.Clock   // Source of the current time. Defaults to chrono.System.
 }
 
 // Bind HTTP handlers to the Echo server.
 func (w *Web) Bind(e *echo.Echo) {
 	e.GET("/", w.index)
-	e.GET("/pat/", w.pat)
+	for _, i := range db.Interactions {
+		e.POST("/"+i.ID+"/", w.interact(i))
+	}
+	// The cat picture is a link, so pats are also accepted with GET.
+	pat, _ := db.InteractionByID("pat")
+	e.GET("/pat/", w.interact(pat))
+	if len(w.Secret) > 0 {
+		// Identities are kept in signed cookies.
+		e.POST("/me/", w.introduce)
+		e.POST("/me/forget/", w.forget)
+	}
+	e.POST("/me/leaderboard/", w.leaderboardOptOut)
+	e.GET("/cats/:id/activity", w.activity)
+	e.GET("/cats/:id/leaderboard", w.leaderboard)
+	e.GET("/cats/:id/leaderboard.json", w.leaderboardJSON)
+	e.GET("/cats/:id/feed.atom", w.catFeedHandler(feed.AtomType, feed.Feed.Atom))
+	e.GET("/cats/:id/feed.rss", w.catFeedHandler(feed.RSSType, feed.Feed.RSS))
 
 	e.StaticFS("/static", w.StaticFS)
 
-	if len(w.AdminPasswordHash) > 0 && len(w.Secret) > 0 {
+	if len(w.Secret) > 0 && (len(w.AdminPasswordHash) > 0 || w.OIDC != nil) {
 		e.GET("/admin/login", w.adminLogin)
 		e.POST("/admin/login", w.adminLoginPost)
+		e.GET("/admin/login/2fa", w.adminSecondFactor)
+		e.POST("/admin/login/2fa", w.adminSecondFactorPost)
+		e.GET("/admin/invite/:token", w.adminInvite)
+		e.POST("/admin/invite/:token", w.adminAcceptInvitePost)
+		if w.OIDC != nil {
+			e.GET("/admin/login/oidc", w.adminOIDCLogin)
+			e.GET("/admin/login/oidc/callback", w.adminOIDCCallback)
+		}
 
-		admin := e.Group("/admin", w.requireAdmin)
-		admin.GET("/", w.adminDashboard)
+		e.GET("/admin/", w.adminDashboard, w.requireRole(db.RoleViewer, db.ScopeReadJournal))
+
+		admin := e.Group("/admin", w.requireRole(db.RoleViewer))
 		admin.GET("/logout", w.adminLogout)
+		admin.GET("/2fa", w.adminEnrollment)
+		admin.POST("/2fa", w.adminEnrollmentPost)
+		admin.GET("/tokens", w.adminTokens)
+		admin.POST("/tokens", w.adminTokenPost)
+		admin.POST("/tokens/:id/revoke", w.adminTokenRevokePost)
+		admin.GET("/cats/:id/timeline", w.adminTimeline)
+		admin.GET("/journal", w.adminJournal)
+
+		owner := e.Group("/admin/admins", w.requireRole(db.RoleOwner))
+		owner.GET("", w.adminUsers)
+		owner.POST("", w.adminInvitePost)
+		owner.POST("/:username/remove", w.adminRemovePost)
+
+		cats := e.Group("/admin/cats", w.requireRole(db.RoleOwner))
+		cats.GET("", w.adminCats)
+		cats.GET("/:id", w.adminCat)
+		cats.POST("/:id", w.adminCatPost)
+
+		webhooks := e.Group("/admin/webhooks", w.requireRole(db.RoleOwner))
+		webhooks.GET("", w.adminWebhooks)
+		webhooks.POST("", w.adminWebhookPost)
+		webhooks.POST("/:id/delete", w.adminWebhookDeletePost)
+		webhooks.GET("/deliveries", w.adminDeliveries)
+
+		api := e.Group("/api")
+		api.GET("/journal", w.apiJournalList, w.requireToken(db.RoleViewer, db.ScopeReadJournal))
+		api.DELETE("/journal/:id", w.apiJournalDelete, w.requireToken(db.RoleModerator, db.ScopeModerate))
+		api.GET("/cats", w.apiCatList, w.requireToken(db.RoleViewer, db.ScopeReadJournal, db.ScopeManageCats))
+		api.POST("/cats", w.apiCatCreate, w.requireToken(db.RoleOwner, db.ScopeManageCats))
+		api.PATCH("/cats/:id", w.apiCatUpdate, w.requireToken(db.RoleOwner, db.ScopeManageCats))
 	}
 }
 
@@ -41,34 +105,132 @@ func (w *Web) index(c *echo.Context) error {
 	if err != nil { // Should never happen.
 		return fmt.Errorf("oops, Splotch went missing 🙀: %w", err)
 	}
-	if err := w.recordJournal(c, db.Event{Type: db.EventVisit}); err != nil {
+	now := w.clock().Now()
+	if err := w.recordJournal(c, db.Event{
+		Type:        db.EventVisit,
+		Description: splotch.Describe(db.EventVisit, now, w.visitor(c)),
+	}); err != nil {
 		return err
 	}
 	data := struct {
-		Cat db.Cat
+		Cat          db.Cat
+		Mood         db.Mood
+		Message      string
+		Needs        []needLevel
+		Interactions []db.Interaction
+		CanIntroduce bool   // Whether visitors can introduce themselves.
+		Nickname     string // Empty for anonymous visitors.
+		Hidden       bool   // Whether the visitor opted out of the leaderboard.
 	}{
-		Cat: splotch,
+		Cat:          splotch,
+		Mood:         splotch.MoodAt(now),
+		CanIntroduce: len(w.Secret) > 0,
+	}
+	var familiarity db.Familiarity
+	if data.Hidden, err = w.hiddenFromLeaderboard(c); err != nil {
+		return fmt.Errorf("failed to check the leaderboard opt-out: %w", err)
+	}
+	if identity, ok := w.identity(c); ok {
+		data.Nickname = identity.Nickname
+		familiarity, err = db.VisitorFamiliarity(w.DB, splotch.ID, identity.ID, now)
+		if err != nil {
+			return fmt.Errorf("failed to recall the visitor: %w", err)
+		}
+	}
+	if i, ok := db.InteractionByID(c.QueryParam("reaction")); ok && (data.Mood != db.MoodImpatient || familiarity.Recognizes()) {
+		// Impatient cats ignore visitors they don't recognize, otherwise they
+		// react to the interaction.
+		data.Mood = i.Reaction
+		if i.Event == db.EventPat && familiarity.Recognizes() {
+			data.Message = fmt.Sprintf("%s recognizes you and purrs.", splotch.Name)
+		}
+	} else if familiarity.Adores() && (data.Mood == db.MoodIdle || data.Mood == db.MoodIdleBlink) {
+		// Cats light up when their favorite visitors come by.
+		data.Mood = db.MoodIdleHappy
+	}
+	if i, ok := db.InteractionByID(c.QueryParam("too_soon")); ok {
+		data.Message = fmt.Sprintf(i.TooSoon, splotch.Name)
+	}
+	if c.QueryParam("nickname") == "invalid" {
+		data.Message = "Nicknames must be 1 to 32 characters long, without special characters."
+	}
+	for _, need := range db.AllNeeds {
+		data.Needs = append(data.Needs, needLevel{Need: need, Level: splotch.NeedLevel(need, now)})
+	}
+	for _, i := range db.Interactions {
+		if i.Label != "" {
+			data.Interactions = append(data.Interactions, i)
+		}
 	}
 	return c.Render(http.StatusOK, "index.html", data)
 }
 
-// pat action handler.
-func (w *Web) pat(c *echo.Context) error {
-	if err := db.Pat(w.DB, db.SplotchID); err != nil {
-		return fmt.Errorf("failed to pat Splotch: %w", err)
+// catFromParam loads the cat identified by the route parameter.
+func (w *Web) catFromParam(c *echo.Context) (db.Cat, error) {
+	cat, err := db.CatByID(w.DB, db.CatID(c.Param("id")))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return db.Cat{}, echo.ErrNotFound
+	} else if err != nil {
+		return db.Cat{}, fmt.Errorf("failed to load cat: %w", err)
 	}
+	return cat, nil
+}
 
-	if err := w.recordJournal(c, db.Event{Type: db.EventPat}); err != nil {
-		return err
+// needLevel is the current level of the cat's need, for display.
+type needLevel struct {
+	Need  db.Need
+	Level float64
+}
+
+// interact returns the handler for the interaction with Splotch.
+func (w *Web) interact(i db.Interaction) echo.HandlerFunc {
+	return func(c *echo.Context) error {
+		milestones, err := db.Interact(w.DB, w.clock(), db.SplotchID, i, w.visitor(c))
+		if errors.Is(err, db.ErrTooSoon) {
+			return c.Redirect(http.StatusFound, "/?too_soon="+i.ID)
+		} else if err != nil {
+			return fmt.Errorf("failed to %s Splotch: %w", i.ID, err)
+		}
+		if len(milestones) > 0 {
+			return w.celebrate(c, milestones)
+		}
+		return c.Redirect(http.StatusFound, "/?reaction="+i.ID)
+	}
+}
+
+// celebrate renders the page congratulating the visitor on reaching
+// milestones, instead of the usual redirect to the index page.
+func (w *Web) celebrate(c *echo.Context, milestones []db.Milestone) error {
+	splotch, err := db.CatByID(w.DB, db.SplotchID)
+	if err != nil {
+		return fmt.Errorf("failed to load cat: %w", err)
+	}
+	data := struct {
+		Cat    db.Cat
+		Titles []string
+	}{Cat: splotch}
+	for _, m := range milestones {
+		data.Titles = append(data.Titles, m.Title(splotch.Name))
 	}
-	return c.Redirect(http.StatusFound, "/")
+	return c.Render(http.StatusOK, "milestone.html", data)
+}
+
+// clock returns the source of the current time.
+func (w *Web) clock() chrono.Clock {
+	return chrono.Or(w.Clock)
+}
+
+// scheme returns the protocol the client used to connect, "http" or "https".
+func (w *Web) scheme(c *echo.Context) string {
+	return w.Proxies.Scheme(c.Request())
 }
 
 func (w *Web) recordJournal(c *echo.Context, e db.Event) error {
 	result := w.DB.Save(&db.Journal{
-		Visitor: VisitorFromContext(c),
-		CatID:   db.SplotchID,
-		Event:   e,
+		CreatedAt: w.clock().Now(),
+		Visitor:   w.visitor(c),
+		CatID:     db.SplotchID,
+		Event:     e,
 	})
 	if result.Error != nil {
 		return fmt.Errorf("failed to add a journal entry: %s", result.Error)
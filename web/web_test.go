@@ -0,0 +1,92 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nevkontakte/pat/chrono/chronotest"
+	"github.com/nevkontakte/pat/db"
+)
+
+func TestInteractions(t *testing.T) {
+	w, e := newTestServer(t)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	w.Clock = chronotest.NewClock(now)
+
+	rec := serve(e, http.MethodGet, "/", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /: status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	var visit db.Journal
+	if result := w.DB.Last(&visit, "type = ?", db.EventVisit); result.Error != nil {
+		t.Fatalf("failed to load the visit journal entry: %v", result.Error)
+	}
+	if !visit.CreatedAt.Equal(now) {
+		t.Errorf("visit recorded at %v, want %v", visit.CreatedAt, now)
+	}
+	for _, i := range db.Interactions {
+		button := `action="/` + i.ID + `/"`
+		if got := strings.Contains(rec.Body.String(), button); got != (i.Label != "") {
+			t.Errorf("index page contains %q = %v, want %v", button, got, i.Label != "")
+		}
+	}
+	if !strings.Contains(rec.Body.String(), "<meter") {
+		t.Error("index page should show the cat's needs")
+	}
+
+	rec = serve(e, http.MethodPost, "/feed/", nil)
+	if loc := rec.Header().Get("Location"); rec.Code != http.StatusFound || loc != "/?reaction=feed" {
+		t.Errorf("POST /feed/: status = %d, location = %q; want a redirect to /?reaction=feed", rec.Code, loc)
+	}
+	cat, err := db.CatByID(w.DB, db.SplotchID)
+	if err != nil {
+		t.Fatalf("db.CatByID: %v", err)
+	}
+	if !cat.Needs.Food.Equal(now) {
+		t.Errorf("Splotch was fed at %v, want %v", cat.Needs.Food, now)
+	}
+	rec = serve(e, http.MethodGet, "/?reaction=feed", nil)
+	if !strings.Contains(rec.Body.String(), "/static/cat/idle_happy.png") {
+		t.Error("index page should show the reaction to feeding")
+	}
+
+	rec = serve(e, http.MethodPost, "/feed/", nil)
+	if loc := rec.Header().Get("Location"); loc != "/?too_soon=feed" {
+		t.Errorf("second POST /feed/: location = %q, want /?too_soon=feed", loc)
+	}
+	rec = serve(e, http.MethodGet, "/?too_soon=feed", nil)
+	if !strings.Contains(rec.Body.String(), "Splotch isn&#39;t hungry yet.") {
+		t.Errorf("index page should explain the cooldown:\n%s", rec.Body)
+	}
+
+	rec = serve(e, http.MethodGet, "/pat/", nil)
+	if loc := rec.Header().Get("Location"); loc != "/?reaction=pat" {
+		t.Errorf("GET /pat/: location = %q, want /?reaction=pat", loc)
+	}
+	if rec := serve(e, http.MethodGet, "/feed/", nil); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /feed/: status = %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestMilestone(t *testing.T) {
+	w, e := newTestServer(t)
+	w.Clock = chronotest.NewClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	w.DB.Model(&db.Cat{ID: db.SplotchID}).Update("pats", 999)
+
+	rec := serve(e, http.MethodPost, "/pat/", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /pat/ reaching a milestone: status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "Splotch&#39;s 1000th pat!") {
+		t.Errorf("milestone page should celebrate the 1000th pat:\n%s", body)
+	}
+
+	if body := serve(e, http.MethodPost, "/pat/", nil).Body.String(); !strings.Contains(body, "Pat number 1001 reads the same backwards!") {
+		t.Errorf("milestone page should celebrate the palindromic pat:\n%s", body)
+	}
+	if rec := serve(e, http.MethodPost, "/pat/", nil); rec.Code != http.StatusFound {
+		t.Errorf("POST /pat/ without a milestone: status = %d, want %d", rec.Code, http.StatusFound)
+	}
+}
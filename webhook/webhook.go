@@ -0,0 +1,168 @@
+// Package webhook delivers journal events to external subscribers.
+//
+// Deliveries are queued in the database in the same transaction as the journal
+// records they are about, see db.EnqueueDeliveries. Workers claim due
+// deliveries one by one, so any number of them may run at the same time, e.g.
+// one per server replica. Failed attempts are retried with exponential backoff.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/nevkontakte/pat/chrono"
+	"github.com/nevkontakte/pat/db"
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultPeriod is the default time between delivery steps.
+	DefaultPeriod = 10 * time.Second
+	// DefaultTimeout is the default limit on the duration of a single attempt.
+	DefaultTimeout = 10 * time.Second
+	// batchSize is the maximum number of deliveries attempted in one step.
+	batchSize = 100
+)
+
+// Request headers of webhook deliveries.
+const (
+	HeaderEvent     = "X-Pat-Event"     // Event type name.
+	HeaderDelivery  = "X-Pat-Delivery"  // Delivery ID, the same for all attempts.
+	HeaderSignature = "X-Pat-Signature" // Payload signature, see Sign.
+)
+
+// Sign returns the signature of the payload with the subscription secret in
+// the format of the HeaderSignature header: "sha256=" followed by hex-encoded
+// HMAC-SHA256.
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify returns true if the signature matches the payload. Receivers written
+// in Go can use it to authenticate deliveries.
+func Verify(secret string, payload []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, payload)), []byte(signature))
+}
+
+// Worker periodically attempts due webhook deliveries.
+type Worker struct {
+	DB     *gorm.DB
+	Clock  chrono.Clock // Source of the current time. Defaults to chrono.System.
+	Client *http.Client // Client for delivery requests. Defaults to http.DefaultClient.
+	// Period is the time between delivery steps. Defaults to DefaultPeriod.
+	Period time.Duration
+	// Timeout limits the duration of a single attempt. Other workers may start
+	// another attempt of the same delivery after that. Defaults to
+	// DefaultTimeout.
+	Timeout time.Duration
+}
+
+func (w *Worker) clock() chrono.Clock {
+	return chrono.Or(w.Clock)
+}
+
+func (w *Worker) client() *http.Client {
+	if w.Client == nil {
+		return http.DefaultClient
+	}
+	return w.Client
+}
+
+func (w *Worker) period() time.Duration {
+	if w.Period <= 0 {
+		return DefaultPeriod
+	}
+	return w.Period
+}
+
+func (w *Worker) timeout() time.Duration {
+	if w.Timeout <= 0 {
+		return DefaultTimeout
+	}
+	return w.Timeout
+}
+
+// Run performs delivery steps until the context is canceled. Failed steps are
+// logged and retried at the next step.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := w.clock().NewTicker(w.period())
+	defer ticker.Stop()
+	for {
+		if _, err := w.Step(ctx); err != nil {
+			slog.Error("webhook delivery step failed", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+// Step attempts deliveries that are due at the current time and returns the
+// number of successful ones. Failed attempts are recorded in the deliveries and
+// aren't step errors.
+func (w *Worker) Step(ctx context.Context) (int, error) {
+	tx := w.DB.WithContext(ctx)
+	due, err := db.DueDeliveries(tx, w.clock().Now(), batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load due deliveries: %w", err)
+	}
+	delivered := 0
+	for _, d := range due {
+		claimed, err := db.ClaimDelivery(tx, &d, w.clock().Now(), w.timeout())
+		if err != nil {
+			return delivered, fmt.Errorf("failed to claim delivery %d: %w", d.ID, err)
+		}
+		if !claimed {
+			continue // Another worker got there first.
+		}
+		failure := w.attempt(ctx, d)
+		if err := db.FinishDelivery(tx, &d, w.clock().Now(), failure); err != nil {
+			return delivered, fmt.Errorf("failed to record delivery %d result: %w", d.ID, err)
+		}
+		if failure == nil {
+			delivered++
+		}
+	}
+	return delivered, nil
+}
+
+// attempt sends the delivery request and returns an error unless the receiver
+// accepted it with a 2xx response.
+func (w *Worker) attempt(ctx context.Context, d db.Delivery) error {
+	ctx, cancel := context.WithTimeout(ctx, w.timeout())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.Webhook.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "pat-webhook")
+	req.Header.Set(HeaderEvent, d.Event.String())
+	req.Header.Set(HeaderDelivery, strconv.FormatUint(d.ID, 10))
+	req.Header.Set(HeaderSignature, Sign(d.Webhook.Secret, d.Payload))
+
+	resp, err := w.client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// Drain the body, so that the connection can be reused.
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("receiver responded with %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nevkontakte/pat/chrono/chronotest"
+	"github.com/nevkontakte/pat/db"
+	"github.com/nevkontakte/pat/db/dbtest"
+	"gorm.io/gorm"
+)
+
+// receiver is an httptest webhook receiver that verifies signatures and fails
+// while failures is positive.
+type receiver struct {
+	t        *testing.T
+	secret   string
+	mu       sync.Mutex
+	failures int
+	payloads []db.WebhookPayload
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		r.t.Errorf("Got: error reading the request body: %s. Want: no error.", err)
+		return
+	}
+	if sig := req.Header.Get(HeaderSignature); !Verify(r.secret, body, sig) {
+		r.t.Errorf("Got: invalid signature %q. Want: %q.", sig, Sign(r.secret, body))
+	}
+	var p db.WebhookPayload
+	if err := json.Unmarshal(body, &p); err != nil {
+		r.t.Errorf("Got: invalid payload %q: %s. Want: JSON.", body, err)
+	}
+	if got := req.Header.Get(HeaderEvent); got != p.Event {
+		r.t.Errorf("Got: %s header %q. Want: %q.", HeaderEvent, got, p.Event)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failures > 0 {
+		r.failures--
+		http.Error(w, "try again later", http.StatusServiceUnavailable)
+		return
+	}
+	r.payloads = append(r.payloads, p)
+}
+
+func (r *receiver) received() []db.WebhookPayload {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]db.WebhookPayload(nil), r.payloads...)
+}
+
+func setup(t *testing.T, r *receiver) (*gorm.DB, *chronotest.Clock) {
+	tx := dbtest.InMemory(t)
+	tx.AutoMigrate(db.Cat{}, db.Journal{}, db.Webhook{}, db.Delivery{})
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	dbtest.Save(t, tx, &db.Cat{ID: db.SplotchID, Name: "Splotch", LatestPat: start.Add(-30 * 24 * time.Hour)})
+
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	if err := db.CreateWebhook(tx, &db.Webhook{URL: srv.URL, Secret: r.secret, Events: db.EventTypes{db.EventPat}}); err != nil {
+		t.Fatalf("Got: CreateWebhook() returned error: %s. Want: no error.", err)
+	}
+	return tx, chronotest.NewClock(start)
+}
+
+func TestWorkerDelivers(t *testing.T) {
+	r := &receiver{t: t, secret: "s3cret"}
+	tx, clock := setup(t, r)
+	w := &Worker{DB: tx, Clock: clock}
+
+	pat, _ := db.InteractionByID("pat")
+	feed, _ := db.InteractionByID("feed")
+	for _, i := range []db.Interaction{pat, feed} {
+		if _, err := db.Interact(tx, clock, db.SplotchID, i, &db.Visitor{}); err != nil {
+			t.Fatalf("Got: Interact(%q) returned error: %s. Want: no error.", i.ID, err)
+		}
+	}
+
+	n, err := w.Step(context.Background())
+	if err != nil {
+		t.Fatalf("Got: Step() returned error: %s. Want: no error.", err)
+	}
+	if n != 1 {
+		t.Errorf("Got: Step() delivered %d payloads. Want: 1, feeding is filtered out.", n)
+	}
+	got := r.received()
+	if len(got) != 1 {
+		t.Fatalf("Got: receiver got %d payloads. Want: 1.", len(got))
+	}
+	want := db.WebhookPayload{
+		ID:          got[0].ID,
+		Event:       "pat",
+		Cat:         db.SplotchID,
+		Description: got[0].Description,
+		Time:        clock.Now(),
+		PrevMood:    db.MoodImpatient,
+		Mood:        db.MoodPat,
+	}
+	if got[0] != want || got[0].Description == "" {
+		t.Errorf("Got: payload %+v. Want: %+v with a description.", got[0], want)
+	}
+
+	// Delivered payloads aren't sent again.
+	if n, err := w.Step(context.Background()); err != nil || n != 0 {
+		t.Errorf("Got: repeated Step() = %d, %v. Want: 0, no error.", n, err)
+	}
+}
+
+func TestWorkerRetries(t *testing.T) {
+	r := &receiver{t: t, secret: "s3cret", failures: 2}
+	tx, clock := setup(t, r)
+	w := &Worker{DB: tx, Clock: clock}
+
+	pat, _ := db.InteractionByID("pat")
+	if _, err := db.Interact(tx, clock, db.SplotchID, pat, &db.Visitor{}); err != nil {
+		t.Fatalf("Got: Interact() returned error: %s. Want: no error.", err)
+	}
+
+	step := func() {
+		t.Helper()
+		if _, err := w.Step(context.Background()); err != nil {
+			t.Fatalf("Got: Step() returned error: %s. Want: no error.", err)
+		}
+	}
+	delivery := func() db.Delivery {
+		t.Helper()
+		var d db.Delivery
+		dbtest.First(t, tx, &d)
+		return d
+	}
+
+	step() // First attempt fails.
+	d := delivery()
+	if d.Status != db.DeliveryPending || d.Attempts != 1 || d.LastError == "" {
+		t.Fatalf("Got: delivery after a failure %+v. Want: pending with one attempt and an error.", d)
+	}
+	if want := clock.Now().Add(db.DeliveryBackoff(1)); !d.NextAttempt.Equal(want) {
+		t.Errorf("Got: next attempt at %s. Want: %s.", d.NextAttempt, want)
+	}
+
+	// Nothing is attempted before the backoff expires.
+	clock.Advance(db.DeliveryBackoff(1) - time.Second)
+	step()
+	if d := delivery(); d.Attempts != 1 {
+		t.Errorf("Got: %d attempts before the backoff expired. Want: 1.", d.Attempts)
+	}
+
+	clock.Advance(time.Second)
+	step() // Second attempt fails.
+	if d := delivery(); d.Attempts != 2 || !d.NextAttempt.Equal(clock.Now().Add(db.DeliveryBackoff(2))) {
+		t.Errorf("Got: delivery after the second failure %+v. Want: two attempts, the next one after %s.", d, db.DeliveryBackoff(2))
+	}
+
+	clock.Advance(db.DeliveryBackoff(2))
+	step() // Third attempt succeeds.
+	if d := delivery(); d.Status != db.DeliveryDelivered || d.Attempts != 3 || d.LastError != "" {
+		t.Errorf("Got: delivery %+v. Want: delivered after 3 attempts.", d)
+	}
+	if got := len(r.received()); got != 1 {
+		t.Errorf("Got: receiver got %d payloads. Want: 1.", got)
+	}
+}
+
+func TestWorkerGivesUp(t *testing.T) {
+	r := &receiver{t: t, secret: "s3cret", failures: db.MaxDeliveryAttempts}
+	tx, clock := setup(t, r)
+	w := &Worker{DB: tx, Clock: clock}
+
+	pat, _ := db.InteractionByID("pat")
+	if _, err := db.Interact(tx, clock, db.SplotchID, pat, &db.Visitor{}); err != nil {
+		t.Fatalf("Got: Interact() returned error: %s. Want: no error.", err)
+	}
+	for range db.MaxDeliveryAttempts + 1 {
+		if _, err := w.Step(context.Background()); err != nil {
+			t.Fatalf("Got: Step() returned error: %s. Want: no error.", err)
+		}
+		clock.Advance(24 * time.Hour)
+	}
+	var d db.Delivery
+	dbtest.First(t, tx, &d)
+	if d.Status != db.DeliveryFailed || d.Attempts != db.MaxDeliveryAttempts {
+		t.Errorf("Got: delivery %+v. Want: failed after %d attempts.", d, db.MaxDeliveryAttempts)
+	}
+}
+
+func TestSign(t *testing.T) {
+	// Reference signature computed with
+	// printf 'hello' | openssl dgst -sha256 -hmac secret
+	const want = "sha256=88aab3ede8d3adf94d26ab90d3bafd4a2083070c3bcce9c014ee04a443847c0b"
+	if got := Sign("secret", []byte("hello")); got != want {
+		t.Errorf("Got: Sign() = %q. Want: %q.", got, want)
+	}
+	if Verify("other", []byte("hello"), want) {
+		t.Errorf("Got: Verify() with a wrong secret returned true. Want: false.")
+	}
+}